@@ -0,0 +1,16 @@
+package Assets
+
+import (
+	_ "embed"
+)
+
+/* The Inter fonts used on invoices are built into the binary, so PDFs render from any directory */
+
+//go:embed fonts/Inter-VariableFont_opsz,wght.ttf
+var InterRegular []byte
+
+//go:embed fonts/Inter-Italic-VariableFont_opsz,wght.ttf
+var InterItalic []byte
+
+//go:embed fonts/static/Inter_18pt-Bold.ttf
+var InterBold []byte
@@ -0,0 +1,300 @@
+package Cli
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	InvoiceManager "moneybringer/invoice-manager"
+	CompanyData "moneybringer/invoice-manager/company"
+	CustomerData "moneybringer/invoice-manager/customer"
+	InvoiceStore "moneybringer/invoice-store"
+	AppErrors "moneybringer/utils/app-errors"
+	AppPaths "moneybringer/utils/app-paths"
+	"os"
+	"sort"
+	"strings"
+)
+
+const EXIT_OK = 0
+const EXIT_ERROR = 1
+const EXIT_USAGE = 2
+const EXIT_NOT_FOUND = 3
+const EXIT_INVALID = 4
+
+type command struct {
+	name    string
+	summary string
+	run     func(args []string) int
+}
+
+/* openedRepository is opened from storage.json by the first command that needs it and closed when Run returns */
+var openedRepository InvoiceStore.InvoiceRepository
+
+/* output implements the --quiet and --json modes shared by every command */
+type output struct {
+	quiet bool
+	json  bool
+}
+
+func commands() []command {
+	return []command{
+		{name: "create", summary: "Create and issue a new invoice (interactive or from --input spec)", run: runCreate},
+		{name: "list", summary: "List stored invoices", run: runList},
+		{name: "show", summary: "Show a stored invoice", run: runShow},
+		{name: "render", summary: "Render the PDF of a stored invoice again", run: runRender},
+		{name: "convert", summary: "Issue the VAT invoice for a stored proforma", run: runConvert},
+		{name: "correct", summary: "Issue a correction invoice for a stored invoice", run: runCorrect},
+		{name: "export", summary: "Export invoices: ksef (FA(2) XML, optional sending) or jpk (JPK_V7M)", run: runExport},
+		{name: "jpk", summary: "Generate the JPK_V7M sales register for a month (same as export jpk)", run: runJpk},
+		{name: "customers", summary: "Manage customers in customers.json: list, show, add, edit, remove", run: runCustomers},
+		{name: "config", summary: "Configuration tools: config validate, config paths, config import-files", run: runConfig},
+		{name: "serve", summary: "Serve the JSON API and web front-end for invoices on localhost", run: runServe},
+	}
+}
+
+/* Run dispatches os.Args[1:] to a subcommand and returns the process exit code */
+func Run(args []string) int {
+	defer closeRepository()
+	InvoiceManager.SetStoredInvoices(storedInvoices)
+
+	if len(args) == 0 {
+		printUsage(os.Stderr)
+		return EXIT_USAGE
+	}
+
+	/* "moneybringer -customer X" from before subcommands existed still creates an invoice */
+	if strings.HasPrefix(args[0], "-") && args[0] != "-h" && args[0] != "--help" {
+		return runCreate(args)
+	}
+
+	if args[0] == "help" || args[0] == "-h" || args[0] == "--help" {
+		if len(args) > 1 {
+			return Run([]string{args[1], "-h"})
+		}
+		printUsage(os.Stdout)
+		return EXIT_OK
+	}
+
+	for _, cmd := range commands() {
+		if cmd.name == args[0] {
+			return cmd.run(args[1:])
+		}
+	}
+
+	fmt.Fprintf(os.Stderr, "Unknown command %q\n\n", args[0])
+	printUsage(os.Stderr)
+
+	return EXIT_USAGE
+}
+
+func printUsage(w io.Writer) {
+	fmt.Fprintln(w, "Moneybringer - let's make some money, baby!")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Usage: moneybringer <command> [flags]")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Commands:")
+	for _, cmd := range commands() {
+		fmt.Fprintf(w, "  %-10s %s\n", cmd.name, cmd.summary)
+	}
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Run \"moneybringer <command> -h\" for command flags.")
+	fmt.Fprintln(w, "Exit codes: 0 ok, 1 error, 2 usage, 3 not found, 4 validation failed.")
+}
+
+func newFlagSet(name string, usage string, out *output) *flag.FlagSet {
+	flags := flag.NewFlagSet(name, flag.ContinueOnError)
+	flags.SetOutput(os.Stderr)
+	flags.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: moneybringer %s\n\nFlags:\n", usage)
+		flags.PrintDefaults()
+	}
+
+	flags.Func("config-dir", "Directory with company.json, customers.json, ksef.json and storage.json", setDir(AppPaths.SetConfigDir))
+	flags.Func("data-dir", "Directory with stored invoices", setDir(AppPaths.SetInvoicesDir))
+
+	if out != nil {
+		flags.BoolVar(&out.quiet, "quiet", false, "Print nothing but errors")
+		flags.BoolVar(&out.json, "json", false, "Print machine readable JSON on stdout")
+	}
+
+	return flags
+}
+
+func setDir(set func(dir string)) func(value string) error {
+	return func(value string) error {
+		if strings.TrimSpace(value) == "" {
+			return errors.New("directory must not be empty")
+		}
+		set(value)
+		return nil
+	}
+}
+
+func addProfileFlag(flags *flag.FlagSet, usage string) *string {
+	return flags.String("profile", "", usage)
+}
+
+/* selectProfile makes profile the seller of newly created documents, empty selects the default profile */
+func selectProfile(profile string) (CompanyData.Company, int) {
+	company, err := CompanyData.LoadCompanyData(CompanyData.CompanyJsonPath(), profile)
+	if errors.Is(err, CompanyData.ErrProfileNotFound) {
+		fmt.Fprintln(os.Stderr, err)
+		return company, EXIT_NOT_FOUND
+	}
+	if errors.Is(err, CompanyData.ErrInvalidCompany) {
+		fmt.Fprintf(os.Stderr, "%v\nRun \"moneybringer config validate\" for details\n", err)
+		return company, EXIT_INVALID
+	}
+	if err != nil {
+		return company, fail("Error reading company data", err)
+	}
+
+	CompanyData.SelectProfile(company.Profile)
+
+	return company, EXIT_OK
+}
+
+/* parseFlags allows flags after positional arguments, e.g. "show 1/10/2026 --json" */
+func parseFlags(flags *flag.FlagSet, args []string) ([]string, int, bool) {
+	var positional []string
+
+	for {
+		if err := flags.Parse(args); err != nil {
+			if errors.Is(err, flag.ErrHelp) {
+				return nil, EXIT_OK, false
+			}
+			return nil, EXIT_USAGE, false
+		}
+
+		if flags.NArg() == 0 {
+			return positional, EXIT_OK, true
+		}
+
+		positional = append(positional, flags.Arg(0))
+		args = flags.Args()[1:]
+	}
+}
+
+/* invoiceRepository returns the invoice storage selected in storage.json */
+func invoiceRepository() (InvoiceStore.InvoiceRepository, int) {
+	repository, err := openRepository()
+	if err != nil {
+		return nil, failWith("Error opening invoice storage", err, EXIT_ERROR)
+	}
+
+	return repository, EXIT_OK
+}
+
+func openRepository() (InvoiceStore.InvoiceRepository, error) {
+	if openedRepository != nil {
+		return openedRepository, nil
+	}
+
+	repository, err := InvoiceStore.Open()
+	if err != nil {
+		return nil, err
+	}
+	openedRepository = repository
+
+	return repository, nil
+}
+
+/* storedInvoices seeds the numbering series it does not know yet with the invoices in storage */
+func storedInvoices() ([]InvoiceManager.InvoiceCreatedData, error) {
+	repository, err := openRepository()
+	if err != nil {
+		return nil, err
+	}
+
+	stored, err := repository.List(InvoiceStore.Filter{})
+	if err != nil {
+		return nil, err
+	}
+
+	invoices := make([]InvoiceManager.InvoiceCreatedData, 0, len(stored))
+	for _, storedInvoice := range stored {
+		invoices = append(invoices, storedInvoice.Invoice)
+	}
+
+	return invoices, nil
+}
+
+func closeRepository() {
+	if openedRepository != nil {
+		openedRepository.Close()
+		openedRepository = nil
+	}
+}
+
+func (o output) printf(format string, args ...interface{}) {
+	if o.quiet || o.json {
+		return
+	}
+
+	fmt.Printf(format, args...)
+}
+
+/* result prints value as JSON in --json mode, otherwise runs the human readable printer */
+func (o output) result(value interface{}, text func()) {
+	if o.json {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		encoder.Encode(value)
+		return
+	}
+
+	if !o.quiet {
+		text()
+	}
+}
+
+func fail(message string, err error) int {
+	fmt.Fprintf(os.Stderr, "%s: %v\n", message, err)
+	return EXIT_ERROR
+}
+
+/* failWith maps the error kinds of the packages to exit codes, fallback covers the other errors */
+func failWith(message string, err error, fallback int) int {
+	fmt.Fprintf(os.Stderr, "%s: %v\n", message, err)
+
+	switch {
+	case errors.Is(err, AppErrors.ErrConfigInvalid):
+		return EXIT_INVALID
+	case errors.Is(err, CustomerData.ErrCustomerNotFound), errors.Is(err, CompanyData.ErrProfileNotFound):
+		return EXIT_NOT_FOUND
+	case errors.Is(err, AppErrors.ErrStorage):
+		return EXIT_ERROR
+	}
+
+	return fallback
+}
+
+func usageError(flags *flag.FlagSet, message string) int {
+	fmt.Fprintln(os.Stderr, message)
+	flags.Usage()
+	return EXIT_USAGE
+}
+
+/* flagValue is the value of a flag given on the command line, empty for a flag left at its default */
+func flagValue(flags *flag.FlagSet, name string) string {
+	value := ""
+	flags.Visit(func(given *flag.Flag) {
+		if given.Name == name {
+			value = given.Value.String()
+		}
+	})
+
+	return value
+}
+
+func sortedKeys[V any](values map[string]V) []string {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	return keys
+}
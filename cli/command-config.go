@@ -0,0 +1,260 @@
+package Cli
+
+import (
+	"fmt"
+	InvoiceManager "moneybringer/invoice-manager"
+	CompanyData "moneybringer/invoice-manager/company"
+	CustomerData "moneybringer/invoice-manager/customer"
+	InvoiceNumbering "moneybringer/invoice-manager/numbering"
+	InvoiceStore "moneybringer/invoice-store"
+	JpkExporter "moneybringer/jpk-exporter"
+	KsefClient "moneybringer/ksef-client"
+	AppPaths "moneybringer/utils/app-paths"
+	"os"
+)
+
+func runConfig(args []string) int {
+	if len(args) > 0 && args[0] == "paths" {
+		return runConfigPaths(args[1:])
+	}
+
+	if len(args) > 0 && args[0] == "import-files" {
+		return runConfigImportFiles(args[1:])
+	}
+
+	if len(args) == 0 || args[0] != "validate" {
+		fmt.Fprintln(os.Stderr, "Usage: moneybringer config validate|paths|import-files [--json] [--quiet]")
+		return EXIT_USAGE
+	}
+
+	return runConfigValidate(args[1:])
+}
+
+/* runConfigPaths prints where config files and invoices are read from and which rule chose the directory */
+func runConfigPaths(args []string) int {
+	var out output
+	flags := newFlagSet("config paths", "config paths [--config-dir DIR] [--data-dir DIR]", &out)
+	if _, code, ok := parseFlags(flags, args); !ok {
+		return code
+	}
+
+	paths := map[string]AppPaths.Location{
+		"config":   AppPaths.ResolveConfigDir(),
+		"invoices": AppPaths.ResolveInvoicesDir(),
+	}
+
+	out.result(paths, func() {
+		for _, name := range sortedKeys(paths) {
+			fmt.Printf("%-10s %s (%s)\n", name, paths[name].Dir, paths[name].Source)
+		}
+	})
+
+	return EXIT_OK
+}
+
+/* runConfigImportFiles copies invoices stored as files into the SQLite database selected in storage.json */
+func runConfigImportFiles(args []string) int {
+	var out output
+	flags := newFlagSet("config import-files", "config import-files", &out)
+	if _, code, ok := parseFlags(flags, args); !ok {
+		return code
+	}
+
+	config, err := InvoiceStore.LoadConfig(InvoiceStore.StorageConfigJsonPath())
+	if err != nil {
+		return fail("Error reading storage config", err)
+	}
+	if config.Backend != InvoiceStore.BACKEND_SQLITE {
+		return fail("Error importing invoices", fmt.Errorf("%s selects the %s backend, set \"backend\": %q first", InvoiceStore.StorageConfigJsonPath(), config.Backend, InvoiceStore.BACKEND_SQLITE))
+	}
+
+	repository, err := InvoiceStore.NewSqliteRepository(config.SqlitePath)
+	if err != nil {
+		return fail("Error opening invoice storage", err)
+	}
+	defer repository.Close()
+
+	imported, err := repository.ImportFiles()
+	if err != nil {
+		return fail(fmt.Sprintf("Error importing invoices (%d imported)", imported), err)
+	}
+
+	out.result(map[string]interface{}{"imported": imported, "database": config.SqlitePath}, func() {
+		fmt.Printf("Imported %d invoices into %s\n", imported, config.SqlitePath)
+	})
+
+	return EXIT_OK
+}
+
+func runConfigValidate(args []string) int {
+	var out output
+	flags := newFlagSet("config validate", "config validate", &out)
+	if _, code, ok := parseFlags(flags, args); !ok {
+		return code
+	}
+
+	problems := map[string][]string{
+		CompanyData.CompanyJsonPath():        validateCompanyConfig(),
+		CustomerData.CustomersJsonPath():     validateCustomersConfig(),
+		KsefClient.KsefConfigJsonPath():      validateKsefConfig(),
+		InvoiceStore.StorageConfigJsonPath(): validateStorageConfig(),
+	}
+
+	valid := true
+	for _, fileProblems := range problems {
+		if len(fileProblems) > 0 {
+			valid = false
+		}
+	}
+
+	out.result(map[string]interface{}{"valid": valid, "problems": problems}, func() {
+		for _, path := range sortedKeys(problems) {
+			if len(problems[path]) == 0 {
+				fmt.Printf("%s: ok\n", path)
+				continue
+			}
+
+			fmt.Printf("%s:\n", path)
+			for _, problem := range problems[path] {
+				fmt.Printf("  - %s\n", problem)
+			}
+		}
+	})
+
+	if !valid {
+		return EXIT_INVALID
+	}
+
+	return EXIT_OK
+}
+
+func validateCompanyConfig() []string {
+	problems := []string{}
+
+	profiles, _, err := CompanyData.LoadProfiles(CompanyData.CompanyJsonPath())
+	if err != nil {
+		return append(problems, err.Error())
+	}
+
+	/* a company.json with a single seller reports fields as before, profiles get their key as prefix */
+	for _, key := range sortedKeys(profiles) {
+		prefix := ""
+		if len(profiles) > 1 || key != CompanyData.DEFAULT_PROFILE {
+			prefix = "profiles." + key + "."
+		}
+
+		for _, problem := range validateCompanyProfile(profiles[key]) {
+			problems = append(problems, prefix+problem)
+		}
+	}
+
+	return problems
+}
+
+func validateCompanyProfile(company CompanyData.Company) []string {
+	problems := []string{}
+
+	details := company.InvoiceDetails
+	patterns := map[string]string{
+		"numberPattern":           details.NumberPattern,
+		"correctionNumberPattern": details.CorrectionNumberPattern,
+		"proformaNumberPattern":   details.ProformaNumberPattern,
+	}
+	for _, field := range sortedKeys(patterns) {
+		if patterns[field] == "" {
+			continue
+		}
+		if err := InvoiceNumbering.ValidatePattern(patterns[field]); err != nil {
+			problems = append(problems, fmt.Sprintf("invoiceDetails.%s: %v", field, err))
+		}
+	}
+
+	switch details.VatRounding {
+	case "", InvoiceManager.VAT_ROUNDING_PER_LINE, InvoiceManager.VAT_ROUNDING_PER_TOTAL:
+	default:
+		problems = append(problems, fmt.Sprintf("invoiceDetails.vatRounding must be %q or %q", InvoiceManager.VAT_ROUNDING_PER_LINE, InvoiceManager.VAT_ROUNDING_PER_TOTAL))
+	}
+
+	if currency := company.InvoicePosition.DefaultCurrency; currency != "" {
+		if _, err := InvoiceManager.ParseCurrency(currency); err != nil {
+			problems = append(problems, fmt.Sprintf("invoicePosition.defaultCurrency: %v", err))
+		}
+	}
+
+	switch company.Jpk.TaxpayerType {
+	case "", JpkExporter.TAXPAYER_COMPANY, JpkExporter.TAXPAYER_PERSON:
+	default:
+		problems = append(problems, fmt.Sprintf("jpk.taxpayerType must be %q or %q", JpkExporter.TAXPAYER_COMPANY, JpkExporter.TAXPAYER_PERSON))
+	}
+
+	problems = append(problems, CompanyData.Validate(company)...)
+
+	for i, account := range company.CompanyDetails.BankAccounts {
+		if account.Currency == "" {
+			continue
+		}
+		if _, err := InvoiceManager.ParseCurrency(account.Currency); err != nil {
+			problems = append(problems, fmt.Sprintf("companyDetails.bankAccounts[%d].currency: %v", i, err))
+		}
+	}
+
+	if company.Logo != "" {
+		if _, err := os.Stat(company.Logo); err != nil {
+			problems = append(problems, fmt.Sprintf("logo: %v", err))
+		}
+	}
+
+	return problems
+}
+
+func validateCustomersConfig() []string {
+	problems := []string{}
+
+	customersData, err := CustomerData.LoadCustomers(CustomerData.CustomersJsonPath())
+	if err != nil {
+		return append(problems, err.Error())
+	}
+
+	pending, _ := CustomerData.PendingMigration(CustomerData.CustomersJsonPath())
+	for _, key := range pending {
+		problems = append(problems, fmt.Sprintf("customers.%s.address.countryCode is missing, run \"moneybringer customers migrate\"", key))
+	}
+
+	for _, key := range sortedKeys(customersData.Customers) {
+		if err := CustomerData.ValidateKey(key); err != nil {
+			problems = append(problems, err.Error())
+		}
+		for _, problem := range CustomerData.Validate(customersData.Customers[key]) {
+			problems = append(problems, fmt.Sprintf("customers.%s.%s", key, problem))
+		}
+	}
+
+	return problems
+}
+
+func validateKsefConfig() []string {
+	problems := []string{}
+
+	if _, err := os.Stat(KsefClient.KsefConfigJsonPath()); os.IsNotExist(err) {
+		return problems
+	}
+
+	if _, err := KsefClient.LoadConfig(KsefClient.KsefConfigJsonPath()); err != nil {
+		problems = append(problems, err.Error())
+	}
+
+	return problems
+}
+
+/* validateStorageConfig also opens the SQLite database, a binary built without cgo cannot */
+func validateStorageConfig() []string {
+	problems := []string{}
+
+	repository, err := InvoiceStore.Open()
+	if err != nil {
+		return append(problems, err.Error())
+	}
+	repository.Close()
+
+	return problems
+}
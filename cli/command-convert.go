@@ -0,0 +1,61 @@
+package Cli
+
+import (
+	"errors"
+	"fmt"
+	InvoiceManager "moneybringer/invoice-manager"
+	InvoiceStore "moneybringer/invoice-store"
+	TimeUtils "moneybringer/utils/time"
+	"os"
+)
+
+func runConvert(args []string) int {
+	var out output
+	flags := newFlagSet("convert", "convert <proforma-no> [--date DD-MM-YYYY] [--profile KEY] [--send-ksef]", &out)
+	dateOfIssue := flags.String("date", TimeUtils.FormatToDdMmYyyy(TimeUtils.GetCurrentTime()), "Date of issue of the VAT invoice (DD-MM-YYYY)")
+	options := addIssueFlags(flags)
+
+	positional, code, ok := parseFlags(flags, args)
+	if !ok {
+		return code
+	}
+	if len(positional) != 1 {
+		return usageError(flags, "convert needs exactly one proforma number")
+	}
+	if _, err := TimeUtils.ParseDdMmYyyy(*dateOfIssue); err != nil {
+		return usageError(flags, "--date must be in DD-MM-YYYY format")
+	}
+
+	proforma, code := findInvoice(positional[0], options.profile)
+	if code != EXIT_OK {
+		return code
+	}
+
+	invoice, err := InvoiceManager.ConvertProforma(proforma.Invoice, *dateOfIssue)
+	if errors.Is(err, InvoiceManager.ErrNotProforma) || errors.Is(err, InvoiceManager.ErrProformaConverted) {
+		fmt.Fprintln(os.Stderr, "Error converting proforma:", err)
+		return EXIT_INVALID
+	}
+	if err != nil {
+		return failWith("Error converting proforma", err, EXIT_ERROR)
+	}
+
+	stored, code := issueInvoice(invoice, *options, out)
+	if code != EXIT_OK {
+		return code
+	}
+
+	repository, code := invoiceRepository()
+	if code != EXIT_OK {
+		return code
+	}
+	if err := repository.UpdateStatus(&proforma, InvoiceStore.Status{ConvertedToInvoiceNo: stored.Invoice.InvoiceNo}); err != nil {
+		return fail("Error linking proforma with the invoice", err)
+	}
+
+	out.result(stored.Invoice, func() {
+		fmt.Printf("Invoice %s issued for proforma %s\n", stored.Invoice.InvoiceNo, proforma.Invoice.InvoiceNo)
+	})
+
+	return EXIT_OK
+}
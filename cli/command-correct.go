@@ -0,0 +1,105 @@
+package Cli
+
+import (
+	"errors"
+	"fmt"
+	InvoiceManager "moneybringer/invoice-manager"
+	Invoice "moneybringer/invoice-manager/invoice"
+	InvoiceSpec "moneybringer/invoice-manager/spec"
+	InvoiceStore "moneybringer/invoice-store"
+	"os"
+)
+
+func runCorrect(args []string) int {
+	var out output
+	flags := newFlagSet("correct", "correct <invoice-no> [--input correction.json | --answers FILE] [--reason TEXT] [--profile KEY] [--send-ksef]", &out)
+	inputPath := flags.String("input", "", "Path to a JSON/YAML correction spec with the positions after correction, no prompts")
+	answersPath := flags.String("answers", "", "File with the answers to the interactive prompts, one per line, an empty line takes the default")
+	reason := flags.String("reason", "", "Reason for correction (interactive mode asks for it when empty)")
+	options := addIssueFlags(flags)
+
+	positional, code, ok := parseFlags(flags, args)
+	if !ok {
+		return code
+	}
+	if len(positional) != 1 {
+		return usageError(flags, "correct needs exactly one invoice number")
+	}
+
+	original, code := findInvoice(positional[0], options.profile)
+	if code != EXIT_OK {
+		return code
+	}
+
+	current, code := currentInvoiceState(original.Invoice)
+	if code != EXIT_OK {
+		return code
+	}
+
+	var correction InvoiceManager.InvoiceCreatedData
+	var err error
+
+	if *inputPath != "" {
+		spec, loadErr := InvoiceSpec.LoadCorrection(*inputPath)
+		if loadErr != nil {
+			fmt.Fprintln(os.Stderr, "Error loading correction spec:", loadErr)
+			return EXIT_INVALID
+		}
+
+		if *reason != "" {
+			spec.Reason = *reason
+		}
+		correction, err = InvoiceManager.CreateCorrectionFromSpec(current, spec)
+	} else {
+		if out.json {
+			return usageError(flags, "--json needs --input, interactive prompts would mix with the JSON output")
+		}
+
+		if code := useAnswers(*answersPath); code != EXIT_OK {
+			return code
+		}
+
+		fmt.Printf("Prepare correction of invoice %s issued %s\n", current.InvoiceNo, current.DateOfIssue)
+		correction, err = InvoiceManager.CreateCorrection(current, *reason)
+	}
+
+	if errors.Is(err, InvoiceManager.ErrNothingToCorrect) || errors.Is(err, InvoiceManager.ErrCorrectingCorrection) || errors.Is(err, InvoiceManager.ErrCorrectingProforma) || errors.Is(err, InvoiceManager.ErrCorrectingAdvance) || errors.Is(err, InvoiceManager.ErrMixedCurrency) || errors.Is(err, Invoice.ErrMissingExemptionBasis) {
+		fmt.Fprintln(os.Stderr, "Error creating correction:", err)
+		return EXIT_INVALID
+	}
+	if err != nil {
+		return failWith("Error creating correction", err, EXIT_ERROR)
+	}
+
+	stored, code := issueInvoice(correction, *options, out)
+	if code != EXIT_OK {
+		return code
+	}
+
+	out.result(stored.Invoice, func() {
+		summary := stored.Invoice.InvoiceSummary
+		fmt.Printf("Correction %s of invoice %s issued, gross difference %s %s\n", stored.Invoice.InvoiceNo, current.InvoiceNo, summary.TotalGrossValue, summary.TotalGrossValue.Currency)
+	})
+
+	return EXIT_OK
+}
+
+/* currentInvoiceState applies corrections issued earlier so a new correction starts from the last state */
+func currentInvoiceState(original InvoiceManager.InvoiceCreatedData) (InvoiceManager.InvoiceCreatedData, int) {
+	repository, code := invoiceRepository()
+	if code != EXIT_OK {
+		return original, code
+	}
+
+	storedCorrections, err := repository.List(InvoiceStore.Filter{Profile: original.SellerProfile(), CorrectedInvoiceNo: original.InvoiceNo})
+	if err != nil {
+		return original, fail("Error reading stored invoices", err)
+	}
+
+	var corrections []InvoiceManager.InvoiceCreatedData
+	for _, stored := range storedCorrections {
+		corrections = append(corrections, stored.Invoice)
+	}
+
+	return InvoiceManager.CurrentState(original, corrections), EXIT_OK
+}
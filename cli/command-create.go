@@ -0,0 +1,336 @@
+package Cli
+
+import (
+	"flag"
+	"fmt"
+	ExchangeRate "moneybringer/exchange-rate"
+	InvoiceGenerator "moneybringer/invoice-generator"
+	InvoiceManager "moneybringer/invoice-manager"
+	CompanyData "moneybringer/invoice-manager/company"
+	CustomerData "moneybringer/invoice-manager/customer"
+	InvoiceSpec "moneybringer/invoice-manager/spec"
+	InvoiceStore "moneybringer/invoice-store"
+	Tui "moneybringer/tui"
+	AppPaths "moneybringer/utils/app-paths"
+	Money "moneybringer/utils/money"
+	Prompt "moneybringer/utils/prompt"
+	TimeUtils "moneybringer/utils/time"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/mattn/go-isatty"
+)
+
+func runCreate(args []string) int {
+	var out output
+	flags := newFlagSet("create", "create [--customer KEY [--answers FILE | --tui] | --input spec.json] [--proforma | --advance AMOUNT [--paid-on DATE] | --settle NO[,NO]] [--profile KEY] [--send-ksef]", &out)
+	customer := flags.String("customer", "default", "Customer key from customers.json (interactive mode)")
+	answersPath := flags.String("answers", "", "File with the answers to the interactive prompts, one per line, an empty line takes the default")
+	tui := flags.Bool("tui", false, "Edit the invoice in a full-screen terminal UI: customer picker, header, positions with live totals and a review before saving")
+	inputPath := flags.String("input", "", "Path to a JSON/YAML invoice spec, creates the invoice without prompts")
+	proforma := flags.Bool("proforma", false, "Issue a proforma in the proforma numbering series instead of a VAT invoice")
+	advanceFlag := flags.String("advance", "", "Issue an advance invoice for this received gross amount, positions describe the whole order")
+	paidOn := flags.String("paid-on", "", "Date the advance was received (DD-MM-YYYY), defaults to the date of issue")
+	settleFlag := flags.String("settle", "", "Issue the final invoice deducting these advance invoices (comma separated numbers)")
+	options := addIssueFlags(flags)
+
+	positional, code, ok := parseFlags(flags, args)
+	if !ok {
+		return code
+	}
+	if len(positional) > 0 {
+		return usageError(flags, "create takes no positional arguments")
+	}
+	if *proforma && options.sendKsef {
+		return usageError(flags, "a proforma is not a VAT invoice and cannot be sent to KSeF")
+	}
+	if countTrue(*tui, *inputPath != "", *answersPath != "") > 1 {
+		return usageError(flags, "--tui, --input and --answers exclude each other")
+	}
+	if countTrue(*proforma, *advanceFlag != "", *settleFlag != "") > 1 {
+		return usageError(flags, "--proforma, --advance and --settle exclude each other")
+	}
+
+	var advanceAmount Money.Money
+	if *advanceFlag != "" {
+		amount, err := Money.Parse(*advanceFlag, "")
+		if err != nil {
+			return usageError(flags, "--advance must be an amount, e.g. 1230.00")
+		}
+		advanceAmount = amount
+	}
+	if *paidOn != "" {
+		if _, err := TimeUtils.ParseDdMmYyyy(*paidOn); err != nil {
+			return usageError(flags, "--paid-on must be in DD-MM-YYYY format")
+		}
+	}
+
+	company, code := selectProfile(options.profile)
+	if code != EXIT_OK {
+		return code
+	}
+
+	var advances []InvoiceStore.StoredInvoice
+	if *settleFlag != "" {
+		for _, advanceNo := range strings.Split(*settleFlag, ",") {
+			advance, code := findInvoice(strings.TrimSpace(advanceNo), company.Profile)
+			if code != EXIT_OK {
+				return code
+			}
+			advances = append(advances, advance)
+		}
+	}
+
+	var invoice InvoiceManager.InvoiceCreatedData
+	var err error
+	if *inputPath != "" {
+		spec, loadErr := InvoiceSpec.Load(*inputPath)
+		if loadErr != nil {
+			fmt.Fprintln(os.Stderr, "Error loading input spec:", loadErr)
+			return EXIT_INVALID
+		}
+
+		invoice, err = InvoiceManager.CreateInvoiceFromSpec(spec)
+	} else if *tui {
+		if out.json {
+			return usageError(flags, "--json needs --input, the terminal UI would mix with the JSON output")
+		}
+
+		invoice, code = editInvoice(company, flagValue(flags, "customer"))
+		if code != EXIT_OK {
+			return code
+		}
+	} else {
+		if out.json {
+			return usageError(flags, "--json needs --input, interactive prompts would mix with the JSON output")
+		}
+
+		if code := useAnswers(*answersPath); code != EXIT_OK {
+			return code
+		}
+
+		fmt.Println("Moneybringer - let's make some money, baby! Prepare new invoice")
+		invoice, err = InvoiceManager.CreateInvoice(*customer)
+	}
+	if err != nil {
+		return failWith("Error creating invoice", err, EXIT_INVALID)
+	}
+
+	switch {
+	case *proforma:
+		invoice, err = InvoiceManager.AsProforma(invoice)
+		if err != nil {
+			return failWith("Error creating proforma", err, EXIT_ERROR)
+		}
+	case *advanceFlag != "":
+		invoice, code = asAdvance(invoice, *paidOn, advanceAmount)
+	case *settleFlag != "":
+		invoice, code = settleAdvances(invoice, advances)
+	}
+	if code != EXIT_OK {
+		return code
+	}
+
+	stored, code := issueInvoice(invoice, *options, out)
+	if code != EXIT_OK {
+		return code
+	}
+
+	if code := markAdvancesSettled(advances, stored.Invoice.InvoiceNo); code != EXIT_OK {
+		return code
+	}
+
+	out.result(stored.Invoice, func() {
+		fmt.Printf("%s %s issued\n", documentName(stored.Invoice), stored.Invoice.InvoiceNo)
+	})
+
+	return EXIT_OK
+}
+
+/* issueOptions are the flags shared by every command that issues a document */
+type issueOptions struct {
+	profile  string
+	sendKsef bool
+}
+
+func addIssueFlags(flags *flag.FlagSet) *issueOptions {
+	options := &issueOptions{}
+	flags.StringVar(&options.profile, "profile", "", "Seller profile from company.json (default: its defaultProfile, for stored documents the profile that issued them)")
+	flags.BoolVar(&options.sendKsef, "send-ksef", false, "Send the issued invoice to KSeF configured in config/ksef.json")
+
+	return options
+}
+
+/*
+issueInvoice converts foreign currency amounts to PLN, numbers and stores the invoice,
+exports the FA(2) XML (not for proformas), optionally sends it to KSeF and renders the PDF
+*/
+func issueInvoice(invoice InvoiceManager.InvoiceCreatedData, options issueOptions, out output) (InvoiceStore.StoredInvoice, int) {
+	if err := InvoiceManager.ApplyExchangeRate(&invoice, getRateProvider()); err != nil {
+		return InvoiceStore.StoredInvoice{}, fail("Error getting exchange rate", err)
+	}
+	if invoice.Conversion != nil {
+		rate := invoice.Conversion.Rate
+		out.printf("Exchange rate %s %s, NBP table %s of %s\n", rate.Currency, rate.Mid, rate.TableNo, rate.EffectiveDate)
+	}
+
+	repository, code := invoiceRepository()
+	if code != EXIT_OK {
+		return InvoiceStore.StoredInvoice{}, code
+	}
+
+	var stored InvoiceStore.StoredInvoice
+	err := InvoiceManager.IssueInvoice(&invoice, func(numbered InvoiceManager.InvoiceCreatedData) error {
+		var saveErr error
+		stored, saveErr = repository.Save(numbered)
+		return saveErr
+	})
+	if err != nil {
+		return stored, failWith("Error issuing invoice", err, EXIT_ERROR)
+	}
+	out.printf("Invoice %s successfully saved to %s\n", stored.Invoice.InvoiceNo, stored.Path)
+
+	if stored.Invoice.Kind() == InvoiceManager.DOCUMENT_KIND_PROFORMA {
+		if err := InvoiceGenerator.GenerateInvoicePDF(stored.Invoice, stored.PdfPath()); err != nil {
+			return stored, fail("Error generating PDF", err)
+		}
+		out.printf("Invoice PDF successfully saved to %s\n", stored.PdfPath())
+		return stored, EXIT_OK
+	}
+
+	if code := saveKsefXml(stored, out); code != EXIT_OK {
+		return stored, code
+	}
+
+	if options.sendKsef {
+		if code := sendToKsef(&stored, out); code != EXIT_OK {
+			return stored, code
+		}
+	}
+
+	if err := InvoiceGenerator.GenerateInvoicePDF(stored.Invoice, stored.PdfPath()); err != nil {
+		return stored, fail("Error generating PDF", err)
+	}
+	out.printf("Invoice PDF successfully saved to %s\n", stored.PdfPath())
+
+	return stored, EXIT_OK
+}
+
+func documentName(invoice InvoiceManager.InvoiceCreatedData) string {
+	switch invoice.Kind() {
+	case InvoiceManager.DOCUMENT_KIND_PROFORMA:
+		return "Proforma"
+	case InvoiceManager.DOCUMENT_KIND_CORRECTION:
+		return "Correction"
+	case InvoiceManager.DOCUMENT_KIND_ADVANCE:
+		return "Advance invoice"
+	case InvoiceManager.DOCUMENT_KIND_FINAL:
+		return "Final invoice"
+	}
+
+	return "Invoice"
+}
+
+func asAdvance(invoice InvoiceManager.InvoiceCreatedData, paidOn string, amount Money.Money) (InvoiceManager.InvoiceCreatedData, int) {
+	if paidOn == "" {
+		paidOn = invoice.DateOfIssue
+	}
+
+	advance, err := InvoiceManager.AsAdvance(invoice, paidOn, amount)
+	if err != nil {
+		return invoice, failWith("Error creating advance invoice", err, EXIT_INVALID)
+	}
+
+	return advance, EXIT_OK
+}
+
+func settleAdvances(invoice InvoiceManager.InvoiceCreatedData, storedAdvances []InvoiceStore.StoredInvoice) (InvoiceManager.InvoiceCreatedData, int) {
+	var advances []InvoiceManager.InvoiceCreatedData
+	for _, stored := range storedAdvances {
+		advances = append(advances, stored.Invoice)
+	}
+
+	final, err := InvoiceManager.SettleAdvances(invoice, advances)
+	if err != nil {
+		return invoice, failWith("Error creating final invoice", err, EXIT_INVALID)
+	}
+
+	return final, EXIT_OK
+}
+
+/* markAdvancesSettled links the advance invoices with the final invoice that deducted them */
+func markAdvancesSettled(advances []InvoiceStore.StoredInvoice, finalInvoiceNo string) int {
+	repository, code := invoiceRepository()
+	if code != EXIT_OK {
+		return code
+	}
+
+	for _, advance := range advances {
+		if err := repository.UpdateStatus(&advance, InvoiceStore.Status{SettledByInvoiceNo: finalInvoiceNo}); err != nil {
+			return fail("Error linking advance invoice "+advance.Invoice.InvoiceNo, err)
+		}
+	}
+
+	return EXIT_OK
+}
+
+func countTrue(values ...bool) int {
+	count := 0
+	for _, value := range values {
+		if value {
+			count++
+		}
+	}
+
+	return count
+}
+
+/* useAnswers replays the prompts of the interactive mode from a file instead of the terminal */
+func useAnswers(path string) int {
+	if path == "" {
+		return EXIT_OK
+	}
+
+	script, err := Prompt.LoadScript(path, os.Stdout)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Error loading answers:", err)
+		return EXIT_INVALID
+	}
+	Prompt.SetPrompter(script)
+
+	return EXIT_OK
+}
+
+/* editInvoice lets the user edit the invoice in the terminal UI and creates it from the edited spec, as --input does */
+func editInvoice(company CompanyData.Company, customerKey string) (InvoiceManager.InvoiceCreatedData, int) {
+	if !isatty.IsTerminal(os.Stdin.Fd()) || !isatty.IsTerminal(os.Stdout.Fd()) {
+		fmt.Fprintln(os.Stderr, "--tui needs a terminal, use --answers or --input to create invoices from a script")
+		return InvoiceManager.InvoiceCreatedData{}, EXIT_USAGE
+	}
+
+	customersData, err := CustomerData.LoadCustomers(CustomerData.CustomersJsonPath())
+	if err != nil {
+		return InvoiceManager.InvoiceCreatedData{}, failWith("Error reading customers", err, EXIT_INVALID)
+	}
+
+	spec, saved, err := Tui.EditInvoice(company, customersData, customerKey)
+	if err != nil {
+		return InvoiceManager.InvoiceCreatedData{}, failWith("Error in the terminal UI", err, EXIT_ERROR)
+	}
+	if !saved {
+		fmt.Fprintln(os.Stderr, "Invoice not saved")
+		return InvoiceManager.InvoiceCreatedData{}, EXIT_ERROR
+	}
+
+	invoice, err := InvoiceManager.CreateInvoiceFromSpec(spec)
+	if err != nil {
+		return invoice, failWith("Error creating invoice", err, EXIT_INVALID)
+	}
+
+	return invoice, EXIT_OK
+}
+
+func getRateProvider() ExchangeRate.Provider {
+	return ExchangeRate.NewNbpProvider(filepath.Join(AppPaths.InvoicesDir(), "exchange-rates"))
+}
@@ -0,0 +1,317 @@
+package Cli
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	CustomerData "moneybringer/invoice-manager/customer"
+	"os"
+	"strings"
+)
+
+const customersUsage = "Usage: moneybringer customers <list|show|add|edit|remove|migrate> [flags]"
+
+/* customerFields are the flags of customers add and edit, edit changes only the flags given */
+type customerFields struct {
+	fullName      *string
+	taxNumber     *string
+	vatEuNumber   *string
+	email         *string
+	contactPerson *string
+	streetAddress *string
+	number        *string
+	state         *string
+	zipCode       *string
+	city          *string
+	country       *string
+	countryCode   *string
+}
+
+func runCustomers(args []string) int {
+	if len(args) == 0 {
+		return runCustomersList(args)
+	}
+
+	switch args[0] {
+	case "list":
+		return runCustomersList(args[1:])
+	case "show":
+		return runCustomersShow(args[1:])
+	case "add":
+		return runCustomersAdd(args[1:])
+	case "edit":
+		return runCustomersEdit(args[1:])
+	case "remove":
+		return runCustomersRemove(args[1:])
+	case "migrate":
+		return runCustomersMigrate(args[1:])
+	case "-h", "--help":
+		fmt.Println(customersUsage)
+		return EXIT_OK
+	}
+
+	/* "customers KEY" from before the subcommands existed shows the customer */
+	return runCustomersShow(args)
+}
+
+func runCustomersList(args []string) int {
+	var out output
+	flags := newFlagSet("customers list", "customers list", &out)
+	if _, code, ok := parseFlags(flags, args); !ok {
+		return code
+	}
+
+	customersData, err := CustomerData.LoadCustomers(CustomerData.CustomersJsonPath())
+	if err != nil {
+		return fail("Error reading customers", err)
+	}
+
+	keys := sortedKeys(customersData.Customers)
+	out.result(customersData.Customers, func() {
+		for _, key := range keys {
+			fmt.Printf("%-20s %s\n", key, customersData.Customers[key].FullName)
+		}
+	})
+
+	return EXIT_OK
+}
+
+func runCustomersShow(args []string) int {
+	var out output
+	flags := newFlagSet("customers show", "customers show <customer-key>", &out)
+
+	positional, code, ok := parseFlags(flags, args)
+	if !ok {
+		return code
+	}
+
+	if len(positional) != 1 {
+		return usageError(flags, "customers show needs exactly one customer key")
+	}
+
+	customersData, err := CustomerData.LoadCustomers(CustomerData.CustomersJsonPath())
+	if err != nil {
+		return fail("Error reading customers", err)
+	}
+
+	customer, err := customersData.Find(positional[0])
+	if err != nil {
+		return customerError(err)
+	}
+
+	out.result(customer, func() {
+		fmt.Println(customer.FullName)
+		fmt.Println(strings.TrimSpace(customer.Address.StreetAddress + " " + customer.Address.Number))
+		fmt.Printf("%s %s\n", customer.Address.ZipCode, customer.Address.City)
+		if customer.Address.State != "" {
+			fmt.Println(customer.Address.State)
+		}
+		fmt.Println(strings.TrimSpace(customer.Address.Country + " (" + customer.Address.CountryCode + ")"))
+
+		for _, field := range [][2]string{
+			{"NIP", customer.TaxNumber},
+			{"VAT EU", customer.VatEuNumber},
+			{"Email", customer.Email},
+			{"Contact", customer.ContactPerson},
+		} {
+			if field[1] != "" {
+				fmt.Printf("%-8s %s\n", field[0]+":", field[1])
+			}
+		}
+	})
+
+	return EXIT_OK
+}
+
+func runCustomersAdd(args []string) int {
+	var out output
+	flags := newFlagSet("customers add", "customers add <customer-key> --full-name NAME --street ADDRESS --zip-code CODE --city CITY [--number NO] [--state STATE] [--country NAME] [--country-code CODE] [--tax-number NIP] [--vat-eu-number NO] [--email EMAIL] [--contact-person NAME]", &out)
+	fields := addCustomerFlags(flags)
+
+	positional, code, ok := parseFlags(flags, args)
+	if !ok {
+		return code
+	}
+
+	if len(positional) != 1 {
+		return usageError(flags, "customers add needs exactly one customer key")
+	}
+
+	customer := CustomerData.Customer{Address: CustomerData.Address{CountryCode: CustomerData.DEFAULT_COUNTRY_CODE}}
+	fields.apply(flags, &customer)
+
+	return updateCustomers(out, func(customersData CustomerData.CustomersData) error {
+		return customersData.Add(positional[0], customer)
+	}, fmt.Sprintf("Customer %s added", positional[0]))
+}
+
+func runCustomersEdit(args []string) int {
+	var out output
+	flags := newFlagSet("customers edit", "customers edit <customer-key> [customer flags as in customers add]", &out)
+	fields := addCustomerFlags(flags)
+
+	positional, code, ok := parseFlags(flags, args)
+	if !ok {
+		return code
+	}
+
+	if len(positional) != 1 {
+		return usageError(flags, "customers edit needs exactly one customer key")
+	}
+
+	if !fields.apply(flags, &CustomerData.Customer{}) {
+		return usageError(flags, "customers edit needs at least one field to change")
+	}
+
+	return updateCustomers(out, func(customersData CustomerData.CustomersData) error {
+		customer, err := customersData.Find(positional[0])
+		if err != nil {
+			return err
+		}
+
+		fields.apply(flags, &customer)
+
+		return customersData.Update(positional[0], customer)
+	}, fmt.Sprintf("Customer %s updated", positional[0]))
+}
+
+/* Removing a customer does not touch issued invoices, they keep their own copy of the buyer */
+func runCustomersRemove(args []string) int {
+	var out output
+	flags := newFlagSet("customers remove", "customers remove <customer-key>", &out)
+
+	positional, code, ok := parseFlags(flags, args)
+	if !ok {
+		return code
+	}
+
+	if len(positional) != 1 {
+		return usageError(flags, "customers remove needs exactly one customer key")
+	}
+
+	return updateCustomers(out, func(customersData CustomerData.CustomersData) error {
+		return customersData.Remove(positional[0])
+	}, fmt.Sprintf("Customer %s removed", positional[0]))
+}
+
+/* runCustomersMigrate rewrites customers.json written before country codes existed */
+func runCustomersMigrate(args []string) int {
+	var out output
+	flags := newFlagSet("customers migrate", "customers migrate", &out)
+	if _, code, ok := parseFlags(flags, args); !ok {
+		return code
+	}
+
+	migrated, err := CustomerData.MigrateFile(CustomerData.CustomersJsonPath())
+	if err != nil {
+		return fail("Error migrating customers", err)
+	}
+
+	out.result(map[string][]string{"migrated": migrated}, func() {
+		if len(migrated) == 0 {
+			fmt.Println("customers.json is up to date")
+			return
+		}
+		fmt.Printf("Migrated customers: %s\n", strings.Join(migrated, ", "))
+	})
+
+	return EXIT_OK
+}
+
+/* updateCustomers loads customers.json, applies change and writes the file back only when change succeeded */
+func updateCustomers(out output, change func(customersData CustomerData.CustomersData) error, message string) int {
+	path := CustomerData.CustomersJsonPath()
+
+	customersData, err := CustomerData.LoadCustomersOrEmpty(path)
+	if err != nil {
+		return fail("Error reading customers", err)
+	}
+
+	if err := change(customersData); err != nil {
+		return customerError(err)
+	}
+
+	if err := CustomerData.SaveCustomers(path, customersData); err != nil {
+		return fail("Error saving customers", err)
+	}
+
+	out.result(map[string]string{"message": message, "path": path}, func() {
+		fmt.Println(message)
+	})
+
+	return EXIT_OK
+}
+
+func customerError(err error) int {
+	fmt.Fprintln(os.Stderr, err)
+
+	switch {
+	case errors.Is(err, CustomerData.ErrCustomerNotFound):
+		return EXIT_NOT_FOUND
+	case errors.Is(err, CustomerData.ErrCustomerExists), errors.Is(err, CustomerData.ErrInvalidCustomer):
+		return EXIT_INVALID
+	}
+
+	return EXIT_ERROR
+}
+
+func addCustomerFlags(flags *flag.FlagSet) *customerFields {
+	return &customerFields{
+		fullName:      flags.String("full-name", "", "Full name of the customer"),
+		taxNumber:     flags.String("tax-number", "", "Tax number, the NIP of Polish customers"),
+		vatEuNumber:   flags.String("vat-eu-number", "", "EU VAT number with the country prefix, e.g. DE123456789"),
+		email:         flags.String("email", "", "Email address"),
+		contactPerson: flags.String("contact-person", "", "Contact person"),
+		streetAddress: flags.String("street", "", "Street"),
+		number:        flags.String("number", "", "Building and flat number"),
+		state:         flags.String("state", "", "State or voivodeship"),
+		zipCode:       flags.String("zip-code", "", "Postal code"),
+		city:          flags.String("city", "", "City"),
+		country:       flags.String("country", "", "Country name, sets --country-code when it is a known name"),
+		countryCode:   flags.String("country-code", "", "Two letter ISO country code, PL by default"),
+	}
+}
+
+/* apply copies the customer flags given on the command line to customer and reports whether there were any */
+func (f *customerFields) apply(flags *flag.FlagSet, customer *CustomerData.Customer) bool {
+	applied := false
+
+	flags.Visit(func(given *flag.Flag) {
+		switch given.Name {
+		case "full-name":
+			customer.FullName = *f.fullName
+		case "tax-number":
+			customer.TaxNumber = *f.taxNumber
+		case "vat-eu-number":
+			customer.VatEuNumber = *f.vatEuNumber
+		case "email":
+			customer.Email = *f.email
+		case "contact-person":
+			customer.ContactPerson = *f.contactPerson
+		case "street":
+			customer.Address.StreetAddress = *f.streetAddress
+		case "number":
+			customer.Address.Number = *f.number
+		case "state":
+			customer.Address.State = *f.state
+		case "zip-code":
+			customer.Address.ZipCode = *f.zipCode
+		case "city":
+			customer.Address.City = *f.city
+		case "country":
+			customer.Address.Country = *f.country
+			if code := CustomerData.CountryCodeOf(*f.country); code != "" {
+				customer.Address.CountryCode = code
+			}
+		case "country-code":
+			/* visited after "country" as flags come in lexical order, an explicit code wins */
+			customer.Address.CountryCode = *f.countryCode
+		default:
+			return
+		}
+
+		applied = true
+	})
+
+	return applied
+}
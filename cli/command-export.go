@@ -0,0 +1,284 @@
+package Cli
+
+import (
+	"errors"
+	"fmt"
+	InvoiceManager "moneybringer/invoice-manager"
+	CompanyData "moneybringer/invoice-manager/company"
+	InvoiceStore "moneybringer/invoice-store"
+	JpkExporter "moneybringer/jpk-exporter"
+	KsefClient "moneybringer/ksef-client"
+	KsefExporter "moneybringer/ksef-exporter"
+	AppPaths "moneybringer/utils/app-paths"
+	"os"
+	"path/filepath"
+)
+
+func runExport(args []string) int {
+	if len(args) == 0 {
+		fmt.Fprintln(os.Stderr, "Usage: moneybringer export <ksef|jpk> [flags]")
+		return EXIT_USAGE
+	}
+
+	switch args[0] {
+	case "ksef":
+		return runExportKsef(args[1:])
+	case "jpk":
+		return runJpk(args[1:])
+	case "-h", "--help":
+		fmt.Println("Usage: moneybringer export <ksef|jpk> [flags]")
+		return EXIT_OK
+	}
+
+	fmt.Fprintf(os.Stderr, "Unknown export format %q, use ksef or jpk\n", args[0])
+	return EXIT_USAGE
+}
+
+func runExportKsef(args []string) int {
+	var out output
+	flags := newFlagSet("export ksef", "export ksef <invoice-no> [--profile KEY] [--send] | export ksef --validate file.xml", &out)
+	send := flags.Bool("send", false, "Send the FA(2) XML to KSeF and record the KSeF number")
+	validatePath := flags.String("validate", "", "Only validate an FA(2) XML file offline against the bundled official schema (needs xmllint)")
+	profile := addProfileFlag(flags, "Seller profile that issued the invoice, needed when several profiles use the same number")
+
+	positional, code, ok := parseFlags(flags, args)
+	if !ok {
+		return code
+	}
+
+	if *validatePath != "" {
+		return validateKsefXml(*validatePath, out)
+	}
+
+	if len(positional) != 1 {
+		return usageError(flags, "export ksef needs exactly one invoice number")
+	}
+
+	stored, code := findInvoice(positional[0], *profile)
+	if code != EXIT_OK {
+		return code
+	}
+
+	/* the XML sent to KSeF is the invoice, it is never regenerated after sending and sent as it was issued */
+	if stored.Invoice.KsefReferenceNumber != "" || *send {
+		if code := keepKsefXml(stored, out); code != EXIT_OK {
+			return code
+		}
+	} else if code := saveKsefXml(stored, out); code != EXIT_OK {
+		return code
+	}
+
+	if *send {
+		if code := sendToKsef(&stored, out); code != EXIT_OK {
+			return code
+		}
+	}
+
+	out.result(map[string]string{"invoiceNo": stored.Invoice.InvoiceNo, "xml": stored.FilePath("xml"), "ksefReferenceNumber": stored.Invoice.KsefReferenceNumber}, func() {})
+
+	return EXIT_OK
+}
+
+func saveKsefXml(stored InvoiceStore.StoredInvoice, out output) int {
+	filePath := stored.FilePath("xml")
+
+	if err := KsefExporter.SaveFA2(stored.Invoice, filePath); err != nil {
+		return fail("Error exporting KSeF FA(2) XML", err)
+	}
+
+	out.printf("KSeF FA(2) XML successfully saved to %s\n", filePath)
+
+	return EXIT_OK
+}
+
+/* keepKsefXml leaves the FA(2) XML saved when the invoice was issued as it is, it is only created when missing */
+func keepKsefXml(stored InvoiceStore.StoredInvoice, out output) int {
+	filePath := stored.FilePath("xml")
+
+	_, err := os.Stat(filePath)
+	if err == nil {
+		out.printf("Using the KSeF FA(2) XML saved in %s\n", filePath)
+		return EXIT_OK
+	}
+	if !os.IsNotExist(err) {
+		return fail("Error reading KSeF FA(2) XML", err)
+	}
+
+	if stored.Invoice.KsefReferenceNumber != "" {
+		fmt.Fprintf(os.Stderr, "The FA(2) XML of invoice %s sent to KSeF with number %s is missing: %s\n", stored.Invoice.InvoiceNo, stored.Invoice.KsefReferenceNumber, filePath)
+		return EXIT_NOT_FOUND
+	}
+
+	return saveKsefXml(stored, out)
+}
+
+/* sendToKsef uploads the stored FA(2) XML, records the KSeF number in the raw invoice and saves the UPO */
+func sendToKsef(stored *InvoiceStore.StoredInvoice, out output) int {
+	if stored.Invoice.KsefReferenceNumber != "" {
+		fmt.Fprintf(os.Stderr, "Invoice %s was already sent to KSeF with number %s\n", stored.Invoice.InvoiceNo, stored.Invoice.KsefReferenceNumber)
+		return EXIT_INVALID
+	}
+
+	client, err := getKsefClient()
+	if err != nil {
+		return fail("Error configuring KSeF client", err)
+	}
+
+	xmlData, err := os.ReadFile(stored.FilePath("xml"))
+	if err != nil {
+		return fail("Error reading KSeF FA(2) XML", err)
+	}
+
+	if err := client.InitSession(KsefExporter.SellerNip(stored.Invoice)); err != nil {
+		return fail("Error opening KSeF session", err)
+	}
+	/* a rejected invoice or a failed step must not leave the session open, after the regular close there is nothing to terminate */
+	defer func() {
+		if err := client.TerminateSession(); err != nil && !errors.Is(err, KsefClient.ErrNoSession) {
+			fmt.Fprintln(os.Stderr, "Error closing KSeF session:", err)
+		}
+	}()
+
+	elementReference, err := client.SendInvoice(xmlData)
+	if err != nil {
+		return fail("Error sending invoice to KSeF", err)
+	}
+
+	status, err := client.WaitForInvoice(elementReference)
+	if err != nil {
+		return fail("Error waiting for KSeF", err)
+	}
+
+	out.printf("Invoice accepted by KSeF with number %s\n", status.Invoice.KsefReferenceNumber)
+
+	repository, code := invoiceRepository()
+	if code != EXIT_OK {
+		return code
+	}
+	if err := repository.UpdateStatus(stored, InvoiceStore.Status{KsefReferenceNumber: status.Invoice.KsefReferenceNumber}); err != nil {
+		return fail("Error saving KSeF number", err)
+	}
+
+	if err := client.TerminateSession(); err != nil {
+		return fail("Error closing KSeF session", err)
+	}
+
+	upo, err := client.DownloadUpo(client.SessionReference())
+	if err != nil {
+		return fail("Error downloading UPO", err)
+	}
+
+	upoPath := stored.FilePath("upo.xml")
+	if err := os.WriteFile(upoPath, upo, 0644); err != nil {
+		return fail("Error saving UPO", err)
+	}
+
+	out.printf("UPO successfully saved to %s\n", upoPath)
+
+	return EXIT_OK
+}
+
+func getKsefClient() (*KsefClient.Client, error) {
+	config, err := KsefClient.LoadConfig(KsefClient.KsefConfigJsonPath())
+	if err != nil {
+		return nil, err
+	}
+
+	return KsefClient.NewFromConfig(config)
+}
+
+/* validateKsefXml checks a file offline against the bundled official FA(2) schema */
+func validateKsefXml(filePath string, out output) int {
+	xmlData, err := os.ReadFile(filePath)
+	if err != nil {
+		return fail("Error reading file", err)
+	}
+
+	err = KsefExporter.Validate(xmlData)
+
+	var validationErr *KsefExporter.ValidationError
+	if errors.As(err, &validationErr) {
+		out.result(map[string]interface{}{"file": filePath, "valid": false, "problems": validationErr.Problems}, func() {
+			fmt.Println(validationErr)
+		})
+		return EXIT_INVALID
+	}
+	if err != nil {
+		return fail("Error validating FA(2) XML", err)
+	}
+
+	out.result(map[string]interface{}{"file": filePath, "valid": true}, func() {
+		fmt.Printf("%s is valid against the official FA(2) schema\n", filePath)
+	})
+
+	return EXIT_OK
+}
+
+func runJpk(args []string) int {
+	var out output
+	flags := newFlagSet("jpk", "jpk --month YYYY-MM [--profile KEY]", &out)
+	monthFlag := flags.String("month", "", "Settlement month in YYYY-MM format, e.g. 2026-09")
+	profile := addProfileFlag(flags, "Seller profile from company.json (default: its defaultProfile)")
+
+	if _, code, ok := parseFlags(flags, args); !ok {
+		return code
+	}
+
+	month, err := parseMonth(*monthFlag)
+	if err != nil {
+		return usageError(flags, "--month must be in YYYY-MM format")
+	}
+
+	/* JPK is filed per taxpayer, only invoices of one seller profile go into a file */
+	company, code := selectProfile(*profile)
+	if code != EXIT_OK {
+		return code
+	}
+
+	repository, code := invoiceRepository()
+	if code != EXIT_OK {
+		return code
+	}
+
+	storedInvoices, err := repository.List(InvoiceStore.Filter{Year: month.Year(), Month: month.Month(), Profile: company.Profile})
+	if err != nil {
+		return fail("Error reading stored invoices", err)
+	}
+
+	var invoices []InvoiceManager.InvoiceCreatedData
+	for _, stored := range storedInvoices {
+		invoices = append(invoices, stored.Invoice)
+	}
+
+	xmlData, control, err := JpkExporter.GenerateV7M(invoices, company, month.Year(), month.Month())
+	if err != nil {
+		return fail("Error generating JPK_V7M", err)
+	}
+
+	dirPath := filepath.Join(AppPaths.InvoicesDir(), "jpk")
+	if err := os.MkdirAll(dirPath, os.ModePerm); err != nil {
+		return fail("Error creating directories", err)
+	}
+
+	fileName := fmt.Sprintf("JPK_V7M_%s.xml", month.Format("2006-01"))
+	if company.Profile != CompanyData.DEFAULT_PROFILE {
+		fileName = fmt.Sprintf("JPK_V7M_%s_%s.xml", month.Format("2006-01"), company.Profile)
+	}
+	filePath := filepath.Join(dirPath, fileName)
+	if err := os.WriteFile(filePath, xmlData, 0644); err != nil {
+		return fail("Error writing JPK file", err)
+	}
+
+	result := map[string]interface{}{
+		"file":      filePath,
+		"salesRows": control.LiczbaWierszySprzedazy,
+		"outputVat": control.PodatekNalezny.String(),
+		"currency":  "PLN",
+	}
+	out.result(result, func() {
+		fmt.Printf("JPK_V7M saved to %s\n", filePath)
+		fmt.Printf("Sales rows: %d, output VAT: %s PLN\n", control.LiczbaWierszySprzedazy, control.PodatekNalezny)
+	})
+
+	return EXIT_OK
+}
@@ -0,0 +1,221 @@
+package Cli
+
+import (
+	"errors"
+	"fmt"
+	InvoiceGenerator "moneybringer/invoice-generator"
+	InvoiceManager "moneybringer/invoice-manager"
+	InvoiceStore "moneybringer/invoice-store"
+	"os"
+	"time"
+)
+
+type invoiceListItem struct {
+	DocumentKind        string `json:"documentKind"`
+	Profile             string `json:"profile"`
+	InvoiceNo           string `json:"invoiceNo"`
+	DateOfIssue         string `json:"dateOfIssue"`
+	Customer            string `json:"customer"`
+	TotalGrossValue     string `json:"totalGrossValue"`
+	Currency            string `json:"currency"`
+	KsefReferenceNumber string `json:"ksefReferenceNumber,omitempty"`
+	Path                string `json:"path"`
+}
+
+func runList(args []string) int {
+	var out output
+	flags := newFlagSet("list", "list [--month YYYY-MM] [--customer NAME] [--profile KEY]", &out)
+	monthFlag := flags.String("month", "", "Only invoices issued in this month (YYYY-MM)")
+	customerFlag := flags.String("customer", "", "Only invoices whose buyer name contains this text")
+	profile := addProfileFlag(flags, "Only invoices issued by this seller profile")
+
+	if _, code, ok := parseFlags(flags, args); !ok {
+		return code
+	}
+
+	filter := InvoiceStore.Filter{Profile: *profile, Customer: *customerFlag}
+	if *monthFlag != "" {
+		month, err := parseMonth(*monthFlag)
+		if err != nil {
+			return usageError(flags, "--month must be in YYYY-MM format")
+		}
+		filter.Year, filter.Month = month.Year(), month.Month()
+	}
+
+	repository, code := invoiceRepository()
+	if code != EXIT_OK {
+		return code
+	}
+
+	storedInvoices, err := repository.List(filter)
+	if err != nil {
+		return fail("Error reading stored invoices", err)
+	}
+
+	items := []invoiceListItem{}
+	for _, stored := range storedInvoices {
+		invoice := stored.Invoice
+		items = append(items, invoiceListItem{
+			DocumentKind:        invoice.Kind(),
+			Profile:             invoice.SellerProfile(),
+			InvoiceNo:           invoice.InvoiceNo,
+			DateOfIssue:         invoice.DateOfIssue,
+			Customer:            invoice.InvoiceTo.FullName,
+			TotalGrossValue:     invoice.InvoiceSummary.TotalGrossValue.String(),
+			Currency:            InvoiceManager.CurrencyOf(invoice),
+			KsefReferenceNumber: invoice.KsefReferenceNumber,
+			Path:                stored.Path,
+		})
+	}
+
+	out.result(items, func() {
+		fmt.Printf("%-16s %-11s %-10s %-30s %14s %-4s %s\n", "NUMBER", "ISSUED", "PROFILE", "CUSTOMER", "GROSS", "CUR", "KSEF")
+		for _, item := range items {
+			fmt.Printf("%-16s %-11s %-10s %-30s %14s %-4s %s\n", item.InvoiceNo, item.DateOfIssue, item.Profile, item.Customer, item.TotalGrossValue, item.Currency, item.KsefReferenceNumber)
+		}
+	})
+
+	return EXIT_OK
+}
+
+func runShow(args []string) int {
+	var out output
+	flags := newFlagSet("show", "show <invoice-no> [--profile KEY]", &out)
+	profile := addProfileFlag(flags, "Seller profile that issued the invoice, needed when several profiles use the same number")
+
+	positional, code, ok := parseFlags(flags, args)
+	if !ok {
+		return code
+	}
+	if len(positional) != 1 {
+		return usageError(flags, "show needs exactly one invoice number")
+	}
+
+	stored, code := findInvoice(positional[0], *profile)
+	if code != EXIT_OK {
+		return code
+	}
+
+	invoice := stored.Invoice
+	out.result(invoice, func() {
+		fmt.Printf("%-15s %s\n", documentName(invoice)+":", invoice.InvoiceNo)
+		fmt.Printf("Date of issue:  %s, %s\n", invoice.DateOfIssue, invoice.PlaceOfIssue)
+		fmt.Printf("Service period: %s - %s\n", invoice.ServiceStartDate, invoice.ServiceEndDate)
+		fmt.Printf("Seller:         %s (%s), profile %s\n", invoice.InvoiceFrom.FullName, invoice.InvoiceFrom.TaxNumber, invoice.SellerProfile())
+		fmt.Printf("Buyer:          %s\n", buyerName(invoice.InvoiceTo))
+		fmt.Printf("Payment:        %s, due %s\n", invoice.Payment.Method, invoice.Payment.Deadline)
+		if invoice.KsefReferenceNumber != "" {
+			fmt.Printf("KSeF number:    %s\n", invoice.KsefReferenceNumber)
+		}
+		if invoice.ProformaNo != "" {
+			fmt.Printf("Proforma:       %s\n", invoice.ProformaNo)
+		}
+		if invoice.ConvertedToInvoiceNo != "" {
+			fmt.Printf("Converted into: %s\n", invoice.ConvertedToInvoiceNo)
+		}
+		if invoice.Advance != nil {
+			fmt.Printf("Advance:        %s %s received %s, order %s\n", invoice.Advance.ReceivedAmount, invoice.Advance.ReceivedAmount.Currency, invoice.Advance.PaymentDate, invoice.Advance.OrderSummary.TotalGrossValue)
+			if invoice.Advance.SettledByInvoiceNo != "" {
+				fmt.Printf("Settled by:     %s\n", invoice.Advance.SettledByInvoiceNo)
+			}
+		}
+		if invoice.Settlement != nil {
+			for _, advance := range invoice.Settlement.Advances {
+				fmt.Printf("Deducts:        %s of %s, gross %s\n", advance.InvoiceNo, advance.DateOfIssue, advance.Summary.TotalGrossValue)
+			}
+		}
+		if invoice.Correction != nil {
+			fmt.Printf("Corrects:       %s of %s (%s)\n", invoice.Correction.OriginalInvoiceNo, invoice.Correction.OriginalDateOfIssue, invoice.Correction.Reason)
+		}
+		fmt.Println()
+		for _, position := range invoice.InvoicePositions {
+			fmt.Printf("%3d. %-30s %5d x %10s  %-4s %10s net %10s gross\n", position.ItemNo, position.ProductOrServiceName, position.Quantity, position.NetPrice, position.TaxRate.Label(), position.NetValue, position.GrossValue)
+		}
+		fmt.Println()
+		summary := invoice.InvoiceSummary
+		fmt.Printf("Net %s, VAT %s, gross %s %s\n", summary.TotalAmount, summary.TotalTaxAmount, summary.TotalGrossValue, summary.TotalGrossValue.Currency)
+		if conversion := invoice.Conversion; conversion != nil {
+			fmt.Printf("In PLN: net %s, VAT %s, gross %s at 1 %s = %s PLN (NBP table %s of %s)\n", conversion.Summary.TotalAmount, conversion.Summary.TotalTaxAmount, conversion.Summary.TotalGrossValue, conversion.Rate.Currency, conversion.Rate.Mid, conversion.Rate.TableNo, conversion.Rate.EffectiveDate)
+		}
+		fmt.Printf("Stored in %s\n", stored.Path)
+	})
+
+	return EXIT_OK
+}
+
+func runRender(args []string) int {
+	var out output
+	flags := newFlagSet("render", "render <invoice-no> [--output file.pdf] [--profile KEY]", &out)
+	outputPath := flags.String("output", "", "PDF path, defaults to the invoice's month folder")
+	profile := addProfileFlag(flags, "Seller profile that issued the invoice, needed when several profiles use the same number")
+
+	positional, code, ok := parseFlags(flags, args)
+	if !ok {
+		return code
+	}
+	if len(positional) != 1 {
+		return usageError(flags, "render needs exactly one invoice number")
+	}
+
+	stored, code := findInvoice(positional[0], *profile)
+	if code != EXIT_OK {
+		return code
+	}
+
+	pdfPath := stored.PdfPath()
+	if *outputPath != "" {
+		pdfPath = *outputPath
+	}
+
+	if err := InvoiceGenerator.GenerateInvoicePDF(stored.Invoice, pdfPath); err != nil {
+		if errors.Is(err, InvoiceManager.ErrMixedCurrency) {
+			fmt.Fprintln(os.Stderr, "Error generating PDF:", err)
+			return EXIT_INVALID
+		}
+		return fail("Error generating PDF", err)
+	}
+
+	out.result(map[string]string{"invoiceNo": stored.Invoice.InvoiceNo, "pdf": pdfPath}, func() {
+		fmt.Printf("PDF of %s saved to %s\n", stored.Invoice.InvoiceNo, pdfPath)
+	})
+
+	return EXIT_OK
+}
+
+/* findInvoice searches invoices of profile, an empty profile searches every profile */
+func findInvoice(invoiceNo string, profile string) (InvoiceStore.StoredInvoice, int) {
+	repository, code := invoiceRepository()
+	if code != EXIT_OK {
+		return InvoiceStore.StoredInvoice{}, code
+	}
+
+	stored, err := repository.Get(invoiceNo, profile)
+	if errors.Is(err, InvoiceStore.ErrInvoiceNotFound) {
+		fmt.Fprintln(os.Stderr, err)
+		return stored, EXIT_NOT_FOUND
+	}
+	if errors.Is(err, InvoiceStore.ErrAmbiguousInvoice) {
+		fmt.Fprintln(os.Stderr, err)
+		return stored, EXIT_USAGE
+	}
+	if err != nil {
+		return stored, fail("Error reading stored invoices", err)
+	}
+
+	return stored, EXIT_OK
+}
+
+func parseMonth(value string) (time.Time, error) {
+	return time.Parse("2006-01", value)
+}
+
+func buyerName(buyer InvoiceManager.InvoiceTo) string {
+	switch {
+	case buyer.TaxNumber != "":
+		return fmt.Sprintf("%s (%s)", buyer.FullName, buyer.TaxNumber)
+	case buyer.VatEuNumber != "":
+		return fmt.Sprintf("%s (%s)", buyer.FullName, buyer.VatEuNumber)
+	}
+
+	return buyer.FullName
+}
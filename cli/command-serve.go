@@ -0,0 +1,90 @@
+package Cli
+
+import (
+	"fmt"
+	WebServer "moneybringer/web-server"
+	"net"
+	"net/http"
+	"os"
+)
+
+/* runServe serves the JSON API and the web front-end until the process is stopped */
+func runServe(args []string) int {
+	flags := newFlagSet("serve", "serve [--addr HOST:PORT] [--allow-host HOST:PORT]... [--profile KEY]", nil)
+	addr := flags.String("addr", "127.0.0.1:8080", "Address to listen on, anything but localhost exposes invoices to the network")
+	var extraHosts []string
+	flags.Func("allow-host", "Another HOST:PORT browsers reach the server by, e.g. a host name with --addr 0.0.0.0:8080 (repeatable)", func(value string) error {
+		if _, _, err := net.SplitHostPort(value); err != nil {
+			return fmt.Errorf("must be HOST:PORT: %w", err)
+		}
+		extraHosts = append(extraHosts, value)
+		return nil
+	})
+	profile := addProfileFlag(flags, "Seller profile from company.json used for new invoices (default: the default profile)")
+
+	positional, code, ok := parseFlags(flags, args)
+	if !ok {
+		return code
+	}
+	if len(positional) != 0 {
+		return usageError(flags, "serve takes no arguments")
+	}
+
+	if _, code := selectProfile(*profile); code != EXIT_OK {
+		return code
+	}
+
+	host, _, err := net.SplitHostPort(*addr)
+	if err != nil {
+		return usageError(flags, "--addr must be HOST:PORT, e.g. 127.0.0.1:8080")
+	}
+	if !isLoopback(host) {
+		fmt.Fprintf(os.Stderr, "Warning: listening on %s, the API has no authentication and anyone who reaches it can issue invoices\n", *addr)
+	}
+
+	repository, code := invoiceRepository()
+	if code != EXIT_OK {
+		return code
+	}
+
+	listener, err := net.Listen("tcp", *addr)
+	if err != nil {
+		return fail("Error starting server", err)
+	}
+
+	fmt.Printf("Serving invoices on http://%s/ (Ctrl+C to stop)\n", listener.Addr())
+
+	server := WebServer.New(getRateProvider(), repository, append(allowedHosts(host, listener.Addr()), extraHosts...))
+	if err := http.Serve(listener, server.Handler()); err != nil {
+		return fail("Error serving", err)
+	}
+
+	return EXIT_OK
+}
+
+/*
+allowedHosts are the Host values of requests to the listener: the address it listens on and,
+for localhost or all interfaces, the loopback names a browser on this machine uses
+*/
+func allowedHosts(host string, listenAddr net.Addr) []string {
+	_, port, _ := net.SplitHostPort(listenAddr.String())
+	hosts := []string{listenAddr.String(), net.JoinHostPort(host, port)}
+
+	ip := net.ParseIP(host)
+	if isLoopback(host) || host == "" || (ip != nil && ip.IsUnspecified()) {
+		for _, name := range []string{"localhost", "127.0.0.1", "::1"} {
+			hosts = append(hosts, net.JoinHostPort(name, port))
+		}
+	}
+
+	return hosts
+}
+
+func isLoopback(host string) bool {
+	if host == "localhost" {
+		return true
+	}
+
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
@@ -0,0 +1,195 @@
+package ExchangeRate
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	Money "moneybringer/utils/money"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+/* Rate is an NBP average (mid) rate, Mid keeps the published digits, e.g. "4.2512" PLN for 1 unit */
+type Rate struct {
+	Currency      string
+	Mid           string
+	TableNo       string
+	EffectiveDate string
+}
+
+/*
+Provider returns the rate from the last working day before date, as required
+for VAT in foreign currency invoices (art. 31a VAT act).
+*/
+type Provider interface {
+	RateBefore(currency string, date time.Time) (Rate, error)
+}
+
+const NBP_BASE_URL = "https://api.nbp.pl"
+
+/* NBP publishes no table on weekends and holidays, the lookback covers the longest break */
+const LOOKBACK_DAYS = 10
+
+const midScale = 1000000
+
+var ErrRateNotFound = errors.New("exchange rate not found")
+
+var ErrCurrencyMismatch = errors.New("amount is not in the currency of the exchange rate")
+
+var midRegexp = regexp.MustCompile(`^\d+(\.\d{1,6})?$`)
+
+/* ToPLN converts an amount in the rate currency into PLN, rounded half away from zero to full grosze */
+func (r Rate) ToPLN(amount Money.Money) (Money.Money, error) {
+	if amount.Currency != r.Currency {
+		return Money.Money{}, fmt.Errorf("%w: %s %s with the %s rate", ErrCurrencyMismatch, amount, amount.Currency, r.Currency)
+	}
+
+	mid, err := parseMid(r.Mid)
+	if err != nil {
+		return Money.Money{}, err
+	}
+
+	converted := amount.MulRatio(mid, midScale)
+	converted.Currency = "PLN"
+
+	return converted, nil
+}
+
+func parseMid(mid string) (int64, error) {
+	if !midRegexp.MatchString(mid) {
+		return 0, fmt.Errorf("invalid exchange rate %q", mid)
+	}
+
+	whole, fraction, _ := strings.Cut(mid, ".")
+	fraction += strings.Repeat("0", 6-len(fraction))
+
+	wholeValue, err := strconv.ParseInt(whole, 10, 64)
+	if err != nil {
+		return 0, err
+	}
+	fractionValue, err := strconv.ParseInt(fraction, 10, 64)
+	if err != nil {
+		return 0, err
+	}
+
+	return wholeValue*midScale + fractionValue, nil
+}
+
+/* NbpProvider reads table A from the NBP web API and keeps every rate it got in CacheDir */
+type NbpProvider struct {
+	BaseURL    string
+	CacheDir   string
+	HttpClient *http.Client
+}
+
+type nbpResponse struct {
+	Code  string `json:"code"`
+	Rates []struct {
+		No            string      `json:"no"`
+		EffectiveDate string      `json:"effectiveDate"`
+		Mid           json.Number `json:"mid"`
+	} `json:"rates"`
+}
+
+func NewNbpProvider(cacheDir string) *NbpProvider {
+	return &NbpProvider{
+		BaseURL:    NBP_BASE_URL,
+		CacheDir:   cacheDir,
+		HttpClient: &http.Client{Timeout: 15 * time.Second},
+	}
+}
+
+func (p *NbpProvider) RateBefore(currency string, date time.Time) (Rate, error) {
+	currency = strings.ToUpper(currency)
+	cachePath := filepath.Join(p.CacheDir, fmt.Sprintf("%s_%s.json", currency, date.Format("2006-01-02")))
+
+	if rate, err := readCachedRate(cachePath); err == nil {
+		return rate, nil
+	}
+
+	rate, err := p.fetch(currency, date)
+	if err != nil {
+		return rate, err
+	}
+
+	/* published rates never change, a failed cache write only costs another request */
+	writeCachedRate(cachePath, rate)
+
+	return rate, nil
+}
+
+func (p *NbpProvider) fetch(currency string, date time.Time) (Rate, error) {
+	end := date.AddDate(0, 0, -1)
+	start := end.AddDate(0, 0, -LOOKBACK_DAYS)
+	url := fmt.Sprintf("%s/api/exchangerates/rates/a/%s/%s/%s/?format=json", strings.TrimSuffix(p.BaseURL, "/"), strings.ToLower(currency), start.Format("2006-01-02"), end.Format("2006-01-02"))
+
+	response, err := p.HttpClient.Get(url)
+	if err != nil {
+		return Rate{}, fmt.Errorf("NBP request failed: %w", err)
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode == http.StatusNotFound {
+		return Rate{}, fmt.Errorf("%w: no NBP table A rate for %s before %s", ErrRateNotFound, currency, date.Format("2006-01-02"))
+	}
+	if response.StatusCode != http.StatusOK {
+		return Rate{}, fmt.Errorf("NBP responded with %s", response.Status)
+	}
+
+	var body nbpResponse
+	decoder := json.NewDecoder(response.Body)
+	decoder.UseNumber()
+	if err := decoder.Decode(&body); err != nil {
+		return Rate{}, fmt.Errorf("cannot parse NBP response: %w", err)
+	}
+
+	if len(body.Rates) == 0 {
+		return Rate{}, fmt.Errorf("%w: no NBP table A rate for %s before %s", ErrRateNotFound, currency, date.Format("2006-01-02"))
+	}
+
+	/* rates come ordered by date, the last one is the working day before date */
+	last := body.Rates[len(body.Rates)-1]
+	rate := Rate{Currency: currency, Mid: last.Mid.String(), TableNo: last.No, EffectiveDate: last.EffectiveDate}
+
+	if _, err := parseMid(rate.Mid); err != nil {
+		return Rate{}, err
+	}
+
+	return rate, nil
+}
+
+func readCachedRate(path string) (Rate, error) {
+	var rate Rate
+
+	jsonData, err := os.ReadFile(path)
+	if err != nil {
+		return rate, err
+	}
+
+	err = json.Unmarshal(jsonData, &rate)
+
+	return rate, err
+}
+
+func writeCachedRate(path string, rate Rate) error {
+	if err := os.MkdirAll(filepath.Dir(path), os.ModePerm); err != nil {
+		return err
+	}
+
+	jsonData, err := json.MarshalIndent(rate, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tempPath := path + ".tmp"
+	if err := os.WriteFile(tempPath, jsonData, 0644); err != nil {
+		return err
+	}
+
+	return os.Rename(tempPath, path)
+}
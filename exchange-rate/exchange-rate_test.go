@@ -0,0 +1,196 @@
+package ExchangeRate_test
+
+import (
+	"encoding/json"
+	"errors"
+	ExchangeRate "moneybringer/exchange-rate"
+	Money "moneybringer/utils/money"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestToPLN(t *testing.T) {
+	cases := []struct {
+		mid      string
+		amount   int64
+		expected int64
+	}{
+		{"4.3000", 10000, 43000},     // 100.00 EUR
+		{"4.2512", 12, 51},           // 0.510144 rounded down
+		{"4.2500", 50, 213},          // 2.125 rounded half up
+		{"4.2500", -50, -213},        // half away from zero for corrections
+		{"4.2500", 1, 4},             // 0.0425
+		{"1.123456", 100000, 112346}, // all 6 published digits count
+		{"4", 12345, 49380},
+		{"0.0275", 1000000, 27500}, // JPY and HUF are quoted for 1 unit too
+	}
+
+	for _, c := range cases {
+		rate := ExchangeRate.Rate{Currency: "EUR", Mid: c.mid}
+
+		converted, err := rate.ToPLN(Money.New(c.amount, "EUR"))
+		if err != nil {
+			t.Errorf("%d at %s: %v", c.amount, c.mid, err)
+			continue
+		}
+		if converted.Amount != c.expected || converted.Currency != "PLN" {
+			t.Errorf("%d EUR at %s = %d %s, want %d PLN", c.amount, c.mid, converted.Amount, converted.Currency, c.expected)
+		}
+	}
+}
+
+func TestToPLNRefusesOtherCurrencies(t *testing.T) {
+	rate := ExchangeRate.Rate{Currency: "EUR", Mid: "4.3000"}
+
+	for _, currency := range []string{"USD", "PLN", ""} {
+		if _, err := rate.ToPLN(Money.New(100, currency)); !errors.Is(err, ExchangeRate.ErrCurrencyMismatch) {
+			t.Errorf("ToPLN of an amount in %q = %v, want ErrCurrencyMismatch", currency, err)
+		}
+	}
+}
+
+func TestToPLNRefusesInvalidRates(t *testing.T) {
+	for _, mid := range []string{"", "4,30", "-4.3", "4.3000001", "abc"} {
+		rate := ExchangeRate.Rate{Currency: "EUR", Mid: mid}
+		if _, err := rate.ToPLN(Money.New(100, "EUR")); err == nil {
+			t.Errorf("rate %q was accepted", mid)
+		}
+	}
+}
+
+/* nbpServer answers like the NBP API with the EUR table A rates published on the given days */
+func nbpServer(t *testing.T, published map[string]string) (*httptest.Server, *int) {
+	t.Helper()
+
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+
+		/* /api/exchangerates/rates/a/eur/2026-10-01/2026-10-11/ */
+		parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+		if len(parts) != 7 || parts[3] != "a" || parts[4] != "eur" || r.URL.Query().Get("format") != "json" {
+			http.NotFound(w, r)
+			return
+		}
+		start, startErr := time.Parse("2006-01-02", parts[5])
+		end, endErr := time.Parse("2006-01-02", parts[6])
+		if startErr != nil || endErr != nil {
+			http.Error(w, "400 BadRequest", http.StatusBadRequest)
+			return
+		}
+
+		type nbpRate struct {
+			No            string      `json:"no"`
+			EffectiveDate string      `json:"effectiveDate"`
+			Mid           json.Number `json:"mid"`
+		}
+		var rates []nbpRate
+		for day := start; !day.After(end); day = day.AddDate(0, 0, 1) {
+			if mid, exists := published[day.Format("2006-01-02")]; exists {
+				rates = append(rates, nbpRate{No: day.Format("002") + "/A/NBP/" + day.Format("2006"), EffectiveDate: day.Format("2006-01-02"), Mid: json.Number(mid)})
+			}
+		}
+		if len(rates) == 0 {
+			http.Error(w, "404 NotFound - Not Found - Brak danych", http.StatusNotFound)
+			return
+		}
+
+		json.NewEncoder(w).Encode(map[string]interface{}{"table": "A", "currency": "euro", "code": "EUR", "rates": rates})
+	}))
+	t.Cleanup(server.Close)
+
+	return server, &requests
+}
+
+func newProvider(t *testing.T, server *httptest.Server) *ExchangeRate.NbpProvider {
+	t.Helper()
+
+	provider := ExchangeRate.NewNbpProvider(t.TempDir())
+	provider.BaseURL = server.URL
+
+	return provider
+}
+
+/* working days around weekends and the holidays of 1 January and 11 November 2026 */
+var publishedTables = map[string]string{
+	"2025-12-30": "4.2100",
+	"2025-12-31": "4.2200",
+	"2026-01-02": "4.2300",
+	"2026-10-08": "4.2400",
+	"2026-10-09": "4.2500",
+	"2026-10-12": "4.2600",
+	"2026-10-13": "4.2700",
+	"2026-10-14": "4.2800",
+	"2026-11-09": "4.2900",
+	"2026-11-10": "4.3000",
+	"2026-11-12": "4.3100",
+}
+
+func TestRateBeforeTakesLastWorkingDayBeforeDate(t *testing.T) {
+	cases := []struct {
+		date          string
+		effectiveDate string
+		mid           string
+	}{
+		{"2026-10-14", "2026-10-13", "4.2700"}, // the table of the day itself is never used
+		{"2026-10-12", "2026-10-09", "4.2500"}, // Monday takes Friday
+		{"2026-10-11", "2026-10-09", "4.2500"}, // Sunday
+		{"2026-10-10", "2026-10-09", "4.2500"}, // Saturday
+		{"2026-11-12", "2026-11-10", "4.3000"}, // after Independence Day
+		{"2026-01-02", "2025-12-31", "4.2200"}, // after New Year, in the previous year
+	}
+
+	server, _ := nbpServer(t, publishedTables)
+	provider := newProvider(t, server)
+
+	for _, c := range cases {
+		date, _ := time.Parse("2006-01-02", c.date)
+
+		rate, err := provider.RateBefore("eur", date)
+		if err != nil {
+			t.Errorf("rate before %s: %v", c.date, err)
+			continue
+		}
+		if rate.EffectiveDate != c.effectiveDate || rate.Mid != c.mid || rate.Currency != "EUR" {
+			t.Errorf("rate before %s = %s %s of %s, want EUR %s of %s", c.date, rate.Currency, rate.Mid, rate.EffectiveDate, c.mid, c.effectiveDate)
+		}
+		if !strings.HasSuffix(rate.TableNo, "/A/NBP/"+c.effectiveDate[:4]) {
+			t.Errorf("table number %q of %s", rate.TableNo, c.effectiveDate)
+		}
+	}
+}
+
+func TestRateBeforeIsCached(t *testing.T) {
+	server, requests := nbpServer(t, publishedTables)
+	provider := newProvider(t, server)
+	date, _ := time.Parse("2006-01-02", "2026-10-12")
+
+	first, err := provider.RateBefore("EUR", date)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := provider.RateBefore("EUR", date)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if first != second || *requests != 1 {
+		t.Errorf("second lookup gave %+v after %d requests, want %+v from the cache", second, *requests, first)
+	}
+}
+
+func TestRateBeforeWithoutTable(t *testing.T) {
+	server, _ := nbpServer(t, publishedTables)
+	provider := newProvider(t, server)
+	date, _ := time.Parse("2006-01-02", "2026-06-01")
+
+	if _, err := provider.RateBefore("EUR", date); !errors.Is(err, ExchangeRate.ErrRateNotFound) {
+		t.Errorf("rate without a published table = %v, want ErrRateNotFound", err)
+	}
+	if _, err := provider.RateBefore("XYZ", date); !errors.Is(err, ExchangeRate.ErrRateNotFound) {
+		t.Errorf("rate of an unknown currency = %v, want ErrRateNotFound", err)
+	}
+}
@@ -2,13 +2,22 @@ package InvoiceGenerator
 
 import (
 	"fmt"
-	"log"
+	Assets "moneybringer/assets"
 	InvoiceManager "moneybringer/invoice-manager"
+	CustomerData "moneybringer/invoice-manager/customer"
+	Invoice "moneybringer/invoice-manager/invoice"
+	Money "moneybringer/utils/money"
+	"os"
 
 	"github.com/phpdave11/gofpdf"
 )
 
-func GenerateInvoicePDF(invoice InvoiceManager.InvoiceCreatedData, outputPath string) {
+/* GenerateInvoicePDF refuses invoices mixing currencies, their totals would add up amounts in different currencies */
+func GenerateInvoicePDF(invoice InvoiceManager.InvoiceCreatedData, outputPath string) error {
+	if err := InvoiceManager.CheckCurrency(invoice); err != nil {
+		return err
+	}
+
 	pdf := gofpdf.New("P", "mm", "A4", "")
 	basicDocumentSetup(pdf)
 	createHeaderSection(pdf, invoice)
@@ -18,16 +27,16 @@ func GenerateInvoicePDF(invoice InvoiceManager.InvoiceCreatedData, outputPath st
 
 	// Save PDF
 	if err := pdf.OutputFileAndClose(outputPath); err != nil {
-		log.Fatalf("Error saving PDF: %v", err)
+		return fmt.Errorf("saving PDF: %w", err)
 	}
 
-	log.Println("Invoice PDF generated successfully at", outputPath)
+	return nil
 }
 
 func basicDocumentSetup(pdf *gofpdf.Fpdf) {
-	pdf.AddUTF8Font("Inter", "", "assets/fonts/Inter-VariableFont_opsz,wght.ttf")
-	pdf.AddUTF8Font("InterItalic", "", "assets/fonts/Inter-Italic-VariableFont_opsz,wght.ttf")
-	pdf.AddUTF8Font("Inter", "B", "assets/fonts/static/Inter_18pt-Bold.ttf")
+	pdf.AddUTF8FontFromBytes("Inter", "", Assets.InterRegular)
+	pdf.AddUTF8FontFromBytes("InterItalic", "", Assets.InterItalic)
+	pdf.AddUTF8FontFromBytes("Inter", "B", Assets.InterBold)
 
 	pdf.SetMargins(2, 10, 2)
 	pdf.AddPage()
@@ -35,14 +44,37 @@ func basicDocumentSetup(pdf *gofpdf.Fpdf) {
 }
 
 func createHeaderSection(pdf *gofpdf.Fpdf, invoice InvoiceManager.InvoiceCreatedData) {
+	createLogo(pdf, invoice.InvoiceFrom.Logo)
+
 	// Title
-	pdf.Cell(0, 10, "Invoice")
+	numberLabel := "Invoice Number: "
+	switch {
+	case invoice.Correction != nil:
+		pdf.Cell(0, 10, "Correction invoice (Faktura korygująca)")
+	case invoice.Advance != nil:
+		pdf.Cell(0, 10, "Advance invoice (Faktura zaliczkowa)")
+	case invoice.Settlement != nil:
+		pdf.Cell(0, 10, "Final invoice (Faktura rozliczeniowa)")
+	case invoice.Kind() == InvoiceManager.DOCUMENT_KIND_PROFORMA:
+		pdf.Cell(0, 10, "PROFORMA")
+		numberLabel = "Proforma Number: "
+	default:
+		pdf.Cell(0, 10, "Invoice")
+	}
 	pdf.Ln(10)
 
 	// Invoice Information
 	pdf.SetFont("Inter", "", 12)
-	pdf.Cell(0, 10, "Invoice Number: "+invoice.InvoiceNo)
+	if invoice.Kind() == InvoiceManager.DOCUMENT_KIND_PROFORMA {
+		pdf.Cell(0, 10, "This document is not a VAT invoice (Dokument nie jest fakturą VAT)")
+		pdf.Ln(6)
+	}
+	pdf.Cell(0, 10, numberLabel+invoice.InvoiceNo)
 	pdf.Ln(6)
+	if invoice.ProformaNo != "" {
+		pdf.Cell(0, 10, "Issued for proforma: "+invoice.ProformaNo)
+		pdf.Ln(6)
+	}
 	pdf.Cell(0, 10, "Date of Issue: "+invoice.DateOfIssue)
 	pdf.Ln(6)
 	pdf.Cell(0, 10, "Place of Issue: "+invoice.PlaceOfIssue)
@@ -50,7 +82,41 @@ func createHeaderSection(pdf *gofpdf.Fpdf, invoice InvoiceManager.InvoiceCreated
 	pdf.Cell(0, 10, "Service Start date: "+invoice.ServiceStartDate)
 	pdf.Ln(6)
 	pdf.Cell(0, 10, "Service End date: "+invoice.ServiceEndDate)
-	pdf.Ln(16)
+	pdf.Ln(6)
+	if invoice.Correction != nil {
+		createCorrectionReference(pdf, invoice.Correction)
+	}
+	pdf.Ln(10)
+}
+
+/*
+The seller logo goes to the top right corner, a logo that is gone since the invoice was
+issued is skipped; "config validate" reports a missing logo
+*/
+func createLogo(pdf *gofpdf.Fpdf, logoPath string) {
+	if logoPath == "" {
+		return
+	}
+
+	if _, err := os.Stat(logoPath); err != nil {
+		return
+	}
+
+	x, y := pdf.GetXY()
+	pdf.ImageOptions(logoPath, 168, 6, 38, 0, false, gofpdf.ImageOptions{ReadDpi: true}, 0, "")
+	pdf.SetXY(x, y)
+}
+
+func createCorrectionReference(pdf *gofpdf.Fpdf, correction *InvoiceManager.InvoiceCorrection) {
+	pdf.SetFont("Inter", "B", 12)
+	pdf.Cell(0, 10, fmt.Sprintf("Corrected invoice: %s of %s", correction.OriginalInvoiceNo, correction.OriginalDateOfIssue))
+	pdf.Ln(6)
+	pdf.SetFont("Inter", "", 12)
+	if correction.OriginalKsefReferenceNumber != "" {
+		pdf.Cell(0, 10, "KSeF number of corrected invoice: "+correction.OriginalKsefReferenceNumber)
+		pdf.Ln(6)
+	}
+	pdf.MultiCell(0, 6, "Reason for correction: "+correction.Reason, "", "L", false)
 }
 
 func createCompanyAndCustomerSection(pdf *gofpdf.Fpdf, invoice InvoiceManager.InvoiceCreatedData) {
@@ -66,31 +132,88 @@ func createCompanyAndCustomerSection(pdf *gofpdf.Fpdf, invoice InvoiceManager.In
 
 	pdf.SetFont("Inter", "", 12)
 
-	pdf.CellFormat(95, 6, invoice.InvoiceFrom.FullName, "", 0, "L", false, 0, "")
-	pdf.CellFormat(95, 6, invoice.InvoiceTo.FullName, "", 1, "R", false, 0, "")
+	from := []string{
+		invoice.InvoiceFrom.FullName,
+		invoice.InvoiceFrom.Address,
+		"Tax Number: " + invoice.InvoiceFrom.TaxNumber,
+		"Email: " + invoice.InvoiceFrom.Email,
+		"IBAN: " + invoice.IBAN,
+		"SWIFT: " + invoice.SWIFT,
+	}
+	to := getBuyerLines(invoice)
 
-	pdf.CellFormat(95, 6, invoice.InvoiceFrom.Address, "", 0, "L", false, 0, "")
-	pdf.CellFormat(95, 6, invoice.InvoiceTo.Address.StreetAddress+", "+invoice.InvoiceTo.Address.Number, "", 1, "R", false, 0, "")
+	for i := 0; i < max(len(from), len(to)); i++ {
+		pdf.CellFormat(95, 6, lineAt(from, i), "", 0, "L", false, 0, "")
+		pdf.CellFormat(95, 6, lineAt(to, i), "", 1, "R", false, 0, "")
+	}
 
-	pdf.CellFormat(95, 6, "Tax Number: "+invoice.InvoiceFrom.TaxNumber, "", 0, "L", false, 0, "")
-	pdf.CellFormat(95, 6, invoice.InvoiceTo.Address.City+", "+invoice.InvoiceTo.Address.State+" - "+invoice.InvoiceTo.Address.ZipCode, "", 1, "R", false, 0, "")
+	pdf.Ln(10)
+}
+
+/* getBuyerLines lists the buyer address and identifiers, the NIP is required on invoices for Polish businesses */
+func getBuyerLines(invoice InvoiceManager.InvoiceCreatedData) []string {
+	buyer := invoice.InvoiceTo
+	address := InvoiceManager.BuyerAddressOf(invoice)
 
-	pdf.CellFormat(95, 6, "Email: "+invoice.InvoiceFrom.Email, "", 0, "L", false, 0, "")
-	pdf.CellFormat(95, 6, "", "", 1, "R", false, 0, "")
+	street := address.StreetAddress
+	if address.Number != "" {
+		street += " " + address.Number
+	}
 
-	pdf.CellFormat(95, 6, "IBAN: "+invoice.IBAN, "", 0, "L", false, 0, "")
-	pdf.CellFormat(95, 6, "", "", 1, "R", false, 0, "")
+	city := address.ZipCode + " " + address.City
+	if address.State != "" {
+		city += ", " + address.State
+	}
 
-	pdf.CellFormat(95, 6, "SWIFT: "+invoice.SWIFT, "", 0, "L", false, 0, "")
-	pdf.CellFormat(95, 6, "", "", 1, "R", false, 0, "")
+	lines := []string{buyer.FullName, street, city}
 
-	pdf.Ln(10)
+	if address.CountryCode != CustomerData.DEFAULT_COUNTRY_CODE && address.Country != "" {
+		lines = append(lines, address.Country)
+	}
+	if buyer.TaxNumber != "" {
+		lines = append(lines, "Tax Number (NIP): "+buyer.TaxNumber)
+	}
+	if buyer.VatEuNumber != "" {
+		lines = append(lines, "VAT EU: "+buyer.VatEuNumber)
+	}
+	if buyer.ContactPerson != "" {
+		lines = append(lines, "Contact: "+buyer.ContactPerson)
+	}
+	if buyer.Email != "" {
+		lines = append(lines, "Email: "+buyer.Email)
+	}
+
+	return lines
+}
+
+func lineAt(lines []string, i int) string {
+	if i < len(lines) {
+		return lines[i]
+	}
+
+	return ""
 }
 
 func createPositionsSection(pdf *gofpdf.Fpdf, invoice InvoiceManager.InvoiceCreatedData) {
+	if invoice.Correction != nil {
+		createPositionsTable(pdf, "Before correction", invoice.Correction.PositionsBefore)
+		pdf.Ln(10)
+		createPositionsTable(pdf, "After correction", invoice.InvoicePositions)
+		return
+	}
+
+	if invoice.Advance != nil {
+		createPositionsTable(pdf, "Ordered goods / services", invoice.InvoicePositions)
+		return
+	}
+
+	createPositionsTable(pdf, "Positions", invoice.InvoicePositions)
+}
+
+func createPositionsTable(pdf *gofpdf.Fpdf, title string, positions []Invoice.InvoicePosition) {
 	// Table Header
 	pdf.SetFont("Inter", "B", 16)
-	pdf.Cell(0, 10, "Positions")
+	pdf.Cell(0, 10, title)
 	pdf.Ln(16)
 
 	pdf.SetFont("Inter", "B", 8)
@@ -107,40 +230,62 @@ func createPositionsSection(pdf *gofpdf.Fpdf, invoice InvoiceManager.InvoiceCrea
 
 	// Table Content
 	pdf.SetFont("Inter", "", 8)
-	for i, pos := range invoice.InvoicePositions {
+	for i, pos := range positions {
 		pdf.CellFormat(10, 10, fmt.Sprintf("%d", i+1), "1", 0, "C", false, 0, "")                     // Item No.
 		pdf.CellFormat(50, 10, pos.ProductOrServiceName, "1", 0, "C", false, 0, "")                   // Product / Service name
 		pdf.CellFormat(24, 10, pos.PolishClassificationOfGoodsAndServices, "1", 0, "C", false, 0, "") // Symbol PKWiU
 		pdf.CellFormat(8, 10, pos.Unit, "1", 0, "C", false, 0, "")                                    // Unit
 		pdf.CellFormat(8, 10, fmt.Sprintf("%d", pos.Quantity), "1", 0, "C", false, 0, "")             // Quantity
-		pdf.CellFormat(19, 10, fmt.Sprintf("%.2f", pos.NetPrice), "1", 0, "C", false, 0, "")          // Net price
-		pdf.CellFormat(19, 10, fmt.Sprintf("%.2f", pos.NetValue), "1", 0, "C", false, 0, "")          // Net value
-		pdf.CellFormat(15, 10, fmt.Sprintf("%d", pos.TaxRate), "1", 0, "C", false, 0, "")             // Tax rate
-		pdf.CellFormat(19, 10, fmt.Sprintf("%.2f", pos.TaxAmount), "1", 0, "C", false, 0, "")         // Tax amount
-		pdf.CellFormat(19, 10, fmt.Sprintf("%.2f", pos.GrossValue), "1", 0, "C", false, 0, "")        // Gross value
+		pdf.CellFormat(19, 10, pos.NetPrice.String(), "1", 0, "C", false, 0, "")                      // Net price
+		pdf.CellFormat(19, 10, pos.NetValue.String(), "1", 0, "C", false, 0, "")                      // Net value
+		pdf.CellFormat(15, 10, pos.TaxRate.Label(), "1", 0, "C", false, 0, "")                        // Tax rate
+		pdf.CellFormat(19, 10, pos.TaxAmount.String(), "1", 0, "C", false, 0, "")                     // Tax amount
+		pdf.CellFormat(19, 10, pos.GrossValue.String(), "1", 0, "C", false, 0, "")                    // Gross value
 		pdf.CellFormat(15, 10, pos.Currency, "1", 1, "C", false, 0, "")                               // Currency
 	}
 }
 
 func createSummarySection(pdf *gofpdf.Fpdf, invoice InvoiceManager.InvoiceCreatedData) {
-	// Check if array has elements and avoid out-of-range error
-	var currency string = "PLN"
-	if len(invoice.InvoicePositions) > 0 && invoice.InvoicePositions[0].Currency != "" {
-		currency = invoice.InvoicePositions[0].Currency
-	}
+	currency := InvoiceManager.CurrencyOf(invoice)
 
 	// Summary
 	pdf.Ln(16)
 	pdf.SetFont("Inter", "B", 16)
-	pdf.Cell(0, 10, "Summary")
-	pdf.Ln(8)
+	if invoice.Correction != nil {
+		pdf.Cell(0, 10, "Difference (after - before correction)")
+	} else {
+		pdf.Cell(0, 10, "Summary")
+	}
+	pdf.Ln(12)
+	switch {
+	case invoice.Advance != nil:
+		createAdvanceSummary(pdf, invoice.Advance, invoice.InvoiceSummary, currency)
+	case invoice.Settlement != nil:
+		createSettlementSummary(pdf, invoice.Settlement, invoice.InvoiceSummary, currency)
+	default:
+		createVatBreakdownTable(pdf, invoice.InvoiceSummary, currency)
+	}
+	createTaxNotices(pdf, invoice)
+	if invoice.Conversion != nil {
+		createConversionSummary(pdf, invoice.Conversion)
+	}
+
 	pdf.SetFont("Inter", "", 12)
-	pdf.Cell(0, 10, fmt.Sprintf("Total Amount: %.2f %s", invoice.InvoiceSummary.TotalAmount, currency))
+	pdf.Cell(0, 10, fmt.Sprintf("Total Amount: %s %s", invoice.InvoiceSummary.TotalAmount, currency))
 	pdf.Ln(8)
-	pdf.Cell(0, 10, fmt.Sprintf("Total Tax Amount: %.2f %s", invoice.InvoiceSummary.TotalTaxAmount, currency))
+	pdf.Cell(0, 10, fmt.Sprintf("Total Tax Amount: %s %s", invoice.InvoiceSummary.TotalTaxAmount, currency))
 	pdf.Ln(8)
-	pdf.Cell(0, 10, fmt.Sprintf("Total Gross Value: %.2f %s", invoice.InvoiceSummary.TotalGrossValue, currency))
+	pdf.Cell(0, 10, fmt.Sprintf("Total Gross Value: %s %s", invoice.InvoiceSummary.TotalGrossValue, currency))
 	pdf.Ln(8)
+	if invoice.Correction != nil {
+		createCorrectionSettlement(pdf, invoice.InvoiceSummary.TotalGrossValue, currency)
+	}
+	if invoice.InvoiceSummary.GrossInWords != "" {
+		pdf.SetFont("InterItalic", "", 10)
+		pdf.Cell(0, 10, "Słownie (in words): "+invoice.InvoiceSummary.GrossInWords)
+		pdf.Ln(8)
+		pdf.SetFont("Inter", "", 12)
+	}
 	pdf.Cell(0, 10, fmt.Sprintf("Issued An Invoice: %s %s", invoice.AuthorFirstName, invoice.AuthorLastName))
 	pdf.Ln(20)
 
@@ -152,3 +297,120 @@ func createSummarySection(pdf *gofpdf.Fpdf, invoice InvoiceManager.InvoiceCreate
 
 	pdf.Ln(8)
 }
+
+func createCorrectionSettlement(pdf *gofpdf.Fpdf, difference Money.Money, currency string) {
+	pdf.SetFont("Inter", "B", 12)
+	if difference.Amount < 0 {
+		pdf.Cell(0, 10, fmt.Sprintf("To be refunded (do zwrotu): %s %s", difference.Neg(), currency))
+	} else {
+		pdf.Cell(0, 10, fmt.Sprintf("To be paid (do zapłaty): %s %s", difference, currency))
+	}
+	pdf.Ln(8)
+	pdf.SetFont("Inter", "", 12)
+}
+
+func createVatBreakdownTable(pdf *gofpdf.Fpdf, summary InvoiceManager.InvoiceSummary, currency string) {
+	pdf.SetFont("Inter", "B", 8)
+	headers := []string{"Tax rate", "Net value", "Tax amount", "Gross value", "Currency"}
+	colWidths := []float64{20, 30, 30, 30, 20}
+
+	for i, header := range headers {
+		pdf.CellFormat(colWidths[i], 8, header, "1", 0, "C", false, 0, "")
+	}
+	pdf.Ln(-1)
+
+	pdf.SetFont("Inter", "", 8)
+	for _, rateSummary := range summary.VatBreakdown {
+		pdf.CellFormat(20, 8, rateSummary.TaxRate.Label(), "1", 0, "C", false, 0, "")
+		pdf.CellFormat(30, 8, rateSummary.NetValue.String(), "1", 0, "C", false, 0, "")
+		pdf.CellFormat(30, 8, rateSummary.TaxAmount.String(), "1", 0, "C", false, 0, "")
+		pdf.CellFormat(30, 8, rateSummary.GrossValue.String(), "1", 0, "C", false, 0, "")
+		pdf.CellFormat(20, 8, currency, "1", 1, "C", false, 0, "")
+	}
+
+	pdf.SetFont("Inter", "B", 8)
+	pdf.CellFormat(20, 8, "Total", "1", 0, "C", false, 0, "")
+	pdf.CellFormat(30, 8, summary.TotalAmount.String(), "1", 0, "C", false, 0, "")
+	pdf.CellFormat(30, 8, summary.TotalTaxAmount.String(), "1", 0, "C", false, 0, "")
+	pdf.CellFormat(30, 8, summary.TotalGrossValue.String(), "1", 0, "C", false, 0, "")
+	pdf.CellFormat(20, 8, currency, "1", 1, "C", false, 0, "")
+	pdf.Ln(4)
+}
+
+func createSubsectionTitle(pdf *gofpdf.Fpdf, title string) {
+	pdf.SetFont("Inter", "B", 10)
+	pdf.Cell(0, 8, title)
+	pdf.Ln(8)
+}
+
+func createAdvanceSummary(pdf *gofpdf.Fpdf, advance *InvoiceManager.InvoiceAdvance, summary InvoiceManager.InvoiceSummary, currency string) {
+	createSubsectionTitle(pdf, "Order value (Wartość zamówienia)")
+	createVatBreakdownTable(pdf, advance.OrderSummary, currency)
+
+	createSubsectionTitle(pdf, fmt.Sprintf("Advance received on %s (Otrzymana zaliczka)", advance.PaymentDate))
+	createVatBreakdownTable(pdf, summary, currency)
+}
+
+func createSettlementSummary(pdf *gofpdf.Fpdf, settlement *InvoiceManager.InvoiceSettlement, summary InvoiceManager.InvoiceSummary, currency string) {
+	createSubsectionTitle(pdf, "Order value (Wartość zamówienia)")
+	createVatBreakdownTable(pdf, settlement.OrderSummary, currency)
+
+	createSubsectionTitle(pdf, "Advances deducted (Rozliczone zaliczki)")
+	pdf.SetFont("Inter", "B", 8)
+	headers := []string{"Advance invoice", "Date of issue", "Net value", "Tax amount", "Gross value"}
+	colWidths := []float64{40, 25, 30, 30, 30}
+	for i, header := range headers {
+		pdf.CellFormat(colWidths[i], 8, header, "1", 0, "C", false, 0, "")
+	}
+	pdf.Ln(-1)
+
+	pdf.SetFont("Inter", "", 8)
+	for _, advance := range settlement.Advances {
+		pdf.CellFormat(40, 8, advance.InvoiceNo, "1", 0, "C", false, 0, "")
+		pdf.CellFormat(25, 8, advance.DateOfIssue, "1", 0, "C", false, 0, "")
+		pdf.CellFormat(30, 8, advance.Summary.TotalAmount.String(), "1", 0, "C", false, 0, "")
+		pdf.CellFormat(30, 8, advance.Summary.TotalTaxAmount.String(), "1", 0, "C", false, 0, "")
+		pdf.CellFormat(30, 8, advance.Summary.TotalGrossValue.String(), "1", 1, "C", false, 0, "")
+	}
+	pdf.Ln(4)
+
+	createSubsectionTitle(pdf, "Remaining to pay (Pozostało do zapłaty)")
+	createVatBreakdownTable(pdf, summary, currency)
+}
+
+/* VAT on a foreign currency invoice has to be shown in PLN as well, with the NBP rate used (art. 106e ust. 11 VAT act) */
+func createConversionSummary(pdf *gofpdf.Fpdf, conversion *InvoiceManager.CurrencyConversion) {
+	rate := conversion.Rate
+	createSubsectionTitle(pdf, fmt.Sprintf("Amounts in PLN, 1 %s = %s PLN, NBP table %s of %s", rate.Currency, rate.Mid, rate.TableNo, rate.EffectiveDate))
+	createVatBreakdownTable(pdf, conversion.Summary, InvoiceManager.PLN)
+
+	pdf.SetFont("Inter", "B", 10)
+	pdf.Cell(0, 8, fmt.Sprintf("VAT in PLN (Kwota podatku w PLN): %s PLN", conversion.Summary.TotalTaxAmount))
+	pdf.Ln(8)
+}
+
+/* Positions without VAT need the reason (and for exemptions the legal basis) printed on the invoice */
+func createTaxNotices(pdf *gofpdf.Fpdf, invoice InvoiceManager.InvoiceCreatedData) {
+	var notices []string
+	seen := map[string]bool{}
+
+	for _, rateSummary := range invoice.InvoiceSummary.VatBreakdown {
+		if rateSummary.TaxRate.IsNumeric() {
+			continue
+		}
+
+		notices = append(notices, rateSummary.TaxRate.Description())
+		for _, pos := range invoice.InvoicePositions {
+			if pos.TaxRate != rateSummary.TaxRate || pos.ExemptionBasis == "" || seen[pos.ExemptionBasis] {
+				continue
+			}
+			seen[pos.ExemptionBasis] = true
+			notices = append(notices, "Podstawa prawna (legal basis): "+pos.ExemptionBasis)
+		}
+	}
+
+	pdf.SetFont("Inter", "", 9)
+	for _, notice := range notices {
+		pdf.MultiCell(0, 5, notice, "", "L", false)
+	}
+}
@@ -2,10 +2,14 @@ package CompanyData
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
-	"io"
-	"log"
+	TaxRate "moneybringer/invoice-manager/tax-rate"
+	AppErrors "moneybringer/utils/app-errors"
+	AppPaths "moneybringer/utils/app-paths"
 	"os"
+	"sort"
+	"strings"
 )
 
 type Address struct {
@@ -27,66 +31,197 @@ type PersonalDetails struct {
 	Phome     string `json:"phome"`
 }
 
+/* BankAccount is printed on invoices in Currency, an empty Currency matches every currency */
+type BankAccount struct {
+	Name     string `json:"name"`
+	IBAN     string `json:"IBAN"`
+	SWIFT    string `json:"SWIFT"`
+	Currency string `json:"currency"`
+}
+
 type CompanyDetails struct {
-	FullName  string  `json:"fullName"`
-	Address   Address `json:"address"`
-	TaxNumber string  `json:"taxNumber"`
-	Email     string  `json:"email"`
-	Phome     string  `json:"phome"`
-	IBAN      string  `json:"IBAN"`
-	SWIFT     string  `json:"SWIFT"`
+	FullName     string        `json:"fullName"`
+	Address      Address       `json:"address"`
+	TaxNumber    string        `json:"taxNumber"`
+	Regon        string        `json:"regon,omitempty"`
+	Email        string        `json:"email"`
+	Phome        string        `json:"phome"`
+	IBAN         string        `json:"IBAN"`
+	SWIFT        string        `json:"SWIFT"`
+	BankAccounts []BankAccount `json:"bankAccounts"`
 }
 
 type InvoicePosition struct {
-	DefaultProduct                         string  `json:"defaultProduct"`
-	DefaultUnit                            string  `json:"defaultUnit"`
-	DefaultNetPrice                        float64 `json:"defaultNetPrice"`
-	DefaultTaxRate                         float64 `json:"defaultTaxRate"`
-	PolishClassificationOfGoodsAndServices string  `json:"polishClassificationOfGoodsAndServices"`
-	DefaultCurrency                        string  `json:"defaultCurrency"`
+	DefaultProduct                         string          `json:"defaultProduct"`
+	DefaultUnit                            string          `json:"defaultUnit"`
+	DefaultNetPrice                        float64         `json:"defaultNetPrice"`
+	DefaultTaxRate                         TaxRate.TaxRate `json:"defaultTaxRate"`
+	DefaultExemptionBasis                  string          `json:"defaultExemptionBasis"`
+	PolishClassificationOfGoodsAndServices string          `json:"polishClassificationOfGoodsAndServices"`
+	DefaultCurrency                        string          `json:"defaultCurrency"`
 }
 
 type InvoiceDetails struct {
-	DefaultNotes           []string `json:"defaultNotes"`
-	DefaultServiceStartDay int      `json:"defaultServiceStartDay"`
-	DefaultServiceEndDay   int      `json:"defaultServiceEndDay"`
-	DefaultPlaceOfIssue    string   `json:"defaultPlaceOfIssue"`
+	DefaultNotes            []string `json:"defaultNotes"`
+	DefaultServiceStartDay  int      `json:"defaultServiceStartDay"`
+	DefaultServiceEndDay    int      `json:"defaultServiceEndDay"`
+	DefaultPlaceOfIssue     string   `json:"defaultPlaceOfIssue"`
+	NumberPattern           string   `json:"numberPattern"`
+	CorrectionNumberPattern string   `json:"correctionNumberPattern"`
+	ProformaNumberPattern   string   `json:"proformaNumberPattern"`
+	VatRounding             string   `json:"vatRounding"`
+	AmountInWordsLanguage   string   `json:"amountInWordsLanguage"`
+}
+
+type Jpk struct {
+	TaxOfficeCode string `json:"taxOfficeCode"`
+	TaxpayerType  string `json:"taxpayerType"`
+	BirthDate     string `json:"birthDate"`
 }
 
 /* TODO - add fields geters */
 type Company struct {
+	Profile         string          `json:"-"`
+	Logo            string          `json:"logo"`
 	Payment         Payment         `json:"payment"`
 	PersonalDetails PersonalDetails `json:"personalDetails"`
 	CompanyDetails  CompanyDetails  `json:"companyDetails"`
 	InvoicePosition InvoicePosition `json:"invoicePosition"`
 	InvoiceDetails  InvoiceDetails  `json:"invoiceDetails"`
+	Jpk             Jpk             `json:"jpk"`
+}
+
+/*
+companyFile is either a single seller, as company.json was before profiles, or several
+named seller profiles; the single seller is the profile DEFAULT_PROFILE.
+*/
+type companyFile struct {
+	Company
+	DefaultProfile string             `json:"defaultProfile"`
+	Profiles       map[string]Company `json:"profiles"`
+}
+
+const COMPANY_JSON_FILE = "company.json"
+
+func CompanyJsonPath() string {
+	return AppPaths.ConfigFile(COMPANY_JSON_FILE)
+}
+
+const DEFAULT_PROFILE = "default"
+
+var ErrProfileNotFound = errors.New("company profile not found")
+
+/* selectedProfile is set by --profile, empty means the default profile of company.json */
+var selectedProfile string
+
+func SelectProfile(profile string) {
+	selectedProfile = profile
 }
 
-const COMPANY_JSON_PATH = "./config/company.json"
+/* LoadProfiles reads every seller profile and returns them with the key of the default profile */
+func LoadProfiles(path string) (map[string]Company, string, error) {
+	var file companyFile
 
-func GetCompanyData() Company {
-	// Open the JSON file
-	file, osErr := os.Open(COMPANY_JSON_PATH)
-	if osErr != nil {
-		log.Fatal(osErr)
-		os.Exit(1)
+	jsonData, err := os.ReadFile(path)
+	if err != nil {
+		return nil, "", fmt.Errorf("%w: %w", AppErrors.ErrConfigInvalid, err)
 	}
-	defer file.Close()
 
-	// Read the file content into a byte slice
-	jsonData, readErr := io.ReadAll(file)
-	if readErr != nil {
-		log.Fatal(readErr)
-		os.Exit(1)
+	if err := json.Unmarshal(jsonData, &file); err != nil {
+		return nil, "", fmt.Errorf("%w: %s: %w", AppErrors.ErrConfigInvalid, path, err)
 	}
 
-	// Unmarshal the JSON into the Company struct
-	var company Company
-	jsonErr := json.Unmarshal(jsonData, &company)
-	if jsonErr != nil {
-		fmt.Println("Error unmarshalling JSON:", jsonErr)
-		os.Exit(1)
+	if len(file.Profiles) == 0 {
+		return map[string]Company{DEFAULT_PROFILE: withProfile(file.Company, DEFAULT_PROFILE)}, DEFAULT_PROFILE, nil
 	}
 
+	profiles := map[string]Company{}
+	for key, company := range file.Profiles {
+		profiles[key] = withProfile(company, key)
+	}
+
+	defaultProfile := file.DefaultProfile
+	if defaultProfile == "" {
+		if _, exists := profiles[DEFAULT_PROFILE]; exists {
+			defaultProfile = DEFAULT_PROFILE
+		} else if len(profiles) == 1 {
+			for key := range profiles {
+				defaultProfile = key
+			}
+		} else {
+			return nil, "", fmt.Errorf("%w: %s: defaultProfile is required when there are several profiles", AppErrors.ErrConfigInvalid, path)
+		}
+	}
+
+	if _, exists := profiles[defaultProfile]; !exists {
+		return nil, "", fmt.Errorf("%w: %s: %w: defaultProfile %q", AppErrors.ErrConfigInvalid, path, ErrProfileNotFound, defaultProfile)
+	}
+
+	return profiles, defaultProfile, nil
+}
+
+/*
+LoadCompanyData reads one seller profile without exiting on errors, an empty profile
+selects the default one. A profile with an invalid NIP, IBAN or SWIFT is refused so
+typos never reach an invoice.
+*/
+func LoadCompanyData(path string, profile string) (Company, error) {
+	profiles, defaultProfile, err := LoadProfiles(path)
+	if err != nil {
+		return Company{}, err
+	}
+
+	if profile == "" {
+		profile = defaultProfile
+	}
+
+	company, exists := profiles[profile]
+	if !exists {
+		keys := make([]string, 0, len(profiles))
+		for key := range profiles {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		return Company{}, fmt.Errorf("%w: %q, available profiles: %s", ErrProfileNotFound, profile, strings.Join(keys, ", "))
+	}
+
+	if problems := Validate(company); len(problems) > 0 {
+		return Company{}, validationError(company, problems)
+	}
+
+	return company, nil
+}
+
+func withProfile(company Company, profile string) Company {
+	company.Profile = profile
+	company.Logo = AppPaths.ResolveConfigPath(company.Logo)
 	return company
 }
+
+/* GetCompanyData returns the profile selected with --profile */
+func GetCompanyData() (Company, error) {
+	return GetProfileData(selectedProfile)
+}
+
+/* GetProfileData errors wrap ErrProfileNotFound for unknown profiles and AppErrors.ErrConfigInvalid for broken company.json */
+func GetProfileData(profile string) (Company, error) {
+	return LoadCompanyData(CompanyJsonPath(), profile)
+}
+
+/* BankAccountFor returns the account for invoices in currency, falling back to IBAN and SWIFT of the company */
+func (company Company) BankAccountFor(currency string) BankAccount {
+	for _, account := range company.CompanyDetails.BankAccounts {
+		if strings.EqualFold(account.Currency, currency) {
+			return account
+		}
+	}
+
+	for _, account := range company.CompanyDetails.BankAccounts {
+		if account.Currency == "" {
+			return account
+		}
+	}
+
+	return BankAccount{IBAN: company.CompanyDetails.IBAN, SWIFT: company.CompanyDetails.SWIFT}
+}
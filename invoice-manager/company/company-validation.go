@@ -0,0 +1,67 @@
+package CompanyData
+
+import (
+	"fmt"
+	AmountInWords "moneybringer/utils/amount-in-words"
+	AppErrors "moneybringer/utils/app-errors"
+	Validation "moneybringer/utils/validation"
+	"strings"
+)
+
+var ErrInvalidCompany = fmt.Errorf("%w: company data", AppErrors.ErrConfigInvalid)
+
+/* Validate checks the identifiers printed on invoices, problems name the fields as in company.json */
+func Validate(company Company) []string {
+	problems := []string{}
+	details := company.CompanyDetails
+
+	if details.TaxNumber == "" {
+		problems = append(problems, "companyDetails.taxNumber is empty")
+	} else if err := Validation.ValidateNIP(details.TaxNumber); err != nil {
+		problems = append(problems, fmt.Sprintf("companyDetails.taxNumber: %v", err))
+	}
+
+	if details.Regon != "" {
+		if err := Validation.ValidateREGON(details.Regon); err != nil {
+			problems = append(problems, fmt.Sprintf("companyDetails.regon: %v", err))
+		}
+	}
+
+	switch company.InvoiceDetails.AmountInWordsLanguage {
+	case "", AmountInWords.LANGUAGE_POLISH, AmountInWords.LANGUAGE_ENGLISH:
+	default:
+		problems = append(problems, fmt.Sprintf("invoiceDetails.amountInWordsLanguage must be %q or %q", AmountInWords.LANGUAGE_POLISH, AmountInWords.LANGUAGE_ENGLISH))
+	}
+
+	problems = append(problems, validateBankAccount("companyDetails.", BankAccount{IBAN: details.IBAN, SWIFT: details.SWIFT}, false)...)
+
+	for i, account := range details.BankAccounts {
+		problems = append(problems, validateBankAccount(fmt.Sprintf("companyDetails.bankAccounts[%d].", i), account, true)...)
+	}
+
+	return problems
+}
+
+func validateBankAccount(prefix string, account BankAccount, required bool) []string {
+	problems := []string{}
+
+	if account.IBAN == "" && required {
+		problems = append(problems, prefix+"IBAN is empty")
+	} else if account.IBAN != "" {
+		if err := Validation.ValidateIBAN(account.IBAN); err != nil {
+			problems = append(problems, fmt.Sprintf("%sIBAN: %v", prefix, err))
+		}
+	}
+
+	if account.SWIFT != "" {
+		if err := Validation.ValidateBIC(account.SWIFT); err != nil {
+			problems = append(problems, fmt.Sprintf("%sSWIFT: %v", prefix, err))
+		}
+	}
+
+	return problems
+}
+
+func validationError(company Company, problems []string) error {
+	return fmt.Errorf("%w: profile %s: %s", ErrInvalidCompany, company.Profile, strings.Join(problems, ", "))
+}
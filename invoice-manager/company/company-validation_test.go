@@ -0,0 +1,38 @@
+package CompanyData_test
+
+import (
+	CompanyData "moneybringer/invoice-manager/company"
+	"testing"
+)
+
+/* the sample configuration is what a first run uses, it must pass the checks LoadCompanyData runs */
+func TestSampleConfigurationsAreValid(t *testing.T) {
+	for _, path := range []string{"../../config/company.json", "../../examples/company-profiles.json"} {
+		profiles, _, err := CompanyData.LoadProfiles(path)
+		if err != nil {
+			t.Fatalf("%s: %v", path, err)
+		}
+
+		for key, company := range profiles {
+			if problems := CompanyData.Validate(company); len(problems) > 0 {
+				t.Errorf("%s, profile %s: %v", path, key, problems)
+			}
+		}
+	}
+}
+
+func TestInvalidSellerIsReported(t *testing.T) {
+	profiles, _, err := CompanyData.LoadProfiles("../../config/company.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	company := profiles[CompanyData.DEFAULT_PROFILE]
+	company.CompanyDetails.IBAN = "PL 22 2222 2222 2222 2222 2222 2222"
+	company.CompanyDetails.TaxNumber = "PL2222222223"
+
+	problems := CompanyData.Validate(company)
+	if len(problems) != 2 {
+		t.Errorf("problems = %q, want the IBAN and the NIP", problems)
+	}
+}
@@ -3,58 +3,92 @@ package CustomerData
 import (
 	"encoding/json"
 	"fmt"
-	"io"
-	"log"
+	AppErrors "moneybringer/utils/app-errors"
+	AppPaths "moneybringer/utils/app-paths"
 	"os"
+	"strings"
 )
 
+/*
+Address of a customer. Files written before countryCode existed kept the country name
+under "country" (read into Number by mistake), LoadCustomers fills countryCode from it.
+*/
 type Address struct {
 	StreetAddress string `json:"streetAddress"`
+	Number        string `json:"number,omitempty"`
 	State         string `json:"state"`
-	Number        string `json:"country"`
 	ZipCode       string `json:"zipCode"`
 	City          string `json:"city"`
+	Country       string `json:"country,omitempty"`
+	CountryCode   string `json:"countryCode"`
 }
 
+/* TaxNumber is the Polish NIP, VatEuNumber the VAT identification number with its country prefix, e.g. DE123456789 */
 type Customer struct {
-	FullName string  `json:"fullName"`
-	Address  Address `json:"address"`
+	FullName      string  `json:"fullName"`
+	TaxNumber     string  `json:"taxNumber,omitempty"`
+	VatEuNumber   string  `json:"vatEuNumber,omitempty"`
+	Email         string  `json:"email,omitempty"`
+	ContactPerson string  `json:"contactPerson,omitempty"`
+	Address       Address `json:"address"`
 }
 
 type CustomersData struct {
 	Customers map[string]Customer `json:"customers"`
 }
 
-const CUSTOMERS_JSON_PATH = "./config/customers.json"
+const CUSTOMERS_JSON_FILE = "customers.json"
 
-func GetCustomerData(customerName string) Customer {
-	file, osErr := os.Open(CUSTOMERS_JSON_PATH)
+func CustomersJsonPath() string {
+	return AppPaths.ConfigFile(CUSTOMERS_JSON_FILE)
+}
 
-	if osErr != nil {
-		log.Fatal(osErr)
-		os.Exit(1)
+/* LoadCustomers reads the whole customers file without exiting on errors, customers of older files are migrated in memory */
+func LoadCustomers(path string) (CustomersData, error) {
+	customersData, err := readCustomers(path)
+	if err != nil {
+		return customersData, err
 	}
-	defer file.Close()
 
-	jsonData, readErr := io.ReadAll(file)
-	if readErr != nil {
-		log.Fatal(readErr)
-		os.Exit(1)
-	}
+	customersData.migrate()
+
+	return customersData, nil
+}
 
+func readCustomers(path string) (CustomersData, error) {
 	var customersData CustomersData
-	jsonErr := json.Unmarshal(jsonData, &customersData)
-	if jsonErr != nil {
-		fmt.Println("Error unmarshalling JSON:", jsonErr)
-		os.Exit(1)
+
+	jsonData, err := os.ReadFile(path)
+	if err != nil {
+		return customersData, err
+	}
+
+	if err := json.Unmarshal(jsonData, &customersData); err != nil {
+		return customersData, fmt.Errorf("%s: %w", path, err)
+	}
+
+	return customersData, nil
+}
+
+/*
+GetCustomerData returns a valid customer for a new invoice. Errors wrap ErrCustomerNotFound,
+with the closest keys in the message, or AppErrors.ErrConfigInvalid for an unreadable
+customers.json or a customer with invalid data.
+*/
+func GetCustomerData(customerName string) (Customer, error) {
+	customersData, err := LoadCustomers(CustomersJsonPath())
+	if err != nil {
+		return Customer{}, fmt.Errorf("%w: %w", AppErrors.ErrConfigInvalid, err)
 	}
 
-	customer, exists := customersData.Customers[customerName]
-	if !exists {
-		fmt.Printf("Customer: %s does not exist, check your config customers.json file", customerName)
-		os.Exit(1)
+	customer, err := customersData.Find(customerName)
+	if err != nil {
+		return Customer{}, err
+	}
 
+	if problems := Validate(customer); len(problems) > 0 {
+		return Customer{}, fmt.Errorf("%w: %w: %s: %s", AppErrors.ErrConfigInvalid, ErrInvalidCustomer, customerName, strings.Join(problems, ", "))
 	}
 
-	return customer
+	return customer, nil
 }
@@ -0,0 +1,137 @@
+package CustomerData
+
+import (
+	Validation "moneybringer/utils/validation"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+const DEFAULT_COUNTRY_CODE = "PL"
+
+var countryCodePattern = regexp.MustCompile(`^[A-Z]{2}$`)
+
+/* countryNames lists Polish and English country names found in customers.json by ISO 3166 code */
+var countryNames = map[string][]string{
+	"AT": {"austria", "österreich"},
+	"BE": {"belgia", "belgium"},
+	"BG": {"bułgaria", "bulgaria"},
+	"CH": {"szwajcaria", "switzerland"},
+	"CY": {"cypr", "cyprus"},
+	"CZ": {"czechy", "czechia", "czech republic"},
+	"DE": {"niemcy", "germany", "deutschland"},
+	"DK": {"dania", "denmark"},
+	"EE": {"estonia"},
+	"ES": {"hiszpania", "spain"},
+	"FI": {"finlandia", "finland"},
+	"FR": {"francja", "france"},
+	"GB": {"wielka brytania", "united kingdom"},
+	"GR": {"grecja", "greece"},
+	"HR": {"chorwacja", "croatia"},
+	"HU": {"węgry", "hungary"},
+	"IE": {"irlandia", "ireland"},
+	"IT": {"włochy", "italy"},
+	"LT": {"litwa", "lithuania"},
+	"LU": {"luksemburg", "luxembourg"},
+	"LV": {"łotwa", "latvia"},
+	"MT": {"malta"},
+	"NL": {"holandia", "niderlandy", "netherlands"},
+	"NO": {"norwegia", "norway"},
+	"PL": {"polska", "poland"},
+	"PT": {"portugalia", "portugal"},
+	"RO": {"rumunia", "romania"},
+	"SE": {"szwecja", "sweden"},
+	"SI": {"słowenia", "slovenia"},
+	"SK": {"słowacja", "slovakia"},
+	"UA": {"ukraina", "ukraine"},
+	"US": {"stany zjednoczone", "usa", "united states"},
+}
+
+/* CountryCodeOf returns the ISO code of a country name or code, empty when the name is not known */
+func CountryCodeOf(country string) string {
+	country = strings.TrimSpace(country)
+
+	if code := strings.ToUpper(country); countryCodePattern.MatchString(code) {
+		return code
+	}
+
+	for code, names := range countryNames {
+		if slices.Contains(names, strings.ToLower(country)) {
+			return code
+		}
+	}
+
+	return ""
+}
+
+/*
+NormalizeTaxNumber strips separators and the PL prefix from a NIP, "PL 123-456-78-90"
+becomes "1234567890". NormalizeVatEuNumber only strips separators and upper-cases.
+*/
+func NormalizeTaxNumber(taxNumber string) string {
+	return strings.TrimPrefix(NormalizeVatEuNumber(taxNumber), DEFAULT_COUNTRY_CODE)
+}
+
+func NormalizeVatEuNumber(vatEuNumber string) string {
+	return Validation.Normalize(vatEuNumber)
+}
+
+/* Normalized returns the customer with tax numbers and the country code in their canonical form */
+func (customer Customer) Normalized() Customer {
+	customer.TaxNumber = NormalizeTaxNumber(customer.TaxNumber)
+	customer.VatEuNumber = NormalizeVatEuNumber(customer.VatEuNumber)
+	customer.Email = strings.TrimSpace(customer.Email)
+	customer.Address.CountryCode = strings.ToUpper(strings.TrimSpace(customer.Address.CountryCode))
+
+	return customer
+}
+
+/*
+migrate fills countryCode of customers written before it existed. Their country name was
+stored under "country", customers without one were always invoiced as Polish.
+*/
+func (c CustomersData) migrate() []string {
+	var migrated []string
+
+	for _, key := range sortedCustomerKeys(c.Customers) {
+		customer := c.Customers[key]
+		if customer.Address.CountryCode != "" {
+			continue
+		}
+
+		customer.Address.CountryCode = DEFAULT_COUNTRY_CODE
+		if customer.Address.Country != "" {
+			customer.Address.CountryCode = CountryCodeOf(customer.Address.Country)
+		}
+
+		c.Customers[key] = customer
+		migrated = append(migrated, key)
+	}
+
+	return migrated
+}
+
+/* PendingMigration returns the keys of customers MigrateFile would change */
+func PendingMigration(path string) ([]string, error) {
+	customersData, err := readCustomers(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return customersData.migrate(), nil
+}
+
+/* MigrateFile rewrites customers.json in the current format and returns the keys of migrated customers */
+func MigrateFile(path string) ([]string, error) {
+	customersData, err := readCustomers(path)
+	if err != nil {
+		return nil, err
+	}
+
+	migrated := customersData.migrate()
+	if len(migrated) == 0 {
+		return migrated, nil
+	}
+
+	return migrated, SaveCustomers(path, customersData)
+}
@@ -0,0 +1,254 @@
+package CustomerData
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	Validation "moneybringer/utils/validation"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+var ErrCustomerNotFound = errors.New("customer does not exist")
+var ErrCustomerExists = errors.New("customer already exists")
+var ErrInvalidCustomer = errors.New("invalid customer")
+
+var customerKeyPattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_.-]*$`)
+
+/* LoadCustomersOrEmpty is LoadCustomers treating a missing file as an empty registry, used before the first customer is added */
+func LoadCustomersOrEmpty(path string) (CustomersData, error) {
+	customersData, err := LoadCustomers(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return CustomersData{Customers: map[string]Customer{}}, nil
+	}
+
+	if customersData.Customers == nil {
+		customersData.Customers = map[string]Customer{}
+	}
+
+	return customersData, err
+}
+
+/* SaveCustomers writes to a temporary file and renames it, a failed write never leaves a truncated customers.json */
+func SaveCustomers(path string, customersData CustomersData) error {
+	if err := os.MkdirAll(filepath.Dir(path), os.ModePerm); err != nil {
+		return err
+	}
+
+	jsonData, err := json.MarshalIndent(customersData, "", "    ")
+	if err != nil {
+		return err
+	}
+
+	tmpPath := path + ".tmp"
+	if err := os.WriteFile(tmpPath, append(jsonData, '\n'), 0644); err != nil {
+		return err
+	}
+
+	return os.Rename(tmpPath, path)
+}
+
+/* ValidateKey accepts letters, digits, '_', '.' and '-', so keys work unquoted in --customer */
+func ValidateKey(key string) error {
+	if !customerKeyPattern.MatchString(key) {
+		return fmt.Errorf("%w: key %q may only contain letters, digits, '_', '.' and '-'", ErrInvalidCustomer, key)
+	}
+
+	return nil
+}
+
+/* Validate returns the problems of a customer, field names as in customers.json */
+func Validate(customer Customer) []string {
+	problems := []string{}
+
+	required := []struct {
+		name  string
+		value string
+	}{
+		{"fullName", customer.FullName},
+		{"address.streetAddress", customer.Address.StreetAddress},
+		{"address.zipCode", customer.Address.ZipCode},
+		{"address.city", customer.Address.City},
+	}
+
+	for _, field := range required {
+		if strings.TrimSpace(field.value) == "" {
+			problems = append(problems, fmt.Sprintf("%s is empty", field.name))
+		}
+	}
+
+	customer = customer.Normalized()
+
+	if !countryCodePattern.MatchString(customer.Address.CountryCode) {
+		problems = append(problems, fmt.Sprintf("address.countryCode %q is not a two letter ISO code, country %q", customer.Address.CountryCode, customer.Address.Country))
+	}
+
+	/* tax numbers of buyers outside Poland have no common format */
+	if customer.TaxNumber != "" && customer.Address.CountryCode == DEFAULT_COUNTRY_CODE {
+		if err := Validation.ValidateNIP(customer.TaxNumber); err != nil {
+			problems = append(problems, fmt.Sprintf("taxNumber: %v", err))
+		}
+	}
+
+	if customer.VatEuNumber != "" {
+		if err := Validation.ValidateVatEuNumber(customer.VatEuNumber); err != nil {
+			problems = append(problems, fmt.Sprintf("vatEuNumber: %v", err))
+		}
+	}
+
+	if customer.Email != "" && !strings.Contains(customer.Email, "@") {
+		problems = append(problems, fmt.Sprintf("email %q is not an email address", customer.Email))
+	}
+
+	return problems
+}
+
+func (c CustomersData) Add(key string, customer Customer) error {
+	if err := ValidateKey(key); err != nil {
+		return err
+	}
+
+	if _, exists := c.Customers[key]; exists {
+		return fmt.Errorf("%w: %s", ErrCustomerExists, key)
+	}
+
+	if problems := Validate(customer); len(problems) > 0 {
+		return fmt.Errorf("%w: %s", ErrInvalidCustomer, strings.Join(problems, ", "))
+	}
+
+	c.Customers[key] = customer.Normalized()
+
+	return nil
+}
+
+func (c CustomersData) Update(key string, customer Customer) error {
+	if _, err := c.Find(key); err != nil {
+		return err
+	}
+
+	if problems := Validate(customer); len(problems) > 0 {
+		return fmt.Errorf("%w: %s", ErrInvalidCustomer, strings.Join(problems, ", "))
+	}
+
+	c.Customers[key] = customer.Normalized()
+
+	return nil
+}
+
+func (c CustomersData) Remove(key string) error {
+	if _, err := c.Find(key); err != nil {
+		return err
+	}
+
+	delete(c.Customers, key)
+
+	return nil
+}
+
+/*
+Find returns the customer stored under key. Keys differing only in letter case are
+accepted when there is exactly one of them, otherwise the error names the closest keys.
+*/
+func (c CustomersData) Find(key string) (Customer, error) {
+	if customer, exists := c.Customers[key]; exists {
+		return customer, nil
+	}
+
+	var sameCase []string
+	for candidate := range c.Customers {
+		if strings.EqualFold(candidate, key) {
+			sameCase = append(sameCase, candidate)
+		}
+	}
+
+	if len(sameCase) == 1 {
+		return c.Customers[sameCase[0]], nil
+	}
+
+	return Customer{}, fmt.Errorf("%w: %s%s", ErrCustomerNotFound, key, didYouMean(c.Suggest(key)))
+}
+
+/* Suggest returns up to three keys close to key: containing it or within a few typos, closest first */
+func (c CustomersData) Suggest(key string) []string {
+	type match struct {
+		key      string
+		distance int
+	}
+
+	needle := strings.ToLower(key)
+	maxDistance := max(2, len(needle)/3)
+
+	var matches []match
+	for candidate := range c.Customers {
+		lower := strings.ToLower(candidate)
+		distance := editDistance(needle, lower)
+
+		if needle != "" && (strings.Contains(lower, needle) || strings.Contains(needle, lower)) {
+			distance = min(distance, 1)
+		}
+
+		if distance <= maxDistance {
+			matches = append(matches, match{candidate, distance})
+		}
+	}
+
+	sort.Slice(matches, func(i, j int) bool {
+		if matches[i].distance != matches[j].distance {
+			return matches[i].distance < matches[j].distance
+		}
+		return matches[i].key < matches[j].key
+	})
+
+	var suggestions []string
+	for i := 0; i < len(matches) && i < 3; i++ {
+		suggestions = append(suggestions, matches[i].key)
+	}
+
+	return suggestions
+}
+
+func sortedCustomerKeys(customers map[string]Customer) []string {
+	keys := make([]string, 0, len(customers))
+	for key := range customers {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	return keys
+}
+
+func didYouMean(suggestions []string) string {
+	if len(suggestions) == 0 {
+		return ""
+	}
+
+	return fmt.Sprintf(", did you mean %s?", strings.Join(suggestions, " or "))
+}
+
+/* editDistance is the Levenshtein distance between a and b */
+func editDistance(a string, b string) int {
+	source, target := []rune(a), []rune(b)
+	previous := make([]int, len(target)+1)
+	current := make([]int, len(target)+1)
+
+	for j := range previous {
+		previous[j] = j
+	}
+
+	for i := 1; i <= len(source); i++ {
+		current[0] = i
+		for j := 1; j <= len(target); j++ {
+			cost := 1
+			if source[i-1] == target[j-1] {
+				cost = 0
+			}
+			current[j] = min(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+		}
+		previous, current = current, previous
+	}
+
+	return previous[len(target)]
+}
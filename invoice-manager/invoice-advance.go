@@ -0,0 +1,175 @@
+package InvoiceManager
+
+import (
+	"errors"
+	"fmt"
+	Money "moneybringer/utils/money"
+)
+
+/*
+InvoiceAdvance is kept on an advance invoice (faktura zaliczkowa). The positions and
+OrderSummary describe the whole order, InvoiceSummary holds the received amount split
+per VAT rate (art. 106f VAT act).
+*/
+type InvoiceAdvance struct {
+	PaymentDate        string
+	ReceivedAmount     Money.Money
+	OrderSummary       InvoiceSummary
+	SettledByInvoiceNo string `json:",omitempty"`
+}
+
+type AdvanceReference struct {
+	InvoiceNo           string
+	DateOfIssue         string
+	KsefReferenceNumber string
+	Summary             InvoiceSummary
+}
+
+/*
+InvoiceSettlement is kept on a final invoice (faktura rozliczeniowa), InvoiceSummary
+holds the order value less the advances listed here, per VAT rate.
+*/
+type InvoiceSettlement struct {
+	OrderSummary InvoiceSummary
+	Advances     []AdvanceReference
+}
+
+var ErrInvalidAdvance = errors.New("invalid advance")
+
+/* AsAdvance turns a prepared invoice for the whole order into an advance invoice for the received gross amount */
+func AsAdvance(invoice InvoiceCreatedData, paymentDate string, receivedAmount Money.Money) (InvoiceCreatedData, error) {
+	orderSummary := invoice.InvoiceSummary
+	orderSummary.VatBreakdown = VatBreakdownOf(invoice)
+	currency := CurrencyOf(invoice)
+
+	if invoice.Kind() != DOCUMENT_KIND_INVOICE {
+		return invoice, fmt.Errorf("%w: only a regular invoice can become an advance invoice", ErrInvalidAdvance)
+	}
+
+	companyData, err := getInvoiceCompanyData(invoice)
+	if err != nil {
+		return invoice, err
+	}
+
+	if receivedAmount.Currency == "" {
+		receivedAmount.Currency = currency
+	}
+
+	if receivedAmount.Currency != currency {
+		return invoice, fmt.Errorf("%w: received amount is in %s, the order in %s", ErrInvalidAdvance, receivedAmount.Currency, currency)
+	}
+
+	if receivedAmount.Amount <= 0 || receivedAmount.Amount > orderSummary.TotalGrossValue.Amount {
+		return invoice, fmt.Errorf("%w: received amount must be greater than 0 and at most the order value %s %s", ErrInvalidAdvance, orderSummary.TotalGrossValue, currency)
+	}
+
+	invoice.DocumentKind = DOCUMENT_KIND_ADVANCE
+	invoice.InvoiceSummary = getAdvanceSummary(orderSummary, receivedAmount, companyData.InvoiceDetails.AmountInWordsLanguage)
+	invoice.Advance = &InvoiceAdvance{
+		PaymentDate:    paymentDate,
+		ReceivedAmount: receivedAmount,
+		OrderSummary:   orderSummary,
+	}
+
+	return invoice, nil
+}
+
+/*
+getAdvanceSummary splits the received gross amount between VAT rates in proportion to
+the gross order value and takes the VAT out of each share, gross * rate / (100 + rate).
+*/
+func getAdvanceSummary(orderSummary InvoiceSummary, receivedAmount Money.Money, language string) InvoiceSummary {
+	currency := receivedAmount.Currency
+	remaining := receivedAmount
+	totalAmount := Money.Zero(currency)
+	totalTaxAmount := Money.Zero(currency)
+
+	var vatBreakdown []VatRateSummary
+	for i, rateSummary := range orderSummary.VatBreakdown {
+		grossShare := remaining
+		if i < len(orderSummary.VatBreakdown)-1 {
+			grossShare = receivedAmount.MulRatio(rateSummary.GrossValue.Amount, orderSummary.TotalGrossValue.Amount)
+		}
+		remaining = remaining.Sub(grossShare)
+
+		taxAmount := Money.Zero(currency)
+		if percent := int64(rateSummary.TaxRate.Percent()); percent > 0 {
+			taxAmount = grossShare.MulRatio(percent, 100+percent)
+		}
+
+		vatBreakdown = append(vatBreakdown, VatRateSummary{
+			TaxRate:    rateSummary.TaxRate,
+			NetValue:   grossShare.Sub(taxAmount),
+			TaxAmount:  taxAmount,
+			GrossValue: grossShare,
+		})
+		totalAmount = totalAmount.Add(grossShare.Sub(taxAmount))
+		totalTaxAmount = totalTaxAmount.Add(taxAmount)
+	}
+
+	return InvoiceSummary{
+		TotalAmount:     totalAmount,
+		TotalTaxAmount:  totalTaxAmount,
+		TotalGrossValue: receivedAmount,
+		GrossInWords:    getAmountInWords(receivedAmount, language),
+		VatBreakdown:    vatBreakdown,
+	}
+}
+
+/* SettleAdvances turns a prepared invoice for the whole order into the final invoice deducting the given advance invoices */
+func SettleAdvances(invoice InvoiceCreatedData, advances []InvoiceCreatedData) (InvoiceCreatedData, error) {
+	orderSummary := invoice.InvoiceSummary
+	orderSummary.VatBreakdown = VatBreakdownOf(invoice)
+	currency := CurrencyOf(invoice)
+
+	if invoice.Kind() != DOCUMENT_KIND_INVOICE {
+		return invoice, fmt.Errorf("%w: only a regular invoice can settle advances", ErrInvalidAdvance)
+	}
+
+	if len(advances) == 0 {
+		return invoice, fmt.Errorf("%w: a final invoice needs at least one advance invoice", ErrInvalidAdvance)
+	}
+
+	companyData, err := getInvoiceCompanyData(invoice)
+	if err != nil {
+		return invoice, err
+	}
+
+	settlement := &InvoiceSettlement{OrderSummary: orderSummary}
+	remaining := orderSummary
+
+	for _, advance := range advances {
+		switch {
+		case advance.Kind() != DOCUMENT_KIND_ADVANCE || advance.Advance == nil:
+			return invoice, fmt.Errorf("%w: %s is not an advance invoice", ErrInvalidAdvance, advance.InvoiceNo)
+		case advance.Advance.SettledByInvoiceNo != "":
+			return invoice, fmt.Errorf("%w: %s was already settled by %s", ErrInvalidAdvance, advance.InvoiceNo, advance.Advance.SettledByInvoiceNo)
+		case advance.SellerProfile() != invoice.SellerProfile():
+			return invoice, fmt.Errorf("%w: %s was issued by another seller profile", ErrInvalidAdvance, advance.InvoiceNo)
+		case advance.InvoiceTo.FullName != invoice.InvoiceTo.FullName:
+			return invoice, fmt.Errorf("%w: %s was issued for %s", ErrInvalidAdvance, advance.InvoiceNo, advance.InvoiceTo.FullName)
+		case CurrencyOf(advance) != currency:
+			return invoice, fmt.Errorf("%w: %s is in %s, the order in %s", ErrInvalidAdvance, advance.InvoiceNo, CurrencyOf(advance), currency)
+		}
+
+		settlement.Advances = append(settlement.Advances, AdvanceReference{
+			InvoiceNo:           advance.InvoiceNo,
+			DateOfIssue:         advance.DateOfIssue,
+			KsefReferenceNumber: advance.KsefReferenceNumber,
+			Summary:             advance.InvoiceSummary,
+		})
+		remaining = getSummaryDifference(advance.InvoiceSummary, remaining, "")
+	}
+
+	if remaining.TotalGrossValue.Amount < 0 {
+		return invoice, fmt.Errorf("%w: advances exceed the order value %s %s", ErrInvalidAdvance, orderSummary.TotalGrossValue, currency)
+	}
+
+	remaining.GrossInWords = getAmountInWords(remaining.TotalGrossValue, companyData.InvoiceDetails.AmountInWordsLanguage)
+
+	invoice.DocumentKind = DOCUMENT_KIND_FINAL
+	invoice.InvoiceSummary = remaining
+	invoice.Settlement = settlement
+
+	return invoice, nil
+}
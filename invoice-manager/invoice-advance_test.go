@@ -0,0 +1,177 @@
+package InvoiceManager_test
+
+import (
+	"errors"
+	InvoiceManager "moneybringer/invoice-manager"
+	"testing"
+)
+
+/* order is a prepared invoice for the whole order, 23% and 8% */
+func order(t *testing.T) InvoiceManager.InvoiceCreatedData {
+	t.Helper()
+
+	invoice := InvoiceManager.InvoiceCreatedData{InvoiceNo: "5/10/2026", Profile: "default", DateOfIssue: "20-10-2026", Currency: "PLN"}
+	invoice.InvoiceTo.FullName = "Some Company Inc"
+	invoice.InvoiceSummary = InvoiceManager.InvoiceSummary{
+		TotalAmount:     amount(t, "1500.00", "PLN"),
+		TotalTaxAmount:  amount(t, "270.00", "PLN"),
+		TotalGrossValue: amount(t, "1770.00", "PLN"),
+		VatBreakdown: []InvoiceManager.VatRateSummary{
+			rateRow(t, 23, "1000.00", "230.00", "PLN"),
+			rateRow(t, 8, "500.00", "40.00", "PLN"),
+		},
+	}
+
+	return invoice
+}
+
+func advanceOf(t *testing.T, orderInvoice InvoiceManager.InvoiceCreatedData, number string, received string) InvoiceManager.InvoiceCreatedData {
+	t.Helper()
+
+	advance, err := InvoiceManager.AsAdvance(orderInvoice, "15-10-2026", amount(t, received, "PLN"))
+	if err != nil {
+		t.Fatalf("AsAdvance(%s): %v", received, err)
+	}
+	advance.InvoiceNo = number
+
+	return advance
+}
+
+type expectedRate struct {
+	net   string
+	tax   string
+	gross string
+}
+
+func assertSummary(t *testing.T, name string, summary InvoiceManager.InvoiceSummary, rates []expectedRate, total expectedRate) {
+	t.Helper()
+
+	if len(summary.VatBreakdown) != len(rates) {
+		t.Fatalf("%s: %d rates, want %d", name, len(summary.VatBreakdown), len(rates))
+	}
+	for i, rate := range rates {
+		row := summary.VatBreakdown[i]
+		assertMoney(t, name+" net "+string(row.TaxRate), row.NetValue, rate.net)
+		assertMoney(t, name+" VAT "+string(row.TaxRate), row.TaxAmount, rate.tax)
+		assertMoney(t, name+" gross "+string(row.TaxRate), row.GrossValue, rate.gross)
+	}
+	assertMoney(t, name+" total net", summary.TotalAmount, total.net)
+	assertMoney(t, name+" total VAT", summary.TotalTaxAmount, total.tax)
+	assertMoney(t, name+" total gross", summary.TotalGrossValue, total.gross)
+}
+
+func TestAsAdvanceSplitsReceivedAmountBetweenRates(t *testing.T) {
+	useTestConfig(t)
+
+	advance := advanceOf(t, order(t), "ZAL/1/10/2026", "1000.00")
+
+	if advance.Kind() != InvoiceManager.DOCUMENT_KIND_ADVANCE || advance.Advance == nil || advance.Advance.PaymentDate != "15-10-2026" {
+		t.Fatalf("advance = %s %+v", advance.Kind(), advance.Advance)
+	}
+	assertMoney(t, "order kept on the advance", advance.Advance.OrderSummary.TotalGrossValue, "1770.00")
+
+	/* 1000.00 * 1230/1770 = 694.915 for 23%, the last rate takes the rest; VAT is gross * rate / (100 + rate) */
+	assertSummary(t, "advance", advance.InvoiceSummary,
+		[]expectedRate{{"564.98", "129.94", "694.92"}, {"282.48", "22.60", "305.08"}},
+		expectedRate{"847.46", "152.54", "1000.00"})
+}
+
+func TestAsAdvanceGivesRoundingRemainderToLastRate(t *testing.T) {
+	useTestConfig(t)
+
+	orderInvoice := order(t)
+	orderInvoice.InvoiceSummary = InvoiceManager.InvoiceSummary{
+		TotalAmount:     amount(t, "276.35", "PLN"),
+		TotalTaxAmount:  amount(t, "23.65", "PLN"),
+		TotalGrossValue: amount(t, "300.00", "PLN"),
+		VatBreakdown: []InvoiceManager.VatRateSummary{
+			rateRow(t, 23, "81.30", "18.70", "PLN"),
+			rateRow(t, 5, "95.24", "4.76", "PLN"),
+			rateRow(t, 0, "99.81", "0.19", "PLN"),
+		},
+	}
+
+	/* every share is 33.333..., rounding each one would leave a grosz out */
+	advance := advanceOf(t, orderInvoice, "ZAL/1/10/2026", "100.00")
+	assertSummary(t, "advance", advance.InvoiceSummary,
+		[]expectedRate{{"27.10", "6.23", "33.33"}, {"31.74", "1.59", "33.33"}, {"33.34", "0.00", "33.34"}},
+		expectedRate{"92.18", "7.82", "100.00"})
+}
+
+func TestAsAdvanceRefusesInvalidAmounts(t *testing.T) {
+	useTestConfig(t)
+
+	for _, received := range []string{"0.00", "-10.00", "1770.01"} {
+		if _, err := InvoiceManager.AsAdvance(order(t), "15-10-2026", amount(t, received, "PLN")); !errors.Is(err, InvoiceManager.ErrInvalidAdvance) {
+			t.Errorf("advance of %s = %v, want ErrInvalidAdvance", received, err)
+		}
+	}
+
+	if _, err := InvoiceManager.AsAdvance(order(t), "15-10-2026", amount(t, "100.00", "EUR")); !errors.Is(err, InvoiceManager.ErrInvalidAdvance) {
+		t.Errorf("advance in EUR of a PLN order = %v, want ErrInvalidAdvance", err)
+	}
+
+	advance := advanceOf(t, order(t), "ZAL/1/10/2026", "100.00")
+	if _, err := InvoiceManager.AsAdvance(advance, "15-10-2026", amount(t, "100.00", "PLN")); !errors.Is(err, InvoiceManager.ErrInvalidAdvance) {
+		t.Errorf("advance of an advance invoice = %v, want ErrInvalidAdvance", err)
+	}
+}
+
+func TestSettleAdvancesDeductsEveryAdvancePerRate(t *testing.T) {
+	useTestConfig(t)
+
+	first := advanceOf(t, order(t), "ZAL/1/10/2026", "1000.00")
+	second := advanceOf(t, order(t), "ZAL/2/10/2026", "270.00")
+	/* stored before seller profiles existed, it belongs to the default profile */
+	second.Profile = ""
+
+	final, err := InvoiceManager.SettleAdvances(order(t), []InvoiceManager.InvoiceCreatedData{first, second})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if final.Kind() != InvoiceManager.DOCUMENT_KIND_FINAL || final.Settlement == nil || len(final.Settlement.Advances) != 2 {
+		t.Fatalf("final invoice = %s %+v", final.Kind(), final.Settlement)
+	}
+	if final.Settlement.Advances[1].InvoiceNo != "ZAL/2/10/2026" {
+		t.Errorf("settled advances = %+v", final.Settlement.Advances)
+	}
+	assertMoney(t, "order kept on the final invoice", final.Settlement.OrderSummary.TotalGrossValue, "1770.00")
+
+	/* 270.00 splits into 187.63 (35.09 VAT) at 23% and 82.37 (6.10 VAT) at 8% */
+	assertSummary(t, "final", final.InvoiceSummary,
+		[]expectedRate{{"282.48", "64.97", "347.45"}, {"141.25", "11.30", "152.55"}},
+		expectedRate{"423.73", "76.27", "500.00"})
+}
+
+func TestSettleAdvancesRefusesOtherAdvances(t *testing.T) {
+	useTestConfig(t)
+
+	settled := advanceOf(t, order(t), "ZAL/1/10/2026", "100.00")
+	settled.Advance.SettledByInvoiceNo = "4/10/2026"
+
+	otherProfile := advanceOf(t, order(t), "ZAL/2/10/2026", "100.00")
+	otherProfile.Profile = "acme"
+
+	otherCustomer := advanceOf(t, order(t), "ZAL/3/10/2026", "100.00")
+	otherCustomer.InvoiceTo.FullName = "Other Company"
+
+	otherCurrency := advanceOf(t, order(t), "ZAL/4/10/2026", "100.00")
+	otherCurrency.Currency = "EUR"
+
+	cases := map[string][]InvoiceManager.InvoiceCreatedData{
+		"no advances":            nil,
+		"not an advance":         {order(t)},
+		"settled before":         {settled},
+		"another seller profile": {otherProfile},
+		"another customer":       {otherCustomer},
+		"another currency":       {otherCurrency},
+		"more than the order":    {advanceOf(t, order(t), "ZAL/5/10/2026", "1000.00"), advanceOf(t, order(t), "ZAL/6/10/2026", "1000.00")},
+	}
+
+	for name, advances := range cases {
+		if _, err := InvoiceManager.SettleAdvances(order(t), advances); !errors.Is(err, InvoiceManager.ErrInvalidAdvance) {
+			t.Errorf("%s = %v, want ErrInvalidAdvance", name, err)
+		}
+	}
+}
@@ -0,0 +1,64 @@
+package InvoiceManager
+
+import (
+	CustomerData "moneybringer/invoice-manager/customer"
+	Validation "moneybringer/utils/validation"
+)
+
+/* Transaction types tell apart the sales taxed at 0%, each goes to its own FA(2) and JPK field */
+const TRANSACTION_DOMESTIC = "domestic"
+const TRANSACTION_INTRA_EU_SUPPLY = "intra-eu-supply"
+const TRANSACTION_EXPORT = "export"
+
+/*
+BuyerAddressOf returns the buyer address with its country code. Invoices stored before
+the country code existed copied the customer's country name into Number, the country
+code is then derived from that name and Polish when there is none.
+*/
+func BuyerAddressOf(invoice InvoiceCreatedData) CustomerAddress {
+	address := invoice.InvoiceTo.Address
+	if address.CountryCode != "" || address.Country != "" {
+		return address
+	}
+
+	address.Country, address.Number = address.Number, ""
+	address.CountryCode = CustomerData.CountryCodeOf(address.Country)
+	if address.CountryCode == "" {
+		address.CountryCode = CustomerData.DEFAULT_COUNTRY_CODE
+	}
+
+	return address
+}
+
+/* IsDomesticBuyer reports whether the buyer is identified by a Polish NIP */
+func IsDomesticBuyer(invoice InvoiceCreatedData) bool {
+	return invoice.InvoiceTo.TaxNumber != "" && BuyerAddressOf(invoice).CountryCode == CustomerData.DEFAULT_COUNTRY_CODE
+}
+
+/*
+TransactionTypeOf derives the kind of sale from the buyer: an intra-EU supply (WDT) to a buyer
+with the EU VAT number of another member state, an export to a buyer outside the EU and a
+domestic sale otherwise.
+*/
+func TransactionTypeOf(invoice InvoiceCreatedData) string {
+	if IsDomesticBuyer(invoice) {
+		return TRANSACTION_DOMESTIC
+	}
+
+	vatEuNumber := CustomerData.NormalizeVatEuNumber(invoice.InvoiceTo.VatEuNumber)
+	if len(vatEuNumber) > 2 && vatEuNumber[:2] != CustomerData.DEFAULT_COUNTRY_CODE && Validation.IsVatEuPrefix(vatEuNumber[:2]) {
+		return TRANSACTION_INTRA_EU_SUPPLY
+	}
+
+	countryCode := BuyerAddressOf(invoice).CountryCode
+	if countryCode != CustomerData.DEFAULT_COUNTRY_CODE && !isEuCountry(countryCode) {
+		return TRANSACTION_EXPORT
+	}
+
+	return TRANSACTION_DOMESTIC
+}
+
+/* isEuCountry reports member states by ISO country code, Greece uses EL only in VAT numbers */
+func isEuCountry(countryCode string) bool {
+	return countryCode == "GR" || (countryCode != "XI" && countryCode != "EL" && Validation.IsVatEuPrefix(countryCode))
+}
@@ -0,0 +1,240 @@
+package InvoiceManager
+
+import (
+	"errors"
+	"fmt"
+	CompanyData "moneybringer/invoice-manager/company"
+	Invoice "moneybringer/invoice-manager/invoice"
+	InvoiceSpec "moneybringer/invoice-manager/spec"
+	TaxRate "moneybringer/invoice-manager/tax-rate"
+	Money "moneybringer/utils/money"
+	Prompt "moneybringer/utils/prompt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+/*
+InvoiceCorrection links a correction invoice (faktura korygująca) with the corrected invoice.
+The correction keeps the positions after correction in InvoicePositions and the
+difference per VAT rate in InvoiceSummary, so registers such as JPK pick up the change.
+*/
+type InvoiceCorrection struct {
+	OriginalInvoiceNo           string
+	OriginalDateOfIssue         string
+	OriginalKsefReferenceNumber string
+	Reason                      string
+	PositionsBefore             []Invoice.InvoicePosition
+	SummaryBefore               InvoiceSummary
+	SummaryAfter                InvoiceSummary
+}
+
+var ErrNothingToCorrect = errors.New("positions after correction are the same as before")
+var ErrCorrectingCorrection = errors.New("a correction invoice cannot be corrected, correct the original invoice instead")
+var ErrCorrectingProforma = errors.New("a proforma is not a VAT invoice and cannot be corrected, issue a new proforma instead")
+var ErrCorrectingAdvance = errors.New("correcting advance and final invoices is not supported")
+
+/* CurrentState applies earlier corrections, ordered by date of issue, to the original invoice */
+func CurrentState(original InvoiceCreatedData, corrections []InvoiceCreatedData) InvoiceCreatedData {
+	current := original
+
+	for _, correction := range corrections {
+		if correction.Correction == nil {
+			continue
+		}
+
+		current.InvoicePositions = correction.InvoicePositions
+		current.InvoiceSummary = correction.Correction.SummaryAfter
+	}
+
+	return current
+}
+
+func CreateCorrection(current InvoiceCreatedData, reason string) (InvoiceCreatedData, error) {
+	if err := checkCorrectable(current); err != nil {
+		return InvoiceCreatedData{}, err
+	}
+
+	companyData, err := getInvoiceCompanyData(current)
+	if err != nil {
+		return InvoiceCreatedData{}, err
+	}
+	dateOfIssue, err := getDateOfIssue()
+	if err != nil {
+		return InvoiceCreatedData{}, err
+	}
+
+	if strings.TrimSpace(reason) == "" {
+		reason = getCorrectionReason()
+	}
+
+	var positionsAfter []Invoice.InvoicePosition
+	for _, position := range current.InvoicePositions {
+		if corrected, keep := Invoice.CorrectInvoicePosition(position); keep {
+			positionsAfter = append(positionsAfter, corrected)
+		}
+	}
+
+	if Prompt.Confirm("Should add new positions? y/N (yes, no)") {
+		positionsAfter = append(positionsAfter, Invoice.GetInvoicePositions(companyData.InvoicePosition, CurrencyOf(current))...)
+	}
+
+	return newCorrection(current, companyData, dateOfIssue, reason, positionsAfter)
+}
+
+func CreateCorrectionFromSpec(current InvoiceCreatedData, spec InvoiceSpec.CorrectionSpec) (InvoiceCreatedData, error) {
+	if err := checkCorrectable(current); err != nil {
+		return InvoiceCreatedData{}, err
+	}
+
+	companyData, err := getInvoiceCompanyData(current)
+	if err != nil {
+		return InvoiceCreatedData{}, err
+	}
+	positionsAfter, err := getInvoicePositionsFromSpec(spec.Positions, companyData.InvoicePosition, CurrencyOf(current))
+	if err != nil {
+		return InvoiceCreatedData{}, err
+	}
+
+	return newCorrection(current, companyData, spec.DateOfIssue, spec.Reason, positionsAfter)
+}
+
+func checkCorrectable(current InvoiceCreatedData) error {
+	switch current.Kind() {
+	case DOCUMENT_KIND_CORRECTION:
+		return ErrCorrectingCorrection
+	case DOCUMENT_KIND_PROFORMA:
+		return ErrCorrectingProforma
+	case DOCUMENT_KIND_ADVANCE, DOCUMENT_KIND_FINAL:
+		return ErrCorrectingAdvance
+	}
+
+	return nil
+}
+
+func newCorrection(current InvoiceCreatedData, companyData CompanyData.Company, dateOfIssue string, reason string, positionsAfter []Invoice.InvoicePosition) (InvoiceCreatedData, error) {
+	for i := range positionsAfter {
+		positionsAfter[i].ItemNo = i + 1
+	}
+
+	if reflect.DeepEqual(current.InvoicePositions, positionsAfter) {
+		return InvoiceCreatedData{}, ErrNothingToCorrect
+	}
+
+	if err := Invoice.CheckExemptionBasis(positionsAfter); err != nil {
+		return InvoiceCreatedData{}, err
+	}
+
+	/* positions after correction stay in the currency of the corrected invoice */
+	currency := CurrencyOf(current)
+	summaryBefore := current.InvoiceSummary
+	summaryBefore.VatBreakdown = VatBreakdownOf(current)
+	summaryAfter, err := getSummaryAfter(positionsAfter, companyData.InvoiceDetails, currency)
+	if err != nil {
+		return InvoiceCreatedData{}, err
+	}
+
+	correctionNo, err := previewNumber(profileSeries(companyData, CORRECTION_SERIES), getCorrectionNumberPattern(companyData), dateOfIssue)
+	if err != nil {
+		return InvoiceCreatedData{}, err
+	}
+
+	correction := current
+	correction.DocumentKind = DOCUMENT_KIND_CORRECTION
+	correction.Currency = currency
+	correction.InvoiceNo = correctionNo
+	correction.DateOfIssue = dateOfIssue
+	correction.PlaceOfIssue = companyData.InvoiceDetails.DefaultPlaceOfIssue
+	correction.Payment.Deadline = getDefaultPaymentDeadline(companyData.Payment.PeriodInDays, dateOfIssue)
+	correction.InvoicePositions = positionsAfter
+	correction.InvoiceSummary = getSummaryDifference(summaryBefore, summaryAfter, companyData.InvoiceDetails.AmountInWordsLanguage)
+	correction.Notes = ""
+	correction.IssuedAnInvoice = fmt.Sprintf("%s %s", companyData.PersonalDetails.FirstName, companyData.PersonalDetails.LastName)
+	correction.AuthorFirstName = companyData.PersonalDetails.FirstName
+	correction.AuthorLastName = companyData.PersonalDetails.LastName
+	correction.KsefReferenceNumber = ""
+	correction.ProformaNo = ""
+	correction.ConvertedToInvoiceNo = ""
+	correction.Correction = &InvoiceCorrection{
+		OriginalInvoiceNo:           current.InvoiceNo,
+		OriginalDateOfIssue:         current.DateOfIssue,
+		OriginalKsefReferenceNumber: current.KsefReferenceNumber,
+		Reason:                      reason,
+		PositionsBefore:             current.InvoicePositions,
+		SummaryBefore:               summaryBefore,
+		SummaryAfter:                summaryAfter,
+	}
+
+	return correction, nil
+}
+
+/* A correction may remove every position, the summary after is then zero in the original currency */
+func getSummaryAfter(positions []Invoice.InvoicePosition, invoiceDetails CompanyData.InvoiceDetails, currency string) (InvoiceSummary, error) {
+	if len(positions) > 0 {
+		return getInvoiceSummary(positions, currency, invoiceDetails)
+	}
+
+	zero := Money.Zero(currency)
+
+	return InvoiceSummary{
+		TotalAmount:     zero,
+		TotalTaxAmount:  zero,
+		TotalGrossValue: zero,
+		GrossInWords:    getAmountInWords(zero, invoiceDetails.AmountInWordsLanguage),
+	}, nil
+}
+
+/* getSummaryDifference returns after minus before, per VAT rate and in total; negative values decrease the tax base */
+func getSummaryDifference(before InvoiceSummary, after InvoiceSummary, language string) InvoiceSummary {
+	currency := before.TotalGrossValue.Currency
+	differences := map[TaxRate.TaxRate]VatRateSummary{}
+
+	apply := func(rateSummary VatRateSummary, subtract bool) {
+		difference, exists := differences[rateSummary.TaxRate]
+		if !exists {
+			difference = VatRateSummary{TaxRate: rateSummary.TaxRate, NetValue: Money.Zero(currency), TaxAmount: Money.Zero(currency), GrossValue: Money.Zero(currency)}
+		}
+
+		if subtract {
+			rateSummary.NetValue, rateSummary.TaxAmount, rateSummary.GrossValue = rateSummary.NetValue.Neg(), rateSummary.TaxAmount.Neg(), rateSummary.GrossValue.Neg()
+		}
+
+		difference.NetValue = difference.NetValue.Add(rateSummary.NetValue)
+		difference.TaxAmount = difference.TaxAmount.Add(rateSummary.TaxAmount)
+		difference.GrossValue = difference.GrossValue.Add(rateSummary.GrossValue)
+		differences[rateSummary.TaxRate] = difference
+	}
+
+	for _, rateSummary := range before.VatBreakdown {
+		apply(rateSummary, true)
+	}
+	for _, rateSummary := range after.VatBreakdown {
+		apply(rateSummary, false)
+	}
+
+	var vatBreakdown []VatRateSummary
+	for _, difference := range differences {
+		if difference.NetValue.IsZero() && difference.TaxAmount.IsZero() {
+			continue
+		}
+		vatBreakdown = append(vatBreakdown, difference)
+	}
+
+	sort.SliceStable(vatBreakdown, func(i, j int) bool {
+		return TaxRate.Less(vatBreakdown[i].TaxRate, vatBreakdown[j].TaxRate)
+	})
+
+	totalGrossValue := after.TotalGrossValue.Sub(before.TotalGrossValue)
+
+	return InvoiceSummary{
+		TotalAmount:     after.TotalAmount.Sub(before.TotalAmount),
+		TotalTaxAmount:  after.TotalTaxAmount.Sub(before.TotalTaxAmount),
+		TotalGrossValue: totalGrossValue,
+		GrossInWords:    getAmountInWords(totalGrossValue, language),
+		VatBreakdown:    vatBreakdown,
+	}
+}
+
+func getCorrectionReason() string {
+	return Prompt.String("Enter reason for correction:", "")
+}
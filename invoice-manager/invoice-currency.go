@@ -0,0 +1,150 @@
+package InvoiceManager
+
+import (
+	"errors"
+	"fmt"
+	ExchangeRate "moneybringer/exchange-rate"
+	Invoice "moneybringer/invoice-manager/invoice"
+	Money "moneybringer/utils/money"
+	TimeUtils "moneybringer/utils/time"
+	"regexp"
+	"strings"
+)
+
+const PLN = "PLN"
+
+/* CurrencyConversion keeps the rate used for a foreign currency invoice and its summary recalculated to PLN */
+type CurrencyConversion struct {
+	Rate    ExchangeRate.Rate
+	Summary InvoiceSummary
+}
+
+var ErrMixedCurrency = errors.New("all positions of an invoice must be in the invoice currency")
+
+var currencyRegexp = regexp.MustCompile(`^[A-Z]{3}$`)
+
+/* CurrencyOf also covers raw invoices stored before the currency was kept on the invoice */
+func CurrencyOf(invoice InvoiceCreatedData) string {
+	switch {
+	case invoice.Currency != "":
+		return invoice.Currency
+	case invoice.InvoiceSummary.TotalGrossValue.Currency != "":
+		return invoice.InvoiceSummary.TotalGrossValue.Currency
+	case len(invoice.InvoicePositions) > 0 && invoice.InvoicePositions[0].Currency != "":
+		return invoice.InvoicePositions[0].Currency
+	}
+
+	return PLN
+}
+
+/* ParseCurrency accepts a three letter ISO 4217 code in any case */
+func ParseCurrency(value string) (string, error) {
+	currency := strings.ToUpper(strings.TrimSpace(value))
+	if !currencyRegexp.MatchString(currency) {
+		return "", fmt.Errorf("invalid currency %q, expected a three letter ISO 4217 code such as PLN or EUR", value)
+	}
+
+	return currency, nil
+}
+
+/* CheckCurrency refuses invoices whose positions are not all in the invoice currency, e.g. older raw files */
+func CheckCurrency(invoice InvoiceCreatedData) error {
+	currency := CurrencyOf(invoice)
+
+	if err := checkPositionCurrency(invoice.InvoicePositions, currency); err != nil {
+		return fmt.Errorf("invoice %s: %w", invoice.InvoiceNo, err)
+	}
+
+	if invoice.Correction != nil {
+		if err := checkPositionCurrency(invoice.Correction.PositionsBefore, currency); err != nil {
+			return fmt.Errorf("invoice %s, positions before correction: %w", invoice.InvoiceNo, err)
+		}
+	}
+
+	return nil
+}
+
+func checkPositionCurrency(positions []Invoice.InvoicePosition, currency string) error {
+	for _, position := range positions {
+		if position.Currency != currency {
+			return fmt.Errorf("%w: position %d %q is in %s, the invoice in %s", ErrMixedCurrency, position.ItemNo, position.ProductOrServiceName, position.Currency, currency)
+		}
+	}
+
+	return nil
+}
+
+/*
+ApplyExchangeRate sets the PLN conversion of a foreign currency invoice. The rate is
+the one from the working day before the date of issue, for an advance invoice before
+the day the payment was received. Corrections keep the rate of the corrected invoice.
+*/
+func ApplyExchangeRate(invoice *InvoiceCreatedData, provider ExchangeRate.Provider) error {
+	if CurrencyOf(*invoice) == PLN || invoice.Kind() == DOCUMENT_KIND_PROFORMA {
+		invoice.Conversion = nil
+		return nil
+	}
+
+	var rate ExchangeRate.Rate
+	if invoice.Kind() == DOCUMENT_KIND_CORRECTION && invoice.Conversion != nil {
+		rate = invoice.Conversion.Rate
+	} else {
+		rateDate := invoice.DateOfIssue
+		if invoice.Advance != nil {
+			rateDate = invoice.Advance.PaymentDate
+		}
+
+		date, err := TimeUtils.ParseDdMmYyyy(rateDate)
+		if err != nil {
+			return err
+		}
+
+		rate, err = provider.RateBefore(CurrencyOf(*invoice), date)
+		if err != nil {
+			return err
+		}
+	}
+
+	summary, err := convertSummary(invoice.InvoiceSummary, rate)
+	if err != nil {
+		return err
+	}
+
+	invoice.Conversion = &CurrencyConversion{Rate: rate, Summary: summary}
+
+	return nil
+}
+
+/* convertSummary converts the net value and VAT of every rate row, gross values and totals are sums of the converted amounts */
+func convertSummary(summary InvoiceSummary, rate ExchangeRate.Rate) (InvoiceSummary, error) {
+	converted := InvoiceSummary{
+		TotalAmount:     Money.Zero(PLN),
+		TotalTaxAmount:  Money.Zero(PLN),
+		TotalGrossValue: Money.Zero(PLN),
+	}
+
+	for _, rateSummary := range summary.VatBreakdown {
+		netValue, err := rate.ToPLN(rateSummary.NetValue)
+		if err != nil {
+			return converted, err
+		}
+
+		taxAmount, err := rate.ToPLN(rateSummary.TaxAmount)
+		if err != nil {
+			return converted, err
+		}
+
+		converted.VatBreakdown = append(converted.VatBreakdown, VatRateSummary{
+			TaxRate:    rateSummary.TaxRate,
+			NetValue:   netValue,
+			TaxAmount:  taxAmount,
+			GrossValue: netValue.Add(taxAmount),
+		})
+		converted.TotalAmount = converted.TotalAmount.Add(netValue)
+		converted.TotalTaxAmount = converted.TotalTaxAmount.Add(taxAmount)
+	}
+
+	converted.TotalGrossValue = converted.TotalAmount.Add(converted.TotalTaxAmount)
+
+	return converted, nil
+}
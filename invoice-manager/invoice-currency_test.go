@@ -0,0 +1,159 @@
+package InvoiceManager_test
+
+import (
+	"errors"
+	ExchangeRate "moneybringer/exchange-rate"
+	InvoiceManager "moneybringer/invoice-manager"
+	TaxRate "moneybringer/invoice-manager/tax-rate"
+	Money "moneybringer/utils/money"
+	"testing"
+	"time"
+)
+
+/* fixedRates answers every lookup with the same rate and records the dates asked for */
+type fixedRates struct {
+	mid   string
+	asked []string
+}
+
+func (p *fixedRates) RateBefore(currency string, date time.Time) (ExchangeRate.Rate, error) {
+	p.asked = append(p.asked, date.Format("02-01-2006"))
+
+	return ExchangeRate.Rate{Currency: currency, Mid: p.mid, TableNo: "196/A/NBP/2026", EffectiveDate: "2026-10-08"}, nil
+}
+
+func amount(t *testing.T, value string, currency string) Money.Money {
+	t.Helper()
+
+	parsed, err := Money.Parse(value, currency)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return parsed
+}
+
+func rateRow(t *testing.T, percent int, net string, tax string, currency string) InvoiceManager.VatRateSummary {
+	t.Helper()
+
+	netValue, taxAmount := amount(t, net, currency), amount(t, tax, currency)
+
+	return InvoiceManager.VatRateSummary{TaxRate: TaxRate.FromPercent(percent), NetValue: netValue, TaxAmount: taxAmount, GrossValue: netValue.Add(taxAmount)}
+}
+
+func foreignInvoice(t *testing.T, currency string) InvoiceManager.InvoiceCreatedData {
+	t.Helper()
+
+	rows := []InvoiceManager.VatRateSummary{
+		rateRow(t, 23, "1000.05", "230.01", currency),
+		rateRow(t, 8, "99.99", "8.00", currency),
+	}
+
+	return InvoiceManager.InvoiceCreatedData{
+		InvoiceNo:   "1/10/2026",
+		DateOfIssue: "09-10-2026",
+		Currency:    currency,
+		InvoiceSummary: InvoiceManager.InvoiceSummary{
+			TotalAmount:     amount(t, "1100.04", currency),
+			TotalTaxAmount:  amount(t, "238.01", currency),
+			TotalGrossValue: amount(t, "1338.05", currency),
+			VatBreakdown:    rows,
+		},
+	}
+}
+
+func TestApplyExchangeRateConvertsEveryVatRate(t *testing.T) {
+	invoice := foreignInvoice(t, "EUR")
+	rates := &fixedRates{mid: "4.2512"}
+
+	if err := InvoiceManager.ApplyExchangeRate(&invoice, rates); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(rates.asked) != 1 || rates.asked[0] != "09-10-2026" {
+		t.Errorf("rates asked for %v, want the date of issue 09-10-2026", rates.asked)
+	}
+	if invoice.Conversion == nil || invoice.Conversion.Rate.Mid != "4.2512" {
+		t.Fatalf("conversion = %+v", invoice.Conversion)
+	}
+
+	/* every amount is converted and rounded on its own, gross values and totals are sums of the rounded amounts */
+	expected := []struct {
+		net   string
+		tax   string
+		gross string
+	}{
+		{"4251.41", "977.82", "5229.23"}, // 4251.41256, 977.818512
+		{"425.08", "34.01", "459.09"},    // 425.077488, 34.0096
+	}
+	summary := invoice.Conversion.Summary
+	if len(summary.VatBreakdown) != len(expected) {
+		t.Fatalf("got %d converted rates, want %d", len(summary.VatBreakdown), len(expected))
+	}
+	for i, row := range expected {
+		converted := summary.VatBreakdown[i]
+		assertMoney(t, "net "+string(converted.TaxRate), converted.NetValue, row.net)
+		assertMoney(t, "VAT "+string(converted.TaxRate), converted.TaxAmount, row.tax)
+		assertMoney(t, "gross "+string(converted.TaxRate), converted.GrossValue, row.gross)
+	}
+	assertMoney(t, "total net", summary.TotalAmount, "4676.49")
+	assertMoney(t, "total VAT", summary.TotalTaxAmount, "1011.83")
+	assertMoney(t, "total gross", summary.TotalGrossValue, "5688.32")
+
+	/* the invoice itself stays in its currency */
+	if invoice.InvoiceSummary.TotalGrossValue.Currency != "EUR" || invoice.InvoiceSummary.TotalGrossValue.String() != "1338.05" {
+		t.Errorf("invoice total changed to %s %s", invoice.InvoiceSummary.TotalGrossValue, invoice.InvoiceSummary.TotalGrossValue.Currency)
+	}
+}
+
+func TestApplyExchangeRateDates(t *testing.T) {
+	advance := foreignInvoice(t, "EUR")
+	advance.DocumentKind = InvoiceManager.DOCUMENT_KIND_ADVANCE
+	advance.Advance = &InvoiceManager.InvoiceAdvance{PaymentDate: "05-10-2026"}
+
+	rates := &fixedRates{mid: "4.3000"}
+	if err := InvoiceManager.ApplyExchangeRate(&advance, rates); err != nil {
+		t.Fatal(err)
+	}
+	if len(rates.asked) != 1 || rates.asked[0] != "05-10-2026" {
+		t.Errorf("advance invoice asked rates for %v, want the payment date 05-10-2026", rates.asked)
+	}
+
+	correction := foreignInvoice(t, "EUR")
+	correction.DocumentKind = InvoiceManager.DOCUMENT_KIND_CORRECTION
+	correction.Conversion = &InvoiceManager.CurrencyConversion{Rate: ExchangeRate.Rate{Currency: "EUR", Mid: "4.1000", TableNo: "150/A/NBP/2026"}}
+
+	rates = &fixedRates{mid: "4.3000"}
+	if err := InvoiceManager.ApplyExchangeRate(&correction, rates); err != nil {
+		t.Fatal(err)
+	}
+	if len(rates.asked) != 0 || correction.Conversion.Rate.Mid != "4.1000" {
+		t.Errorf("correction got rate %s after asking for %v, want the rate 4.1000 of the corrected invoice", correction.Conversion.Rate.Mid, rates.asked)
+	}
+	assertMoney(t, "corrected total net", correction.Conversion.Summary.TotalAmount, "4510.17")
+}
+
+func TestApplyExchangeRateSkipsPlnAndProformas(t *testing.T) {
+	pln := foreignInvoice(t, "PLN")
+	proforma := foreignInvoice(t, "EUR")
+	proforma.DocumentKind = InvoiceManager.DOCUMENT_KIND_PROFORMA
+
+	for _, invoice := range []*InvoiceManager.InvoiceCreatedData{&pln, &proforma} {
+		rates := &fixedRates{mid: "4.3000"}
+		if err := InvoiceManager.ApplyExchangeRate(invoice, rates); err != nil {
+			t.Fatal(err)
+		}
+		if invoice.Conversion != nil || len(rates.asked) != 0 {
+			t.Errorf("%s %s got a conversion %+v", invoice.Kind(), invoice.Currency, invoice.Conversion)
+		}
+	}
+}
+
+func TestApplyExchangeRateRefusesAmountsInOtherCurrency(t *testing.T) {
+	invoice := foreignInvoice(t, "USD")
+	invoice.Currency = "EUR"
+
+	if err := InvoiceManager.ApplyExchangeRate(&invoice, &fixedRates{mid: "4.3000"}); !errors.Is(err, ExchangeRate.ErrCurrencyMismatch) {
+		t.Errorf("USD amounts of an EUR invoice = %v, want ErrCurrencyMismatch", err)
+	}
+}
@@ -0,0 +1,69 @@
+package InvoiceManager
+
+import (
+	CompanyData "moneybringer/invoice-manager/company"
+	Invoice "moneybringer/invoice-manager/invoice"
+	InvoiceSpec "moneybringer/invoice-manager/spec"
+	Money "moneybringer/utils/money"
+)
+
+/*
+DefaultSpec is the spec of an invoice with the values the interactive prompts offer as
+defaults and one default position, front-ends editing a whole invoice start from it and
+create the invoice with CreateInvoiceFromSpec.
+*/
+func DefaultSpec(companyData CompanyData.Company, customerName string) InvoiceSpec.Spec {
+	dateOfIssue := getDefaultDateOfIssue()
+
+	return InvoiceSpec.Spec{
+		Customer:         customerName,
+		DateOfIssue:      dateOfIssue,
+		PlaceOfIssue:     companyData.InvoiceDetails.DefaultPlaceOfIssue,
+		ServiceStartDate: getDefaultServiceStartDate(companyData.InvoiceDetails.DefaultServiceStartDay),
+		ServiceEndDate:   getDefaultServiceEndDate(companyData.InvoiceDetails.DefaultServiceEndDay),
+		Currency:         getDefaultCurrency(companyData),
+		PaymentDeadline:  getDefaultPaymentDeadline(companyData.Payment.PeriodInDays, dateOfIssue),
+		Positions:        []InvoiceSpec.PositionSpec{DefaultPositionSpec(companyData)},
+		Notes:            companyData.InvoiceDetails.DefaultNotes,
+	}
+}
+
+func DefaultPositionSpec(companyData CompanyData.Company) InvoiceSpec.PositionSpec {
+	defaultPosition := companyData.InvoicePosition
+	taxRate := defaultPosition.DefaultTaxRate
+	quantity := Invoice.DEFAULT_QUANTITY
+
+	return InvoiceSpec.PositionSpec{
+		Product:                                defaultPosition.DefaultProduct,
+		Unit:                                   defaultPosition.DefaultUnit,
+		NetPrice:                               InvoiceSpec.AmountOf(Money.FromFloat(defaultPosition.DefaultNetPrice, "")),
+		TaxRate:                                &taxRate,
+		ExemptionBasis:                         defaultPosition.DefaultExemptionBasis,
+		PolishClassificationOfGoodsAndServices: defaultPosition.PolishClassificationOfGoodsAndServices,
+		Quantity:                               &quantity,
+	}
+}
+
+/* DefaultPaymentDeadline is the deadline offered for dateOfIssue in DD-MM-YYYY format */
+func DefaultPaymentDeadline(companyData CompanyData.Company, dateOfIssue string) string {
+	return getDefaultPaymentDeadline(companyData.Payment.PeriodInDays, dateOfIssue)
+}
+
+/*
+SpecSummary returns the totals and the VAT breakdown the invoice created from spec will
+have, without reading the customer or the numbering registry. Positions need their
+quantity and net price, as after spec.Validate.
+*/
+func SpecSummary(spec InvoiceSpec.Spec, companyData CompanyData.Company) (InvoiceSummary, error) {
+	currency, err := ParseCurrency(valueOrDefault(spec.InvoiceCurrency(), getDefaultCurrency(companyData)))
+	if err != nil {
+		return InvoiceSummary{}, err
+	}
+
+	positions, err := getInvoicePositionsFromSpec(spec.Positions, companyData.InvoicePosition, currency)
+	if err != nil {
+		return InvoiceSummary{}, err
+	}
+
+	return getInvoiceSummary(positions, currency, companyData.InvoiceDetails)
+}
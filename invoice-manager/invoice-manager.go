@@ -1,18 +1,29 @@
 package InvoiceManager
 
 import (
+	"errors"
 	"fmt"
 	CompanyData "moneybringer/invoice-manager/company"
 	CustomerData "moneybringer/invoice-manager/customer"
 	Invoice "moneybringer/invoice-manager/invoice"
+	InvoiceNumbering "moneybringer/invoice-manager/numbering"
+	InvoiceSpec "moneybringer/invoice-manager/spec"
+	TaxRate "moneybringer/invoice-manager/tax-rate"
+	AmountInWords "moneybringer/utils/amount-in-words"
+	AppErrors "moneybringer/utils/app-errors"
+	AppPaths "moneybringer/utils/app-paths"
+	Money "moneybringer/utils/money"
+	Prompt "moneybringer/utils/prompt"
 	TimeUtils "moneybringer/utils/time"
-	"os"
+	"path/filepath"
+	"sort"
 	"strings"
+	"time"
 )
 
 type InvoicePayment struct {
-	deadline string
-	method   string
+	Deadline string
+	Method   string
 }
 
 type InvoiceFrom struct {
@@ -20,6 +31,7 @@ type InvoiceFrom struct {
 	Address   string
 	TaxNumber string
 	Email     string
+	Logo      string `json:",omitempty"`
 }
 
 type CustomerAddress struct {
@@ -28,90 +40,330 @@ type CustomerAddress struct {
 	Number        string
 	ZipCode       string
 	City          string
+	Country       string `json:",omitempty"`
+	CountryCode   string `json:",omitempty"`
 }
 
 type InvoiceTo struct {
-	FullName string
-	Address  CustomerAddress
+	FullName      string
+	TaxNumber     string `json:",omitempty"`
+	VatEuNumber   string `json:",omitempty"`
+	Email         string `json:",omitempty"`
+	ContactPerson string `json:",omitempty"`
+	Address       CustomerAddress
+}
+
+type VatRateSummary struct {
+	TaxRate    TaxRate.TaxRate
+	NetValue   Money.Money
+	TaxAmount  Money.Money
+	GrossValue Money.Money
 }
 
 type InvoiceSummary struct {
-	TotalAmount     float32
-	TotalTaxAmount  float32
-	TotalGrossValue float32
+	TotalAmount     Money.Money
+	TotalTaxAmount  Money.Money
+	TotalGrossValue Money.Money
+	GrossInWords    string
+	VatBreakdown    []VatRateSummary
 }
 
+/* DocumentKind tells the documents apart, raw invoices stored without it are regular VAT invoices */
+const DOCUMENT_KIND_INVOICE = "invoice"
+const DOCUMENT_KIND_PROFORMA = "proforma"
+const DOCUMENT_KIND_CORRECTION = "correction"
+const DOCUMENT_KIND_ADVANCE = "advance"
+const DOCUMENT_KIND_FINAL = "final"
+
 type InvoiceCreatedData struct {
-	InvoiceNo        string
-	DateOfIssue      string
-	PlaceOfIssue     string
-	ServiceStartDate string
-	ServiceEndDate   string
-	Payment          InvoicePayment
-	InvoiceFrom      InvoiceFrom
-	InvoiceTo        InvoiceTo
-	IBAN             string
-	SWIFT            string
-	InvoicePositions []Invoice.InvoicePosition
-	InvoiceSummary   InvoiceSummary
-	Notes            string
-	IssuedAnInvoice  string
-	AuthorFirstName  string
-	AuthorLastName   string
-}
-
-func CreateInvoice(customerName string) InvoiceCreatedData {
-	var invoicePayment InvoicePayment
-
-	customer := getCustomerData(customerName)
-	companyData := getCompanyData()
-	dateOfIssue := getDateOfIssue()
-	serviceStartDate := getServiceStartDate(companyData.InvoiceDetails.DefaultServiceStartDay)
-	serviceEndDate := getServiceEndDate(companyData.InvoiceDetails.DefaultServiceEndDay)
-	invoiceNumber := getInvoiceNumber()
-	invoicePayment.deadline = getPaymentDeadline(companyData.Payment.PeriodInDays, dateOfIssue)
-	invoicePayment.method = fmt.Sprintf("%s (%d days)", companyData.Payment.Method, companyData.Payment.PeriodInDays)
-	invoicePositions := Invoice.GetInvoicePositions(companyData.InvoicePosition)
-	invoiceFrom := getInvoiceFrom(companyData)
-	invoiceTo := getInvoiceTo(customer)
-	invoiceSummary := getInvoiceSummary(invoicePositions)
+	DocumentKind         string `json:",omitempty"`
+	Profile              string `json:",omitempty"`
+	InvoiceNo            string
+	DateOfIssue          string
+	PlaceOfIssue         string
+	ServiceStartDate     string
+	ServiceEndDate       string
+	Payment              InvoicePayment
+	InvoiceFrom          InvoiceFrom
+	InvoiceTo            InvoiceTo
+	IBAN                 string
+	SWIFT                string
+	Currency             string
+	InvoicePositions     []Invoice.InvoicePosition
+	InvoiceSummary       InvoiceSummary
+	Notes                string
+	IssuedAnInvoice      string
+	AuthorFirstName      string
+	AuthorLastName       string
+	KsefReferenceNumber  string
+	Correction           *InvoiceCorrection  `json:",omitempty"`
+	ProformaNo           string              `json:",omitempty"`
+	ConvertedToInvoiceNo string              `json:",omitempty"`
+	Advance              *InvoiceAdvance     `json:",omitempty"`
+	Settlement           *InvoiceSettlement  `json:",omitempty"`
+	Conversion           *CurrencyConversion `json:",omitempty"`
+}
+
+func (invoice InvoiceCreatedData) Kind() string {
+	if invoice.DocumentKind == "" {
+		return DOCUMENT_KIND_INVOICE
+	}
+
+	return invoice.DocumentKind
+}
+
+/* SellerProfile is the company.json profile that issued the invoice, raw invoices stored without it come from the default one */
+func (invoice InvoiceCreatedData) SellerProfile() string {
+	if invoice.Profile == "" {
+		return CompanyData.DEFAULT_PROFILE
+	}
+
+	return invoice.Profile
+}
+
+func CreateInvoice(customerName string) (InvoiceCreatedData, error) {
+	customer, companyData, err := getCustomerAndCompanyData(customerName)
+	if err != nil {
+		return InvoiceCreatedData{}, err
+	}
+	dateOfIssue, err := getDateOfIssue()
+	if err != nil {
+		return InvoiceCreatedData{}, err
+	}
+	serviceStartDate, err := getServiceStartDate(companyData.InvoiceDetails.DefaultServiceStartDay)
+	if err != nil {
+		return InvoiceCreatedData{}, err
+	}
+	serviceEndDate, err := getServiceEndDate(companyData.InvoiceDetails.DefaultServiceEndDay)
+	if err != nil {
+		return InvoiceCreatedData{}, err
+	}
+	invoiceNumber, err := getInvoiceNumber(companyData, dateOfIssue)
+	if err != nil {
+		return InvoiceCreatedData{}, err
+	}
+	paymentDeadline, err := getPaymentDeadline(companyData.Payment.PeriodInDays, dateOfIssue)
+	if err != nil {
+		return InvoiceCreatedData{}, err
+	}
+	currency, err := ParseCurrency(getCurrency(getDefaultCurrency(companyData)))
+	if err != nil {
+		return InvoiceCreatedData{}, err
+	}
+	invoicePositions := Invoice.GetInvoicePositions(companyData.InvoicePosition, currency)
+
+	invoice, err := newInvoiceCreatedData(companyData, customer, currency, invoicePositions)
+	if err != nil {
+		return invoice, err
+	}
+	invoice.InvoiceNo = invoiceNumber
+	invoice.DateOfIssue = dateOfIssue
+	invoice.PlaceOfIssue = companyData.InvoiceDetails.DefaultPlaceOfIssue
+	invoice.ServiceStartDate = serviceStartDate
+	invoice.ServiceEndDate = serviceEndDate
+	invoice.Payment.Deadline = paymentDeadline
+	invoice.Notes = strings.Join(companyData.InvoiceDetails.DefaultNotes, ", ")
+
+	return invoice, nil
+}
+
+func CreateInvoiceFromSpec(spec InvoiceSpec.Spec) (InvoiceCreatedData, error) {
+	customer, companyData, err := getCustomerAndCompanyData(spec.Customer)
+	if err != nil {
+		return InvoiceCreatedData{}, err
+	}
+
+	currency, err := ParseCurrency(valueOrDefault(spec.InvoiceCurrency(), getDefaultCurrency(companyData)))
+	if err != nil {
+		return InvoiceCreatedData{}, err
+	}
+	invoicePositions, err := getInvoicePositionsFromSpec(spec.Positions, companyData.InvoicePosition, currency)
+	if err != nil {
+		return InvoiceCreatedData{}, err
+	}
+
+	invoice, err := newInvoiceCreatedData(companyData, customer, currency, invoicePositions)
+	if err != nil {
+		return invoice, err
+	}
+	invoice.InvoiceNo, err = getInvoiceNumber(companyData, spec.DateOfIssue)
+	if err != nil {
+		return invoice, err
+	}
+	invoice.DateOfIssue = spec.DateOfIssue
+	invoice.PlaceOfIssue = valueOrDefault(spec.PlaceOfIssue, companyData.InvoiceDetails.DefaultPlaceOfIssue)
+	invoice.ServiceStartDate = spec.ServiceStartDate
+	invoice.ServiceEndDate = spec.ServiceEndDate
+	invoice.Payment.Deadline = valueOrDefault(spec.PaymentDeadline, getDefaultPaymentDeadline(companyData.Payment.PeriodInDays, spec.DateOfIssue))
+
+	notes := spec.Notes
+	if len(notes) == 0 {
+		notes = companyData.InvoiceDetails.DefaultNotes
+	}
+	invoice.Notes = strings.Join(notes, ", ")
+
+	return invoice, nil
+}
+
+func newInvoiceCreatedData(companyData CompanyData.Company, customer CustomerData.Customer, currency string, invoicePositions []Invoice.InvoicePosition) (InvoiceCreatedData, error) {
+	if err := Invoice.CheckExemptionBasis(invoicePositions); err != nil {
+		return InvoiceCreatedData{}, err
+	}
+
+	invoiceSummary, err := getInvoiceSummary(invoicePositions, currency, companyData.InvoiceDetails)
+	if err != nil {
+		return InvoiceCreatedData{}, err
+	}
+
+	bankAccount := companyData.BankAccountFor(currency)
 
 	return InvoiceCreatedData{
-		InvoiceNo:        invoiceNumber,
-		DateOfIssue:      dateOfIssue,
-		PlaceOfIssue:     companyData.InvoiceDetails.DefaultPlaceOfIssue,
-		ServiceStartDate: serviceStartDate,
-		ServiceEndDate:   serviceEndDate,
-		Payment:          invoicePayment,
-		InvoiceFrom:      invoiceFrom,
-		InvoiceTo:        invoiceTo,
-		IBAN:             companyData.CompanyDetails.IBAN,
-		SWIFT:            companyData.CompanyDetails.SWIFT,
+		DocumentKind: DOCUMENT_KIND_INVOICE,
+		Profile:      companyData.Profile,
+		Payment: InvoicePayment{
+			Method: fmt.Sprintf("%s (%d days)", companyData.Payment.Method, companyData.Payment.PeriodInDays),
+		},
+		InvoiceFrom:      getInvoiceFrom(companyData),
+		InvoiceTo:        getInvoiceTo(customer),
+		IBAN:             bankAccount.IBAN,
+		SWIFT:            bankAccount.SWIFT,
+		Currency:         currency,
 		InvoicePositions: invoicePositions,
 		InvoiceSummary:   invoiceSummary,
-		Notes:            strings.Join(companyData.InvoiceDetails.DefaultNotes, ", "),
 		IssuedAnInvoice:  fmt.Sprintf("%s %s", companyData.PersonalDetails.FirstName, companyData.PersonalDetails.LastName),
 		AuthorFirstName:  companyData.PersonalDetails.FirstName,
 		AuthorLastName:   companyData.PersonalDetails.LastName,
+	}, nil
+}
+
+/* Positions without a currency take the invoice currency, a different one is refused by the summary */
+func getInvoicePositionsFromSpec(positionSpecs []InvoiceSpec.PositionSpec, defaultPosition CompanyData.InvoicePosition, currency string) ([]Invoice.InvoicePosition, error) {
+	var invoicePositions []Invoice.InvoicePosition
+
+	for i, positionSpec := range positionSpecs {
+		netPrice, err := positionSpec.NetPrice.Money("")
+		if err != nil {
+			return nil, fmt.Errorf("position %d: net price: %w", i+1, err)
+		}
+
+		taxRate := defaultPosition.DefaultTaxRate
+		if positionSpec.TaxRate != nil {
+			taxRate = *positionSpec.TaxRate
+		}
+
+		position := Invoice.NewInvoicePosition(
+			i+1,
+			positionSpec.Product,
+			valueOrDefault(positionSpec.PolishClassificationOfGoodsAndServices, defaultPosition.PolishClassificationOfGoodsAndServices),
+			valueOrDefault(positionSpec.Unit, defaultPosition.DefaultUnit),
+			*positionSpec.Quantity,
+			netPrice,
+			taxRate,
+			strings.ToUpper(valueOrDefault(positionSpec.Currency, currency)),
+		)
+
+		if taxRate == TaxRate.EXEMPT || taxRate == TaxRate.NOT_SUBJECT {
+			position.ExemptionBasis = valueOrDefault(positionSpec.ExemptionBasis, defaultPosition.DefaultExemptionBasis)
+		}
+		invoicePositions = append(invoicePositions, position)
+	}
+
+	return invoicePositions, nil
+}
+
+func valueOrDefault(value string, defaultValue string) string {
+	if strings.TrimSpace(value) == "" {
+		return defaultValue
 	}
+
+	return value
 }
 
-func getInvoiceSummary(positions []Invoice.InvoicePosition) InvoiceSummary {
-	var totalAmount float32 = 0
-	var totalTaxAmount float32 = 0
-	var totalGrossValue float32 = 0
+/*
+Polish law allows rounding VAT either per invoice line or once per tax rate on
+the summed net values (art. 106e ust. 10 VAT act), selected by vatRounding.
+*/
+const VAT_ROUNDING_PER_LINE = "line"
+const VAT_ROUNDING_PER_TOTAL = "total"
+
+/* getInvoiceSummary refuses positions in another currency than the invoice, their amounts cannot be added up */
+func getInvoiceSummary(positions []Invoice.InvoicePosition, currency string, invoiceDetails CompanyData.InvoiceDetails) (InvoiceSummary, error) {
+	if err := checkPositionCurrency(positions, currency); err != nil {
+		return InvoiceSummary{}, err
+	}
 
-	for _, position := range positions {
-		totalAmount += position.NetValue
-		totalTaxAmount += position.TaxAmount
-		totalGrossValue += position.GrossValue
+	vatBreakdown := getVatBreakdown(positions, invoiceDetails.VatRounding)
+	totalAmount := Money.Zero(currency)
+	totalTaxAmount := Money.Zero(currency)
+
+	for _, rateSummary := range vatBreakdown {
+		totalAmount = totalAmount.Add(rateSummary.NetValue)
+		totalTaxAmount = totalTaxAmount.Add(rateSummary.TaxAmount)
 	}
 
+	totalGrossValue := totalAmount.Add(totalTaxAmount)
+
 	return InvoiceSummary{
 		TotalAmount:     totalAmount,
 		TotalTaxAmount:  totalTaxAmount,
 		TotalGrossValue: totalGrossValue,
+		GrossInWords:    getAmountInWords(totalGrossValue, invoiceDetails.AmountInWordsLanguage),
+		VatBreakdown:    vatBreakdown,
+	}, nil
+}
+
+/* The language is checked when company data is loaded, an unknown one falls back to Polish, required on Polish invoices */
+func getAmountInWords(amount Money.Money, language string) string {
+	words, err := AmountInWords.Spell(amount, language)
+	if err != nil {
+		words, _ = AmountInWords.Spell(amount, AmountInWords.LANGUAGE_POLISH)
+	}
+
+	return words
+}
+
+/* VatBreakdownOf also covers raw invoices stored before the summary had a per-rate breakdown */
+func VatBreakdownOf(invoice InvoiceCreatedData) []VatRateSummary {
+	if len(invoice.InvoiceSummary.VatBreakdown) > 0 {
+		return invoice.InvoiceSummary.VatBreakdown
+	}
+
+	return getVatBreakdown(invoice.InvoicePositions, VAT_ROUNDING_PER_LINE)
+}
+
+/* Groups positions per tax rate, highest rate first, as shown in the VAT table of the invoice */
+func getVatBreakdown(positions []Invoice.InvoicePosition, vatRounding string) []VatRateSummary {
+	var vatBreakdown []VatRateSummary
+	rateIndex := map[TaxRate.TaxRate]int{}
+
+	for _, position := range positions {
+		index, exists := rateIndex[position.TaxRate]
+		if !exists {
+			index = len(vatBreakdown)
+			rateIndex[position.TaxRate] = index
+			vatBreakdown = append(vatBreakdown, VatRateSummary{
+				TaxRate:   position.TaxRate,
+				NetValue:  Money.Zero(position.Currency),
+				TaxAmount: Money.Zero(position.Currency),
+			})
+		}
+
+		vatBreakdown[index].NetValue = vatBreakdown[index].NetValue.Add(position.NetValue)
+		vatBreakdown[index].TaxAmount = vatBreakdown[index].TaxAmount.Add(position.TaxAmount)
 	}
+
+	for i := range vatBreakdown {
+		if vatRounding == VAT_ROUNDING_PER_TOTAL {
+			vatBreakdown[i].TaxAmount = Invoice.CalculateTaxAmount(vatBreakdown[i].TaxRate, vatBreakdown[i].NetValue)
+		}
+		vatBreakdown[i].GrossValue = vatBreakdown[i].NetValue.Add(vatBreakdown[i].TaxAmount)
+	}
+
+	sort.SliceStable(vatBreakdown, func(i, j int) bool {
+		return TaxRate.Less(vatBreakdown[i].TaxRate, vatBreakdown[j].TaxRate)
+	})
+
+	return vatBreakdown
 }
 
 func getInvoiceFrom(companyData CompanyData.Company) InvoiceFrom {
@@ -126,122 +378,272 @@ func getInvoiceFrom(companyData CompanyData.Company) InvoiceFrom {
 		Address:   addres,
 		TaxNumber: companyData.CompanyDetails.TaxNumber,
 		Email:     companyData.CompanyDetails.Email,
+		Logo:      companyData.Logo,
 	}
 }
 
 func getInvoiceTo(customer CustomerData.Customer) InvoiceTo {
+	customer = customer.Normalized()
 
 	return InvoiceTo{
-		FullName: customer.FullName,
-		Address:  CustomerAddress(customer.Address),
+		FullName:      customer.FullName,
+		TaxNumber:     customer.TaxNumber,
+		VatEuNumber:   customer.VatEuNumber,
+		Email:         customer.Email,
+		ContactPerson: customer.ContactPerson,
+		Address: CustomerAddress{
+			StreetAddress: customer.Address.StreetAddress,
+			State:         customer.Address.State,
+			Number:        customer.Address.Number,
+			ZipCode:       customer.Address.ZipCode,
+			City:          customer.Address.City,
+			Country:       customer.Address.Country,
+			CountryCode:   customer.Address.CountryCode,
+		},
 	}
 }
 
-func getInvoiceNumber() string {
-	/* number of invoice in month/current month/current year */
-	currentTime := TimeUtils.GetCurrentTime()
-	currentYear := currentTime.Year()
-	currentMonthNumber := int(currentTime.Month())
-	monthDirPath := Invoice.GetInvoiceDirPath()
+const INVOICE_SERIES = "invoice"
+const CORRECTION_SERIES = "correction"
+const PROFORMA_SERIES = "proforma"
+const DEFAULT_CORRECTION_PATTERN = "KOR/{n}/{MM}/{YYYY}"
+const DEFAULT_PROFORMA_PATTERN = "PRO/{n}/{MM}/{YYYY}"
+
+/*
+Every seller profile numbers its documents in its own series, the default profile keeps
+the unprefixed series used before profiles existed.
+*/
+func profileSeries(companyData CompanyData.Company, series string) string {
+	if companyData.Profile == "" || companyData.Profile == CompanyData.DEFAULT_PROFILE {
+		return series
+	}
+
+	return companyData.Profile + "/" + series
+}
+
+/* storedInvoices lists the issued invoices wherever they are stored, see SetStoredInvoices */
+var storedInvoices func() ([]InvoiceCreatedData, error)
+
+/*
+SetStoredInvoices tells the numbering where issued invoices are kept, a series the numbering
+registry does not know yet continues after the numbers already stored in it
+*/
+func SetStoredInvoices(list func() ([]InvoiceCreatedData, error)) {
+	storedInvoices = list
+}
+
+func getNumberingRegistry() *InvoiceNumbering.Registry {
+	return InvoiceNumbering.NewRegistry(filepath.Join(AppPaths.InvoicesDir(), "numbering.json"), issuedNumbers)
+}
+
+func issuedNumbers(series string) ([]InvoiceNumbering.IssuedNumber, error) {
+	if storedInvoices == nil {
+		return nil, nil
+	}
 
-	files, err := os.ReadDir(monthDirPath)
+	invoices, err := storedInvoices()
 	if err != nil {
-		fmt.Println("Error reading directory:", err)
-		os.Exit(1)
+		return nil, err
 	}
 
-	fileCount := 0
-	for _, file := range files {
-		if !file.IsDir() { // Only count files, skip directories
-			fileCount++
+	var numbers []InvoiceNumbering.IssuedNumber
+	for _, invoice := range invoices {
+		if profileSeries(CompanyData.Company{Profile: invoice.Profile}, seriesOf(invoice)) != series {
+			continue
 		}
+
+		/* a number without a readable date cannot continue a period, it is still never issued again */
+		date, _ := TimeUtils.ParseDdMmYyyy(invoice.DateOfIssue)
+		numbers = append(numbers, InvoiceNumbering.IssuedNumber{Number: invoice.InvoiceNo, Date: date})
 	}
 
-	nextInvoiceNumber := fileCount + 1
+	return numbers, nil
+}
 
-	return fmt.Sprintf("%d/%d/%d", nextInvoiceNumber, currentMonthNumber, currentYear)
+func getNumberPattern(companyData CompanyData.Company) string {
+	return valueOrDefault(companyData.InvoiceDetails.NumberPattern, InvoiceNumbering.DEFAULT_PATTERN)
 }
 
-func getPaymentDeadline(defaultPaymentPeriodInDays int, dateOfIssue string) string {
-	issueProposedTime, isOriginalTime := TimeUtils.GetDataFromDdMmYyyyFormat(dateOfIssue)
+func getCorrectionNumberPattern(companyData CompanyData.Company) string {
+	return valueOrDefault(companyData.InvoiceDetails.CorrectionNumberPattern, DEFAULT_CORRECTION_PATTERN)
+}
 
-	if isOriginalTime == false {
-		fmt.Printf("Using current timestamp to calculate deadline day")
+func getProformaNumberPattern(companyData CompanyData.Company) string {
+	return valueOrDefault(companyData.InvoiceDetails.ProformaNumberPattern, DEFAULT_PROFORMA_PATTERN)
+}
+
+/*
+Corrections and proformas are numbered in their own series so the gap-free invoice
+sequence stays untouched, advance and final invoices are VAT invoices and share it.
+*/
+func getNumberSeries(invoice InvoiceCreatedData, companyData CompanyData.Company) (string, string) {
+	series := profileSeries(companyData, seriesOf(invoice))
+
+	switch invoice.Kind() {
+	case DOCUMENT_KIND_CORRECTION:
+		return series, getCorrectionNumberPattern(companyData)
+	case DOCUMENT_KIND_PROFORMA:
+		return series, getProformaNumberPattern(companyData)
 	}
 
-	deadlineProposedDay := issueProposedTime.AddDate(0, 0, defaultPaymentPeriodInDays)
-	formated := TimeUtils.FormatToDdMmYyyy(deadlineProposedDay)
+	return series, getNumberPattern(companyData)
+}
 
-	fmt.Printf("Enter date of payment deadline (or press Enter to use the default: %s):", formated)
+func seriesOf(invoice InvoiceCreatedData) string {
+	switch invoice.Kind() {
+	case DOCUMENT_KIND_CORRECTION:
+		return CORRECTION_SERIES
+	case DOCUMENT_KIND_PROFORMA:
+		return PROFORMA_SERIES
+	}
 
-	var input string
-	_, err := fmt.Scanln(&input)
+	return INVOICE_SERIES
+}
 
-	if err != nil || input == "" {
-		input = formated
+func getNumberingDate(dateOfIssue string) time.Time {
+	date, err := TimeUtils.ParseDdMmYyyy(dateOfIssue)
+	if err != nil {
+		return TimeUtils.GetCurrentTime()
 	}
 
-	return input
+	return date
 }
 
-func getDateOfIssue() string {
-	currentTime := TimeUtils.GetCurrentTime()
-	formated := TimeUtils.FormatToDdMmYyyy(currentTime)
+/* Returns a preview of the next number, the number is assigned for good by IssueInvoice */
+func getInvoiceNumber(companyData CompanyData.Company, dateOfIssue string) (string, error) {
+	return previewNumber(profileSeries(companyData, INVOICE_SERIES), getNumberPattern(companyData), dateOfIssue)
+}
 
-	fmt.Printf("Enter date of issue (or press Enter to use the default: %s):", formated)
+func previewNumber(series string, pattern string, dateOfIssue string) (string, error) {
+	number, err := getNumberingRegistry().Peek(series, pattern, getNumberingDate(dateOfIssue))
+	if err != nil {
+		return "", fmt.Errorf("%w: invoice numbering registry: %w", AppErrors.ErrStorage, err)
+	}
 
-	var input string
-	_, err := fmt.Scanln(&input)
+	return number, nil
+}
 
-	if err != nil || input == "" {
-		input = formated
+/*
+IssueInvoice assigns the final invoice number and calls persist with the numbered
+invoice. The number is only consumed when persist succeeds. Errors of persist are
+returned as they are, errors of the numbering registry wrap AppErrors.ErrStorage.
+*/
+func IssueInvoice(invoice *InvoiceCreatedData, persist func(invoice InvoiceCreatedData) error) error {
+	companyData, err := getInvoiceCompanyData(*invoice)
+	if err != nil {
+		return err
 	}
+	series, pattern := getNumberSeries(*invoice, companyData)
+
+	var persistErr error
+	_, err = getNumberingRegistry().Issue(series, pattern, getNumberingDate(invoice.DateOfIssue), func(number string) error {
+		invoice.InvoiceNo = number
+		persistErr = persist(*invoice)
+		return persistErr
+	})
+	if err != nil && err != persistErr {
+		return fmt.Errorf("%w: invoice numbering registry: %w", AppErrors.ErrStorage, err)
+	}
+
+	return err
+}
+
+func getPaymentDeadline(defaultPaymentPeriodInDays int, dateOfIssue string) (string, error) {
+	formated := getDefaultPaymentDeadline(defaultPaymentPeriodInDays, dateOfIssue)
 
-	return input
+	return askDate(fmt.Sprintf("Enter date of payment deadline (or press Enter to use the default: %s):", formated), formated)
 }
 
-func getServiceStartDate(defaultServiceStartDay int) string {
-	previousMonthTime := TimeUtils.GetPreviousMonthTime()
-	adjustedTime := TimeUtils.SetDayOfMonth(previousMonthTime, defaultServiceStartDay)
-	formated := TimeUtils.FormatToDdMmYyyy(adjustedTime)
+func getDefaultPaymentDeadline(defaultPaymentPeriodInDays int, dateOfIssue string) string {
+	/* a date of issue that is not a date is reported by the caller, the deadline counts from today then */
+	issueProposedTime, _ := TimeUtils.GetDataFromDdMmYyyyFormat(dateOfIssue)
 
-	fmt.Printf("Enter service start date (or press Enter to use the default: %s):", formated)
+	deadlineProposedDay := issueProposedTime.AddDate(0, 0, defaultPaymentPeriodInDays)
 
-	var input string
-	_, err := fmt.Scanln(&input)
+	return TimeUtils.FormatToDdMmYyyy(deadlineProposedDay)
+}
 
-	if err != nil || input == "" {
-		input = formated
+func getDefaultCurrency(companyData CompanyData.Company) string {
+	return valueOrDefault(companyData.InvoicePosition.DefaultCurrency, PLN)
+}
+
+func getCurrency(defaultCurrency string) string {
+	input := Prompt.String(fmt.Sprintf("Enter invoice currency (or press Enter to use the default: %s):", defaultCurrency), defaultCurrency)
+
+	currency, parseErr := ParseCurrency(input)
+	if parseErr != nil {
+		fmt.Println(parseErr, "- using the default:", defaultCurrency)
+		return defaultCurrency
 	}
 
-	return input
+	return currency
 }
 
-func getServiceEndDate(defaultServiceEndDay int) string {
-	currentTime := TimeUtils.GetCurrentTime()
-	adjustedTime := TimeUtils.SetDayOfMonth(currentTime, defaultServiceEndDay)
-	formated := TimeUtils.FormatToDdMmYyyy(adjustedTime)
+func getDateOfIssue() (string, error) {
+	formated := getDefaultDateOfIssue()
+
+	return askDate(fmt.Sprintf("Enter date of issue (or press Enter to use the default: %s):", formated), formated)
+}
 
-	fmt.Printf("Enter service end date (or press Enter to use the default: %s):", formated)
+/* dateAttempts limits asking again for a date that is not DD-MM-YYYY, input that only has invalid answers gives up */
+const dateAttempts = 3
 
-	var input string
-	_, err := fmt.Scanln(&input)
+var ErrInvalidDate = errors.New("date is not a valid DD-MM-YYYY date")
 
-	if err != nil || input == "" {
-		input = formated
+func askDate(question string, defaultValue string) (string, error) {
+	date := Prompt.String(question, defaultValue)
+
+	for attempt := 1; ; attempt++ {
+		if _, err := TimeUtils.ParseDdMmYyyy(date); err == nil {
+			return date, nil
+		}
+		if attempt == dateAttempts {
+			return "", fmt.Errorf("%w: %q", ErrInvalidDate, date)
+		}
+
+		fmt.Printf("%q is not a date, enter it as DD-MM-YYYY, e.g. %s\n", date, defaultValue)
+		date = Prompt.String(question, defaultValue)
 	}
+}
 
-	return input
+func getServiceStartDate(defaultServiceStartDay int) (string, error) {
+	formated := getDefaultServiceStartDate(defaultServiceStartDay)
+
+	return askDate(fmt.Sprintf("Enter service start date (or press Enter to use the default: %s):", formated), formated)
 }
 
-func getCompanyData() CompanyData.Company {
-	company := CompanyData.GetCompanyData()
+func getServiceEndDate(defaultServiceEndDay int) (string, error) {
+	formated := getDefaultServiceEndDate(defaultServiceEndDay)
+
+	return askDate(fmt.Sprintf("Enter service end date (or press Enter to use the default: %s):", formated), formated)
+}
 
-	return company
+func getDefaultDateOfIssue() string {
+	return TimeUtils.FormatToDdMmYyyy(TimeUtils.GetCurrentTime())
 }
 
-func getCustomerData(customerName string) CustomerData.Customer {
-	customer := CustomerData.GetCustomerData(customerName)
+/* Services are invoiced for the period from defaultServiceStartDay of the previous month to defaultServiceEndDay of the current one */
+func getDefaultServiceStartDate(defaultServiceStartDay int) string {
+	return TimeUtils.FormatToDdMmYyyy(TimeUtils.SetDayOfMonth(TimeUtils.GetPreviousMonthTime(), defaultServiceStartDay))
+}
+
+func getDefaultServiceEndDate(defaultServiceEndDay int) string {
+	return TimeUtils.FormatToDdMmYyyy(TimeUtils.SetDayOfMonth(TimeUtils.GetCurrentTime(), defaultServiceEndDay))
+}
+
+/* getCustomerAndCompanyData loads the buyer and the seller selected with --profile for a new invoice */
+func getCustomerAndCompanyData(customerName string) (CustomerData.Customer, CompanyData.Company, error) {
+	customer, err := CustomerData.GetCustomerData(customerName)
+	if err != nil {
+		return customer, CompanyData.Company{}, err
+	}
+
+	companyData, err := CompanyData.GetCompanyData()
+
+	return customer, companyData, err
+}
 
-	return customer
+/* Documents derived from a stored invoice (corrections, conversions) use the profile that issued it */
+func getInvoiceCompanyData(invoice InvoiceCreatedData) (CompanyData.Company, error) {
+	return CompanyData.GetProfileData(invoice.SellerProfile())
 }
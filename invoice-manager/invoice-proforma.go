@@ -0,0 +1,54 @@
+package InvoiceManager
+
+import (
+	"errors"
+	"fmt"
+)
+
+var ErrNotProforma = errors.New("only a proforma can be converted into a VAT invoice")
+var ErrProformaConverted = errors.New("proforma was already converted")
+
+/* AsProforma turns a prepared invoice into a proforma, numbered in the proforma series */
+func AsProforma(invoice InvoiceCreatedData) (InvoiceCreatedData, error) {
+	companyData, err := getInvoiceCompanyData(invoice)
+	if err != nil {
+		return invoice, err
+	}
+
+	invoice.DocumentKind = DOCUMENT_KIND_PROFORMA
+	invoice.InvoiceNo, err = previewNumber(profileSeries(companyData, PROFORMA_SERIES), getProformaNumberPattern(companyData), invoice.DateOfIssue)
+
+	return invoice, err
+}
+
+/*
+ConvertProforma prepares the VAT invoice for a proforma. Positions and amounts are
+copied unchanged, the invoice gets its own number, date of issue and payment deadline.
+*/
+func ConvertProforma(proforma InvoiceCreatedData, dateOfIssue string) (InvoiceCreatedData, error) {
+	if proforma.Kind() != DOCUMENT_KIND_PROFORMA {
+		return InvoiceCreatedData{}, ErrNotProforma
+	}
+
+	if proforma.ConvertedToInvoiceNo != "" {
+		return InvoiceCreatedData{}, fmt.Errorf("%w into invoice %s", ErrProformaConverted, proforma.ConvertedToInvoiceNo)
+	}
+
+	companyData, err := getInvoiceCompanyData(proforma)
+	if err != nil {
+		return InvoiceCreatedData{}, err
+	}
+
+	invoice := proforma
+	invoice.DocumentKind = DOCUMENT_KIND_INVOICE
+	invoice.InvoiceNo, err = getInvoiceNumber(companyData, dateOfIssue)
+	if err != nil {
+		return InvoiceCreatedData{}, err
+	}
+	invoice.DateOfIssue = dateOfIssue
+	invoice.Payment.Deadline = getDefaultPaymentDeadline(companyData.Payment.PeriodInDays, dateOfIssue)
+	invoice.KsefReferenceNumber = ""
+	invoice.ProformaNo = proforma.InvoiceNo
+
+	return invoice, nil
+}
@@ -0,0 +1,295 @@
+package InvoiceManager_test
+
+import (
+	"errors"
+	"io"
+	InvoiceManager "moneybringer/invoice-manager"
+	Invoice "moneybringer/invoice-manager/invoice"
+	TaxRate "moneybringer/invoice-manager/tax-rate"
+	AppPaths "moneybringer/utils/app-paths"
+	Money "moneybringer/utils/money"
+	Prompt "moneybringer/utils/prompt"
+	"strings"
+	"testing"
+)
+
+/* useTestConfig reads company.json and customers.json from testdata and keeps numbering.json in a temporary directory */
+func useTestConfig(t *testing.T) {
+	t.Helper()
+
+	AppPaths.SetConfigDir("testdata/config")
+	AppPaths.SetInvoicesDir(t.TempDir())
+
+	previous := Prompt.Current()
+	t.Cleanup(func() {
+		AppPaths.SetConfigDir("")
+		AppPaths.SetInvoicesDir("")
+		Prompt.SetPrompter(previous)
+	})
+}
+
+func assertMoney(t *testing.T, name string, got Money.Money, want string) {
+	t.Helper()
+
+	if got.String() != want || got.Currency != "PLN" {
+		t.Errorf("%s = %s %s, want %s PLN", name, got, got.Currency, want)
+	}
+}
+
+func assertPosition(t *testing.T, position Invoice.InvoicePosition, name string, quantity int, netPrice string, taxRate TaxRate.TaxRate, grossValue string) {
+	t.Helper()
+
+	if position.ProductOrServiceName != name || position.Quantity != quantity || position.TaxRate != taxRate {
+		t.Errorf("position %d = %q, %d, VAT %s, want %q, %d, VAT %s", position.ItemNo, position.ProductOrServiceName, position.Quantity, position.TaxRate, name, quantity, taxRate)
+	}
+	assertMoney(t, name+" net price", position.NetPrice, netPrice)
+	assertMoney(t, name+" gross value", position.GrossValue, grossValue)
+}
+
+func TestCreateInvoiceWithScriptedAnswers(t *testing.T) {
+	useTestConfig(t)
+
+	script := Prompt.NewScripted([]string{
+		"09-10-2026", // date of issue
+		"",           // service start date
+		"",           // service end date
+		"",           // payment deadline
+		"",           // currency
+		"Consulting", // product
+		"",           // unit
+		"120.50",     // net price
+		"23",         // tax rate
+		"",           // classification
+		"10",         // quantity
+		"y",          // another position
+		"Hosting",
+		"month",
+		"99.99",
+		"8",
+		"",
+		"3",
+		"n",
+	}, io.Discard)
+	Prompt.SetPrompter(script)
+
+	invoice, err := InvoiceManager.CreateInvoice("SomeCompany")
+	if err != nil {
+		t.Fatalf("CreateInvoice: %v", err)
+	}
+	if remaining := script.Remaining(); len(remaining) != 0 {
+		t.Errorf("answers left unused: %q", remaining)
+	}
+
+	if invoice.InvoiceNo != "1/10/2026" || invoice.DateOfIssue != "09-10-2026" || invoice.Payment.Deadline != "08-11-2026" {
+		t.Errorf("invoice %s of %s due %s, want 1/10/2026 of 09-10-2026 due 08-11-2026", invoice.InvoiceNo, invoice.DateOfIssue, invoice.Payment.Deadline)
+	}
+	if len(invoice.InvoicePositions) != 2 {
+		t.Fatalf("got %d positions, want 2", len(invoice.InvoicePositions))
+	}
+	assertPosition(t, invoice.InvoicePositions[0], "Consulting", 10, "120.50", TaxRate.FromPercent(23), "1482.15")
+	assertPosition(t, invoice.InvoicePositions[1], "Hosting", 3, "99.99", TaxRate.FromPercent(8), "323.97")
+	if unit := invoice.InvoicePositions[0].Unit; unit != "h" {
+		t.Errorf("unit = %q, want the default h", unit)
+	}
+
+	assertMoney(t, "total net", invoice.InvoiceSummary.TotalAmount, "1504.97")
+	assertMoney(t, "total VAT", invoice.InvoiceSummary.TotalTaxAmount, "301.15")
+	assertMoney(t, "total gross", invoice.InvoiceSummary.TotalGrossValue, "1806.12")
+}
+
+func TestCreateInvoiceAsksExemptionBasis(t *testing.T) {
+	useTestConfig(t)
+
+	fake := &Prompt.Fake{Answers: map[string]string{
+		"date of issue": "09-10-2026",
+		"net price":     "1000",
+		"tax rate":      "zw",
+		"legal basis":   "art. 43 ust. 1 pkt 29 lit. a",
+		"quantity":      "2",
+	}}
+	Prompt.SetPrompter(fake)
+
+	invoice, err := InvoiceManager.CreateInvoice("SomeCompany")
+	if err != nil {
+		t.Fatalf("CreateInvoice: %v", err)
+	}
+
+	position := invoice.InvoicePositions[0]
+	assertPosition(t, position, "Consulting service", 2, "1000.00", TaxRate.EXEMPT, "2000.00")
+	if position.ExemptionBasis != "art. 43 ust. 1 pkt 29 lit. a" {
+		t.Errorf("exemption basis = %q", position.ExemptionBasis)
+	}
+	assertMoney(t, "total VAT", invoice.InvoiceSummary.TotalTaxAmount, "0.00")
+
+	asked := strings.Join(fake.Asked, "\n")
+	if !strings.Contains(asked, "legal basis for zw") {
+		t.Errorf("exemption basis was not asked, questions:\n%s", asked)
+	}
+}
+
+func TestCreateCorrectionWithScriptedAnswers(t *testing.T) {
+	useTestConfig(t)
+
+	Prompt.SetPrompter(Prompt.NewScripted([]string{"09-10-2026", "", "", "", "", "Consulting", "", "100", "23", "", "10", "y", "Hosting", "", "50", "23", "", "1", "n"}, io.Discard))
+	original, err := InvoiceManager.CreateInvoice("SomeCompany")
+	if err != nil {
+		t.Fatalf("CreateInvoice: %v", err)
+	}
+
+	script := Prompt.NewScripted([]string{
+		"20-10-2026", // date of issue
+		"8",          // Consulting quantity
+		"",           // Consulting net price kept
+		"",           // Consulting tax rate kept
+		"0",          // Hosting removed
+		"y",          // add new positions
+		"Support",
+		"",
+		"200",
+		"8",
+		"",
+		"1",
+		"n",
+	}, io.Discard)
+	Prompt.SetPrompter(script)
+
+	correction, err := InvoiceManager.CreateCorrection(original, "Fewer hours")
+	if err != nil {
+		t.Fatalf("CreateCorrection: %v", err)
+	}
+	if remaining := script.Remaining(); len(remaining) != 0 {
+		t.Errorf("answers left unused: %q", remaining)
+	}
+
+	if correction.InvoiceNo != "KOR/1/10/2026" || correction.Correction.OriginalInvoiceNo != original.InvoiceNo || correction.Correction.Reason != "Fewer hours" {
+		t.Errorf("correction %s of %s (%q)", correction.InvoiceNo, correction.Correction.OriginalInvoiceNo, correction.Correction.Reason)
+	}
+	if len(correction.InvoicePositions) != 2 {
+		t.Fatalf("got %d positions after correction, want 2", len(correction.InvoicePositions))
+	}
+	assertPosition(t, correction.InvoicePositions[0], "Consulting", 8, "100.00", TaxRate.FromPercent(23), "984.00")
+	assertPosition(t, correction.InvoicePositions[1], "Support", 1, "200.00", TaxRate.FromPercent(8), "216.00")
+	if itemNo := correction.InvoicePositions[1].ItemNo; itemNo != 2 {
+		t.Errorf("new position has item number %d, want 2", itemNo)
+	}
+
+	assertMoney(t, "net before", correction.Correction.SummaryBefore.TotalAmount, "1050.00")
+	assertMoney(t, "net after", correction.Correction.SummaryAfter.TotalAmount, "1000.00")
+	assertMoney(t, "net difference", correction.InvoiceSummary.TotalAmount, "-50.00")
+	assertMoney(t, "VAT difference", correction.InvoiceSummary.TotalTaxAmount, "-41.50")
+	assertMoney(t, "gross difference", correction.InvoiceSummary.TotalGrossValue, "-91.50")
+}
+
+func TestCreateInvoiceAsksAgainForInvalidDates(t *testing.T) {
+	useTestConfig(t)
+
+	script := Prompt.NewScripted([]string{
+		"9-10-26",    // date of issue, not DD-MM-YYYY
+		"09-10-2026", // date of issue
+		"01-09-2026", // service start date
+		"30/09/2026", // service end date, not DD-MM-YYYY
+		"30-09-2026", // service end date
+		"31-11-2026", // payment deadline, not a date
+		"",           // payment deadline, the default
+		"",           // currency
+		"Consulting",
+		"",
+		"100",
+		"23",
+		"",
+		"10",
+		"n",
+	}, io.Discard)
+	Prompt.SetPrompter(script)
+
+	invoice, err := InvoiceManager.CreateInvoice("SomeCompany")
+	if err != nil {
+		t.Fatalf("CreateInvoice: %v", err)
+	}
+	if remaining := script.Remaining(); len(remaining) != 0 {
+		t.Errorf("answers left unused: %q", remaining)
+	}
+
+	if invoice.DateOfIssue != "09-10-2026" || invoice.ServiceStartDate != "01-09-2026" || invoice.ServiceEndDate != "30-09-2026" || invoice.Payment.Deadline != "08-11-2026" {
+		t.Errorf("invoice of %s for %s - %s due %s", invoice.DateOfIssue, invoice.ServiceStartDate, invoice.ServiceEndDate, invoice.Payment.Deadline)
+	}
+
+	for _, question := range []string{"date of issue", "service start date", "service end date", "payment deadline"} {
+		fake := &Prompt.Fake{Answers: map[string]string{"date of issue": "09-10-2026", question: "soon"}}
+		Prompt.SetPrompter(fake)
+
+		if _, err := InvoiceManager.CreateInvoice("SomeCompany"); !errors.Is(err, InvoiceManager.ErrInvalidDate) {
+			t.Errorf("CreateInvoice with %s soon = %v, want ErrInvalidDate", question, err)
+		}
+		if asked := strings.Count(strings.Join(fake.Asked, "\n"), question); asked != 3 {
+			t.Errorf("%s was asked %d times, want 3", question, asked)
+		}
+	}
+}
+
+func TestCreateCorrectionAsksAgainForInvalidDate(t *testing.T) {
+	useTestConfig(t)
+
+	Prompt.SetPrompter(Prompt.NewScripted([]string{"09-10-2026", "", "", "", "", "Consulting", "", "100", "23", "", "10", "n"}, io.Discard))
+	original, err := InvoiceManager.CreateInvoice("SomeCompany")
+	if err != nil {
+		t.Fatalf("CreateInvoice: %v", err)
+	}
+	/* converted from a proforma, the link belongs to the original invoice only */
+	original.ProformaNo = "PRO/1/10/2026"
+
+	script := Prompt.NewScripted([]string{
+		"31-02-2026", // not a date
+		"20.10.2026", // not DD-MM-YYYY
+		"20-10-2026",
+		"8",
+		"",
+		"",
+		"n",
+	}, io.Discard)
+	Prompt.SetPrompter(script)
+
+	correction, err := InvoiceManager.CreateCorrection(original, "Fewer hours")
+	if err != nil {
+		t.Fatalf("CreateCorrection: %v", err)
+	}
+	if remaining := script.Remaining(); len(remaining) != 0 {
+		t.Errorf("answers left unused: %q", remaining)
+	}
+
+	if correction.DateOfIssue != "20-10-2026" || correction.Payment.Deadline == "" {
+		t.Errorf("correction issued on %q, payment deadline %q", correction.DateOfIssue, correction.Payment.Deadline)
+	}
+	if correction.ProformaNo != "" || correction.ConvertedToInvoiceNo != "" {
+		t.Errorf("correction links proforma %q and invoice %q", correction.ProformaNo, correction.ConvertedToInvoiceNo)
+	}
+
+	fake := &Prompt.Fake{Answers: map[string]string{"date of issue": "tomorrow"}}
+	Prompt.SetPrompter(fake)
+
+	if _, err := InvoiceManager.CreateCorrection(original, "Fewer hours"); !errors.Is(err, InvoiceManager.ErrInvalidDate) {
+		t.Fatalf("CreateCorrection = %v, want ErrInvalidDate", err)
+	}
+	if len(fake.Asked) != 3 {
+		t.Errorf("asked %d questions, want the date of issue 3 times", len(fake.Asked))
+	}
+}
+
+func TestCreateInvoiceRefusesExemptPositionWithoutBasis(t *testing.T) {
+	useTestConfig(t)
+
+	fake := &Prompt.Fake{Answers: map[string]string{
+		"date of issue": "09-10-2026",
+		"tax rate":      "zw",
+	}}
+	Prompt.SetPrompter(fake)
+
+	_, err := InvoiceManager.CreateInvoice("SomeCompany")
+	if !errors.Is(err, Invoice.ErrMissingExemptionBasis) {
+		t.Fatalf("CreateInvoice = %v, want ErrMissingExemptionBasis", err)
+	}
+
+	if asked := strings.Count(strings.Join(fake.Asked, "\n"), "legal basis for zw"); asked != 3 {
+		t.Errorf("legal basis was asked %d times, want 3", asked)
+	}
+}
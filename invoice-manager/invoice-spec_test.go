@@ -0,0 +1,139 @@
+package InvoiceManager_test
+
+import (
+	"encoding/json"
+	"errors"
+	InvoiceManager "moneybringer/invoice-manager"
+	Invoice "moneybringer/invoice-manager/invoice"
+	InvoiceSpec "moneybringer/invoice-manager/spec"
+	AppPaths "moneybringer/utils/app-paths"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+/* useTestConfigWith copies the test configuration to a temporary directory after change edits company.json */
+func useTestConfigWith(t *testing.T, change func(company map[string]interface{})) {
+	t.Helper()
+
+	useTestConfig(t)
+	dir := t.TempDir()
+
+	customers, err := os.ReadFile("testdata/config/customers.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "customers.json"), customers, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	jsonData, err := os.ReadFile("testdata/config/company.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var company map[string]interface{}
+	if err := json.Unmarshal(jsonData, &company); err != nil {
+		t.Fatal(err)
+	}
+	change(company)
+	if jsonData, err = json.Marshal(company); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "company.json"), jsonData, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	AppPaths.SetConfigDir(dir)
+}
+
+func withDefaultExemptionBasis(basis string) func(company map[string]interface{}) {
+	return func(company map[string]interface{}) {
+		company["invoicePosition"].(map[string]interface{})["defaultExemptionBasis"] = basis
+	}
+}
+
+func loadSpec(t *testing.T, content string) InvoiceSpec.Spec {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "spec.yaml")
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	spec, err := InvoiceSpec.Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return spec
+}
+
+const exemptSpec = `customer: SomeCompany
+dateOfIssue: 09-10-2026
+serviceStartDate: 10-09-2026
+serviceEndDate: 09-10-2026
+positions:
+  - product: Medical consulting
+    netPrice: 500
+    quantity: 2
+    taxRate: zw
+`
+
+func TestSpecTakesExemptionBasisFromConfig(t *testing.T) {
+	useTestConfigWith(t, withDefaultExemptionBasis("art. 43 ust. 1 pkt 19"))
+
+	invoice, err := InvoiceManager.CreateInvoiceFromSpec(loadSpec(t, exemptSpec))
+	if err != nil {
+		t.Fatalf("CreateInvoiceFromSpec: %v", err)
+	}
+
+	if basis := invoice.InvoicePositions[0].ExemptionBasis; basis != "art. 43 ust. 1 pkt 19" {
+		t.Errorf("exemption basis = %q, want defaultExemptionBasis from company.json", basis)
+	}
+
+	invoice, err = InvoiceManager.CreateInvoiceFromSpec(loadSpec(t, exemptSpec+"    exemptionBasis: art. 113 ust. 1\n"))
+	if err != nil {
+		t.Fatalf("CreateInvoiceFromSpec: %v", err)
+	}
+	if basis := invoice.InvoicePositions[0].ExemptionBasis; basis != "art. 113 ust. 1" {
+		t.Errorf("exemption basis = %q, want the one given in the spec", basis)
+	}
+}
+
+func TestSpecWithoutExemptionBasisIsRefused(t *testing.T) {
+	useTestConfigWith(t, withDefaultExemptionBasis(""))
+
+	_, err := InvoiceManager.CreateInvoiceFromSpec(loadSpec(t, exemptSpec))
+	if !errors.Is(err, Invoice.ErrMissingExemptionBasis) {
+		t.Errorf("zw position without a basis in the spec or company.json = %v, want ErrMissingExemptionBasis", err)
+	}
+}
+
+func TestNumberingContinuesAfterStoredInvoices(t *testing.T) {
+	useTestConfigWith(t, withDefaultExemptionBasis("art. 43 ust. 1 pkt 19"))
+
+	InvoiceManager.SetStoredInvoices(func() ([]InvoiceManager.InvoiceCreatedData, error) {
+		return []InvoiceManager.InvoiceCreatedData{
+			{InvoiceNo: "1/10/2026", DateOfIssue: "01-10-2026"},
+			{InvoiceNo: "3/10/2026", DateOfIssue: "05-10-2026"},
+			{InvoiceNo: "KOR/5/10/2026", DateOfIssue: "06-10-2026", DocumentKind: InvoiceManager.DOCUMENT_KIND_CORRECTION},
+			{InvoiceNo: "9/10/2026", DateOfIssue: "07-10-2026", Profile: "other"},
+		}, nil
+	})
+	t.Cleanup(func() { InvoiceManager.SetStoredInvoices(nil) })
+
+	invoice, err := InvoiceManager.CreateInvoiceFromSpec(loadSpec(t, exemptSpec))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if invoice.InvoiceNo != "4/10/2026" {
+		t.Errorf("previewed number = %q, want 4/10/2026 after the stored 3/10/2026", invoice.InvoiceNo)
+	}
+
+	if err := InvoiceManager.IssueInvoice(&invoice, func(InvoiceManager.InvoiceCreatedData) error { return nil }); err != nil {
+		t.Fatal(err)
+	}
+	if invoice.InvoiceNo != "4/10/2026" {
+		t.Errorf("issued number = %q, want 4/10/2026", invoice.InvoiceNo)
+	}
+}
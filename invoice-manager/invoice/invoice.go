@@ -1,14 +1,12 @@
 package Invoice
 
 import (
-	"bufio"
+	"errors"
 	"fmt"
 	CompanyData "moneybringer/invoice-manager/company"
-	TimeUtils "moneybringer/utils/time"
-	"os"
-	"path/filepath"
-	"strconv"
-	"strings"
+	TaxRate "moneybringer/invoice-manager/tax-rate"
+	Money "moneybringer/utils/money"
+	Prompt "moneybringer/utils/prompt"
 )
 
 type InvoicePosition struct {
@@ -17,62 +15,35 @@ type InvoicePosition struct {
 	PolishClassificationOfGoodsAndServices string
 	Unit                                   string
 	Quantity                               int
-	NetPrice                               float32
-	NetValue                               float32
-	TaxRate                                int
-	TaxAmount                              float32
-	GrossValue                             float32
+	NetPrice                               Money.Money
+	NetValue                               Money.Money
+	TaxRate                                TaxRate.TaxRate
+	ExemptionBasis                         string
+	TaxAmount                              Money.Money
+	GrossValue                             Money.Money
 	Currency                               string
 }
 
-const INVOICES_DIR_PATH = "./invoices"
+/* DEFAULT_QUANTITY is offered for new positions, a month of hourly work */
+const DEFAULT_QUANTITY = 160
 
-func GetInvoiceDirPath() string {
-	currentTime := TimeUtils.GetCurrentTime()
-	currentYear := strconv.Itoa(currentTime.Year())
-	yearDirPath := filepath.Join(INVOICES_DIR_PATH, currentYear)
+/* exemptionBasisAttempts limits asking again for a missing legal basis, input that only has empty answers gives up */
+const exemptionBasisAttempts = 3
 
-	if _, yearErr := os.Stat(yearDirPath); os.IsNotExist(yearErr) {
-		os.Mkdir(yearDirPath, 0755)
-		fmt.Printf("Directory created: %s", yearDirPath)
-	}
-
-	monthName := currentTime.Month()
-
-	monthDirPath := filepath.Join(yearDirPath, monthName.String())
+/* ErrMissingExemptionBasis is returned for zw positions without a legal basis, the invoice must state it (P_19A) */
+var ErrMissingExemptionBasis = errors.New("VAT exempt (zw) position needs the legal basis of the exemption")
 
-	if _, monthErr := os.Stat(monthDirPath); os.IsNotExist(monthErr) {
-		os.Mkdir(monthDirPath, 0755)
-		fmt.Printf("Directory created: %s", monthDirPath)
-	}
-
-	return monthDirPath
-}
-
-func GetInvoicePositions(defaultPosition CompanyData.InvoicePosition) []InvoicePosition {
+/* GetInvoicePositions asks for positions in the given invoice currency, the currency is not asked per position */
+func GetInvoicePositions(defaultPosition CompanyData.InvoicePosition, currency string) []InvoicePosition {
 	var positionsCounter int = 0
-	var shouldAddNewPosition bool = true
 	var invoicePositionsSlice []InvoicePosition
 
 	for {
 		positionsCounter++
-		position := createInvoicePosition(positionsCounter, defaultPosition)
+		position := createInvoicePosition(positionsCounter, defaultPosition, currency)
 		invoicePositionsSlice = append(invoicePositionsSlice, position)
 
-		fmt.Printf("Shoul add another position? Y/n (yes, no)")
-
-		var input string
-		_, err := fmt.Scanln(&input)
-
-		if err != nil {
-			shouldAddNewPosition = false
-		}
-
-		if input != "Y" {
-			shouldAddNewPosition = false
-		}
-
-		if shouldAddNewPosition == false {
+		if !Prompt.Confirm("Should add another position? y/N (yes, no)") {
 			break
 		}
 	}
@@ -80,110 +51,126 @@ func GetInvoicePositions(defaultPosition CompanyData.InvoicePosition) []InvoiceP
 	return invoicePositionsSlice
 }
 
-func createInvoicePosition(itemNo int, defaultPosition CompanyData.InvoicePosition) InvoicePosition {
-	fmt.Printf("Enter product (or press Enter to use the default: %s):", defaultPosition.DefaultProduct)
-	productOrServiceName := createStringPosition(defaultPosition.DefaultProduct)
+func createInvoicePosition(itemNo int, defaultPosition CompanyData.InvoicePosition, currency string) InvoicePosition {
+	productOrServiceName := Prompt.String(fmt.Sprintf("Enter product (or press Enter to use the default: %s):", defaultPosition.DefaultProduct), defaultPosition.DefaultProduct)
 
-	fmt.Printf("Enter unit (or press Enter to use the default: %s):", defaultPosition.DefaultUnit)
-	unit := createStringPosition(defaultPosition.DefaultUnit)
+	unit := Prompt.String(fmt.Sprintf("Enter unit (or press Enter to use the default: %s):", defaultPosition.DefaultUnit), defaultPosition.DefaultUnit)
 
-	fmt.Printf("Enter net price (or press Enter to use the default: %f):", defaultPosition.DefaultNetPrice)
-	netPrice := createFloatPosition(defaultPosition.DefaultNetPrice)
+	defaultNetPrice := Money.FromFloat(defaultPosition.DefaultNetPrice, currency)
+	netPrice := createMoneyPosition(fmt.Sprintf("Enter net price in %s (or press Enter to use the default: %s):", currency, defaultNetPrice), defaultNetPrice)
 
-	fmt.Printf("Enter tax rate (or press Enter to use the default: %f):", defaultPosition.DefaultTaxRate)
-	taxRate := createFloatPosition(defaultPosition.DefaultTaxRate)
+	taxRate := createTaxRatePosition(fmt.Sprintf("Enter tax rate - 23, 8, 5, 0, zw, np or oo (or press Enter to use the default: %s):", defaultPosition.DefaultTaxRate.Label()), defaultPosition.DefaultTaxRate)
 
-	fmt.Printf("Enter polish classification of goods and services (or press Enter to use the default: %s):", defaultPosition.PolishClassificationOfGoodsAndServices)
-	polishClassificationOfGoodsAndServices := createStringPosition(defaultPosition.PolishClassificationOfGoodsAndServices)
+	exemptionBasis := ""
+	if taxRate == TaxRate.EXEMPT || taxRate == TaxRate.NOT_SUBJECT {
+		exemptionBasis = askExemptionBasis(taxRate, fmt.Sprintf("Enter legal basis for %s (or press Enter to use the default: %s):", taxRate, defaultPosition.DefaultExemptionBasis), defaultPosition.DefaultExemptionBasis)
+	}
 
-	fmt.Printf("Enter quantity (or press Enter to use the default: %d):", 160)
-	quantity := createIntPosition(160)
+	polishClassificationOfGoodsAndServices := Prompt.String(fmt.Sprintf("Enter polish classification of goods and services (or press Enter to use the default: %s):", defaultPosition.PolishClassificationOfGoodsAndServices), defaultPosition.PolishClassificationOfGoodsAndServices)
 
-	netValue := netPrice * float64(quantity)
-	taxAmount := calculateTaxAmount(taxRate, netValue)
-	grossValue := netValue + taxAmount
+	quantity := Prompt.Int(fmt.Sprintf("Enter quantity (or press Enter to use the default: %d):", DEFAULT_QUANTITY), DEFAULT_QUANTITY)
 
-	fmt.Printf("Enter currency (or press Enter to use the default: %s):", defaultPosition.DefaultCurrency)
-	Currency := createStringPosition(defaultPosition.DefaultCurrency)
+	position := NewInvoicePosition(itemNo, productOrServiceName, polishClassificationOfGoodsAndServices, unit, quantity, netPrice, taxRate, currency)
+	position.ExemptionBasis = exemptionBasis
 
-	return InvoicePosition{
-		ItemNo:                                 itemNo,
-		ProductOrServiceName:                   productOrServiceName,
-		PolishClassificationOfGoodsAndServices: polishClassificationOfGoodsAndServices,
-		Unit:                                   unit,
-		Quantity:                               quantity,
-		NetPrice:                               float32(netPrice),
-		NetValue:                               float32(netValue),
-		TaxRate:                                int(taxRate),
-		TaxAmount:                              float32(taxAmount),
-		GrossValue:                             float32(grossValue),
-		Currency:                               Currency,
-	}
+	return position
 }
 
-func createStringPosition(defaultValue string) string {
-	var input string
-	var value string
-	reader := bufio.NewReader(os.Stdin)
-	input, err := reader.ReadString('\n')
+/* CorrectInvoicePosition asks for the values after correction, false means the position is removed */
+func CorrectInvoicePosition(position InvoicePosition) (InvoicePosition, bool) {
+	fmt.Printf("Position %d: %s, %d %s x %s %s, VAT %s\n", position.ItemNo, position.ProductOrServiceName, position.Quantity, position.Unit, position.NetPrice, position.Currency, position.TaxRate.Label())
 
-	if err != nil {
-		value = defaultValue
+	quantity := Prompt.Int(fmt.Sprintf("Enter quantity after correction, 0 removes the position (or press Enter to keep: %d):", position.Quantity), position.Quantity)
+	if quantity <= 0 {
+		return position, false
 	}
 
-	// Trim spaces and newline characters
-	value = strings.TrimSpace(input)
+	netPrice := createMoneyPosition(fmt.Sprintf("Enter net price after correction (or press Enter to keep: %s):", position.NetPrice), position.NetPrice)
+
+	taxRate := createTaxRatePosition(fmt.Sprintf("Enter tax rate after correction (or press Enter to keep: %s):", position.TaxRate.Label()), position.TaxRate)
 
-	if err != nil || value == "" {
-		value = defaultValue
+	exemptionBasis := ""
+	if taxRate == TaxRate.EXEMPT || taxRate == TaxRate.NOT_SUBJECT {
+		exemptionBasis = askExemptionBasis(taxRate, fmt.Sprintf("Enter legal basis for %s (or press Enter to keep: %s):", taxRate, position.ExemptionBasis), position.ExemptionBasis)
 	}
 
-	return value
-}
+	corrected := NewInvoicePosition(position.ItemNo, position.ProductOrServiceName, position.PolishClassificationOfGoodsAndServices, position.Unit, quantity, netPrice, taxRate, position.Currency)
+	corrected.ExemptionBasis = exemptionBasis
 
-func createIntPosition(defaultValue int) int {
+	return corrected, true
+}
 
-	var input string
-	_, err := fmt.Scanln(&input)
+/* askExemptionBasis asks again while a zw position has no legal basis, CheckExemptionBasis refuses it after that */
+func askExemptionBasis(taxRate TaxRate.TaxRate, question string, defaultValue string) string {
+	exemptionBasis := Prompt.String(question, defaultValue)
 
-	// Handle case when Scanln does not read anything
-	if err != nil && err.Error() == "expected newline" {
-		input = "" // Set to empty if newline is detected
+	for attempt := 1; taxRate == TaxRate.EXEMPT && exemptionBasis == "" && attempt < exemptionBasisAttempts; attempt++ {
+		fmt.Println("A VAT exempt position needs the legal basis, e.g. art. 43 ust. 1 pkt 19 ustawy o VAT")
+		exemptionBasis = Prompt.String(question, defaultValue)
 	}
 
-	// Convert input to int, or use default value if empty
-	number, convErr := strconv.Atoi(strings.TrimSpace(input))
-	if convErr != nil || input == "" {
-		number = defaultValue
+	return exemptionBasis
+}
+
+/* CheckExemptionBasis refuses zw positions without a legal basis before an invoice with them is issued */
+func CheckExemptionBasis(positions []InvoicePosition) error {
+	for _, position := range positions {
+		if position.TaxRate == TaxRate.EXEMPT && position.ExemptionBasis == "" {
+			return fmt.Errorf("%w: position %d %s", ErrMissingExemptionBasis, position.ItemNo, position.ProductOrServiceName)
+		}
 	}
 
-	return number
+	return nil
 }
 
-func createFloatPosition(defaultValue float64) float64 {
+func NewInvoicePosition(itemNo int, productOrServiceName string, polishClassificationOfGoodsAndServices string, unit string, quantity int, netPrice Money.Money, taxRate TaxRate.TaxRate, currency string) InvoicePosition {
+	netPrice.Currency = currency
+	netValue := netPrice.MulInt(quantity)
+	taxAmount := CalculateTaxAmount(taxRate, netValue)
+	grossValue := netValue.Add(taxAmount)
+
+	return InvoicePosition{
+		ItemNo:                                 itemNo,
+		ProductOrServiceName:                   productOrServiceName,
+		PolishClassificationOfGoodsAndServices: polishClassificationOfGoodsAndServices,
+		Unit:                                   unit,
+		Quantity:                               quantity,
+		NetPrice:                               netPrice,
+		NetValue:                               netValue,
+		TaxRate:                                taxRate,
+		TaxAmount:                              taxAmount,
+		GrossValue:                             grossValue,
+		Currency:                               currency,
+	}
+}
 
-	var input string
-	_, err := fmt.Scanln(&input)
+func createTaxRatePosition(question string, defaultValue TaxRate.TaxRate) TaxRate.TaxRate {
+	input := Prompt.String(question, string(defaultValue))
 
-	// Handle case when Scanln does not read anything
-	if err != nil && err.Error() == "expected newline" {
-		input = "" // Set to empty if newline is detected
+	taxRate, err := TaxRate.Parse(input)
+	if err != nil {
+		fmt.Println(err, "- using the default:", defaultValue.Label())
+		return defaultValue
 	}
 
-	// Convert input to float64, or use default value if empty
-	number, convErr := strconv.ParseFloat(strings.TrimSpace(input), 64)
-	if convErr != nil || input == "" {
-		number = defaultValue
+	return taxRate
+}
+
+/* createMoneyPosition parses the answer as an exact decimal amount, answers that are not an amount take the default */
+func createMoneyPosition(question string, defaultValue Money.Money) Money.Money {
+	amount, err := Money.Parse(Prompt.String(question, defaultValue.String()), defaultValue.Currency)
+	if err != nil {
+		return defaultValue
 	}
 
-	return number
+	return amount
 }
 
-func calculateTaxAmount(taxRate float64, netValue float64) float64 {
-	if taxRate > 0 {
-		taxDecimal := taxRate / 100
-		return netValue * taxDecimal
+/* VAT is rounded half up to full grosze on the given net value, zw/np/oo positions carry no VAT */
+func CalculateTaxAmount(taxRate TaxRate.TaxRate, netValue Money.Money) Money.Money {
+	if taxRate.Percent() > 0 {
+		return netValue.Percent(taxRate.Percent())
 	}
 
-	return 0
+	return Money.Zero(netValue.Currency)
 }
@@ -0,0 +1,360 @@
+package InvoiceNumbering
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+/*
+Registry keeps the last issued sequence number per series and period in a JSON
+file, so numbers stay unique and gap-free no matter what else lives in the
+invoices directories. Every write happens under a lock file. A series the registry
+does not know yet is seeded with the numbers already issued in it, so switching to the
+registry continues the numbering of invoices stored before.
+*/
+type Registry struct {
+	path   string
+	issued IssuedNumbers
+}
+
+/* IssuedNumber is a number already used in a series with the date it was issued on */
+type IssuedNumber struct {
+	Number string
+	Date   time.Time
+}
+
+/* IssuedNumbers lists the numbers already used in series, it is asked once, on the first use of the series */
+type IssuedNumbers func(series string) ([]IssuedNumber, error)
+
+type seriesState struct {
+	Periods map[string]int `json:"periods"`
+	Issued  []string       `json:"issued"`
+}
+
+type registryData struct {
+	Series map[string]*seriesState `json:"series"`
+}
+
+const DEFAULT_PATTERN = "{n}/{M}/{YYYY}"
+
+const lockRetryInterval = 50 * time.Millisecond
+const lockTimeout = 5 * time.Second
+
+/* The holder touches the lock file while it works, a lock left untouched that long is left over from a crash */
+const staleLockAge = 30 * time.Second
+const lockRefreshInterval = staleLockAge / 5
+
+var ErrNumberReused = errors.New("invoice number was already issued")
+var ErrRegistryLocked = errors.New("numbering registry is locked by another process")
+
+var tokenRegexp = regexp.MustCompile(`\{(n(?::(\d+))?|MM|M|YYYY|YY)\}`)
+
+/* NewRegistry keeps the registry in the JSON file at path, issued may be nil when there is nothing to seed from */
+func NewRegistry(path string, issued IssuedNumbers) *Registry {
+	return &Registry{path: path, issued: issued}
+}
+
+func ValidatePattern(pattern string) error {
+	for _, match := range tokenRegexp.FindAllStringSubmatch(pattern, -1) {
+		if strings.HasPrefix(match[1], "n") {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("numbering pattern %q must contain {n} or {n:0W}", pattern)
+}
+
+func Format(pattern string, sequence int, date time.Time) string {
+	return tokenRegexp.ReplaceAllStringFunc(pattern, func(token string) string {
+		match := tokenRegexp.FindStringSubmatch(token)
+
+		switch match[1] {
+		case "MM":
+			return fmt.Sprintf("%02d", int(date.Month()))
+		case "M":
+			return strconv.Itoa(int(date.Month()))
+		case "YYYY":
+			return fmt.Sprintf("%04d", date.Year())
+		case "YY":
+			return fmt.Sprintf("%02d", date.Year()%100)
+		}
+
+		if match[2] != "" {
+			width, _ := strconv.Atoi(match[2])
+			return fmt.Sprintf("%0*d", width, sequence)
+		}
+
+		return strconv.Itoa(sequence)
+	})
+}
+
+/* Sequences restart every month or year depending on the date tokens used in the pattern */
+func periodKey(pattern string, date time.Time) string {
+	if strings.Contains(pattern, "{M}") || strings.Contains(pattern, "{MM}") {
+		return date.Format("2006-01")
+	}
+
+	if strings.Contains(pattern, "{YYYY}") || strings.Contains(pattern, "{YY}") {
+		return date.Format("2006")
+	}
+
+	return "all"
+}
+
+/* Peek returns the number the next Issue call would assign, without reserving it */
+func (r *Registry) Peek(series string, pattern string, date time.Time) (string, error) {
+	if err := ValidatePattern(pattern); err != nil {
+		return "", err
+	}
+
+	data, err := r.load()
+	if err != nil {
+		return "", err
+	}
+
+	state, err := r.state(data, series, pattern)
+	if err != nil {
+		return "", err
+	}
+
+	return Format(pattern, state.Periods[periodKey(pattern, date)]+1, date), nil
+}
+
+/*
+Issue assigns the next number and calls persist with it while holding the lock.
+The number is reserved in the registry before persist runs and released again when
+persist fails, so a stored invoice always has its number recorded and a failed save
+leaves no gap behind. Errors of persist are returned as they are.
+*/
+func (r *Registry) Issue(series string, pattern string, date time.Time, persist func(number string) error) (string, error) {
+	if err := ValidatePattern(pattern); err != nil {
+		return "", err
+	}
+
+	unlock, err := r.lock()
+	if err != nil {
+		return "", err
+	}
+	defer unlock()
+
+	data, err := r.load()
+	if err != nil {
+		return "", err
+	}
+
+	state, err := r.state(data, series, pattern)
+	if err != nil {
+		return "", err
+	}
+	period := periodKey(pattern, date)
+	sequence := state.Periods[period] + 1
+	number := Format(pattern, sequence, date)
+
+	for _, issued := range state.Issued {
+		if issued == number {
+			return "", fmt.Errorf("%w: %s (series %s)", ErrNumberReused, number, series)
+		}
+	}
+
+	state.Periods[period] = sequence
+	state.Issued = append(state.Issued, number)
+
+	if err := r.save(data); err != nil {
+		return "", fmt.Errorf("cannot reserve number %s: %w", number, err)
+	}
+
+	if persistErr := persist(number); persistErr != nil {
+		state.Periods[period] = sequence - 1
+		state.Issued = state.Issued[:len(state.Issued)-1]
+
+		/* a number left reserved is a gap, which is still better than issuing it twice */
+		if err := r.save(data); err != nil {
+			return "", fmt.Errorf("%w; number %s stays reserved, releasing it failed: %w", persistErr, number, err)
+		}
+
+		return "", persistErr
+	}
+
+	return number, nil
+}
+
+func (r *Registry) state(data *registryData, series string, pattern string) (*seriesState, error) {
+	if data.Series == nil {
+		data.Series = map[string]*seriesState{}
+	}
+
+	state, exists := data.Series[series]
+	if !exists {
+		state = &seriesState{}
+		if r.issued != nil {
+			numbers, err := r.issued(series)
+			if err != nil {
+				return nil, fmt.Errorf("cannot seed series %s with the numbers already issued: %w", series, err)
+			}
+			state.seed(pattern, numbers)
+		}
+		data.Series[series] = state
+	}
+
+	if state.Periods == nil {
+		state.Periods = map[string]int{}
+	}
+
+	return state, nil
+}
+
+/*
+seed continues every period after the highest sequence issued in it. Numbers that do not
+follow pattern, e.g. issued before the pattern changed, are only guarded against reuse.
+*/
+func (state *seriesState) seed(pattern string, numbers []IssuedNumber) {
+	state.Periods = map[string]int{}
+
+	for _, issued := range numbers {
+		state.Issued = append(state.Issued, issued.Number)
+
+		sequence, matches := parseSequence(pattern, issued.Number, issued.Date)
+		period := periodKey(pattern, issued.Date)
+		if matches && sequence > state.Periods[period] {
+			state.Periods[period] = sequence
+		}
+	}
+}
+
+/* parseSequence reads {n} back from a number formatted with pattern for date */
+func parseSequence(pattern string, number string, date time.Time) (int, bool) {
+	var expression strings.Builder
+	expression.WriteString("^")
+
+	last := 0
+	for _, match := range tokenRegexp.FindAllStringSubmatchIndex(pattern, -1) {
+		expression.WriteString(regexp.QuoteMeta(pattern[last:match[0]]))
+
+		if pattern[match[2]] == 'n' {
+			expression.WriteString(`(\d+)`)
+		} else {
+			expression.WriteString(regexp.QuoteMeta(Format(pattern[match[0]:match[1]], 0, date)))
+		}
+		last = match[1]
+	}
+	expression.WriteString(regexp.QuoteMeta(pattern[last:]) + "$")
+
+	match := regexp.MustCompile(expression.String()).FindStringSubmatch(number)
+	if match == nil {
+		return 0, false
+	}
+
+	sequence, err := strconv.Atoi(match[1])
+
+	return sequence, err == nil
+}
+
+func (r *Registry) load() (*registryData, error) {
+	var data registryData
+
+	jsonData, err := os.ReadFile(r.path)
+	if os.IsNotExist(err) {
+		return &data, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(jsonData, &data); err != nil {
+		return nil, fmt.Errorf("corrupted numbering registry %s: %w", r.path, err)
+	}
+
+	return &data, nil
+}
+
+func (r *Registry) save(data *registryData) error {
+	if err := os.MkdirAll(filepath.Dir(r.path), os.ModePerm); err != nil {
+		return err
+	}
+
+	jsonData, err := json.MarshalIndent(data, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmpPath := r.path + ".tmp"
+	if err := os.WriteFile(tmpPath, jsonData, 0644); err != nil {
+		return err
+	}
+
+	return os.Rename(tmpPath, r.path)
+}
+
+func (r *Registry) lock() (func(), error) {
+	if err := os.MkdirAll(filepath.Dir(r.path), os.ModePerm); err != nil {
+		return nil, err
+	}
+
+	lockPath := r.path + ".lock"
+	deadline := time.Now().Add(lockTimeout)
+
+	for {
+		file, err := os.OpenFile(lockPath, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+		if err == nil {
+			file.Close()
+
+			done, stopped := make(chan struct{}), make(chan struct{})
+			go keepLockFresh(lockPath, done, stopped)
+
+			return func() {
+				close(done)
+				<-stopped
+				os.Remove(lockPath)
+			}, nil
+		}
+
+		if !os.IsExist(err) {
+			return nil, err
+		}
+
+		if lockIsStale(lockPath) {
+			os.Remove(lockPath)
+			continue
+		}
+
+		if time.Now().After(deadline) {
+			return nil, fmt.Errorf("%w (%s)", ErrRegistryLocked, lockPath)
+		}
+
+		time.Sleep(lockRetryInterval)
+	}
+}
+
+/* keepLockFresh touches the lock until done is closed, so a slow persist never makes it look stale */
+func keepLockFresh(lockPath string, done <-chan struct{}, stopped chan<- struct{}) {
+	defer close(stopped)
+
+	ticker := time.NewTicker(lockRefreshInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-done:
+			return
+		case now := <-ticker.C:
+			os.Chtimes(lockPath, now, now)
+		}
+	}
+}
+
+/*
+A lock is stale when its holder has not touched it for staleLockAge, e.g. after a crash.
+Only the age counts, the process holding it may run on another machine sharing the directory.
+*/
+func lockIsStale(lockPath string) bool {
+	info, err := os.Stat(lockPath)
+
+	return err == nil && time.Since(info.ModTime()) > staleLockAge
+}
@@ -0,0 +1,292 @@
+package InvoiceNumbering_test
+
+import (
+	"errors"
+	InvoiceNumbering "moneybringer/invoice-manager/numbering"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func date(year int, month time.Month, day int) time.Time {
+	return time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+}
+
+func newRegistry(t *testing.T, issued InvoiceNumbering.IssuedNumbers) (*InvoiceNumbering.Registry, string) {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "numbering.json")
+
+	return InvoiceNumbering.NewRegistry(path, issued), path
+}
+
+func saved(string) error {
+	return nil
+}
+
+func issue(t *testing.T, registry *InvoiceNumbering.Registry, pattern string, on time.Time) string {
+	t.Helper()
+
+	number, err := registry.Issue("invoice", pattern, on, saved)
+	if err != nil {
+		t.Fatalf("Issue(%s, %s): %v", pattern, on.Format("2006-01-02"), err)
+	}
+
+	return number
+}
+
+func TestFormat(t *testing.T) {
+	cases := []struct {
+		pattern  string
+		sequence int
+		date     time.Time
+		expected string
+	}{
+		{"{n}/{M}/{YYYY}", 7, date(2026, time.March, 5), "7/3/2026"},
+		{"{n}/{MM}/{YYYY}", 7, date(2026, time.March, 5), "7/03/2026"},
+		{"{n}/{MM}/{YYYY}", 12, date(2026, time.December, 5), "12/12/2026"},
+		{"FV/{YYYY}/{n:04}", 7, date(2026, time.March, 5), "FV/2026/0007"},
+		{"FV/{YYYY}/{n:04}", 12345, date(2026, time.March, 5), "FV/2026/12345"},
+		{"{n:2}-{YY}", 3, date(2009, time.January, 1), "03-09"},
+		{"{n:01}", 42, date(2026, time.March, 5), "42"},
+		{"{YY}{MM}{n:03}", 9, date(2026, time.October, 9), "2610009"},
+		{"no tokens {x} {n}", 1, date(2026, time.March, 5), "no tokens {x} 1"},
+	}
+
+	for _, c := range cases {
+		if got := InvoiceNumbering.Format(c.pattern, c.sequence, c.date); got != c.expected {
+			t.Errorf("Format(%q, %d) = %q, want %q", c.pattern, c.sequence, got, c.expected)
+		}
+	}
+}
+
+func TestValidatePattern(t *testing.T) {
+	for pattern, valid := range map[string]bool{"{n}/{MM}/{YYYY}": true, "FV/{YYYY}/{n:04}": true, "{n}": true, "FV/{YYYY}/{MM}": false, "{N}": false, "": false} {
+		if err := InvoiceNumbering.ValidatePattern(pattern); (err == nil) != valid {
+			t.Errorf("ValidatePattern(%q) = %v, want valid %v", pattern, err, valid)
+		}
+	}
+}
+
+func TestSequencesRestartWithThePeriodOfThePattern(t *testing.T) {
+	cases := []struct {
+		name     string
+		pattern  string
+		dates    []time.Time
+		expected []string
+	}{
+		{
+			name:     "monthly",
+			pattern:  "{n}/{MM}/{YYYY}",
+			dates:    []time.Time{date(2026, time.October, 1), date(2026, time.October, 31), date(2026, time.November, 1), date(2027, time.October, 1)},
+			expected: []string{"1/10/2026", "2/10/2026", "1/11/2026", "1/10/2027"},
+		},
+		{
+			name:     "monthly with a single digit month",
+			pattern:  "{n}/{M}/{YY}",
+			dates:    []time.Time{date(2026, time.March, 1), date(2026, time.March, 31), date(2026, time.April, 1)},
+			expected: []string{"1/3/26", "2/3/26", "1/4/26"},
+		},
+		{
+			name:     "yearly",
+			pattern:  "FV/{YYYY}/{n:04}",
+			dates:    []time.Time{date(2026, time.January, 2), date(2026, time.December, 31), date(2027, time.January, 1)},
+			expected: []string{"FV/2026/0001", "FV/2026/0002", "FV/2027/0001"},
+		},
+		{
+			name:     "yearly with two digits",
+			pattern:  "{YY}-{n}",
+			dates:    []time.Time{date(2026, time.January, 2), date(2026, time.July, 1), date(2027, time.January, 1)},
+			expected: []string{"26-1", "26-2", "27-1"},
+		},
+		{
+			name:     "never",
+			pattern:  "INV-{n}",
+			dates:    []time.Time{date(2026, time.January, 2), date(2027, time.January, 1)},
+			expected: []string{"INV-1", "INV-2"},
+		},
+	}
+
+	for _, c := range cases {
+		registry, _ := newRegistry(t, nil)
+
+		for i, on := range c.dates {
+			if number := issue(t, registry, c.pattern, on); number != c.expected[i] {
+				t.Errorf("%s: number %d = %q, want %q", c.name, i+1, number, c.expected[i])
+			}
+		}
+	}
+}
+
+func TestSeriesAreNumberedSeparately(t *testing.T) {
+	registry, _ := newRegistry(t, nil)
+	on := date(2026, time.October, 9)
+
+	issue(t, registry, "{n}/{MM}/{YYYY}", on)
+	correction, err := registry.Issue("correction", "KOR/{n}/{MM}/{YYYY}", on, saved)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if correction != "KOR/1/10/2026" {
+		t.Errorf("first correction = %q, want KOR/1/10/2026", correction)
+	}
+}
+
+func TestPeekDoesNotReserve(t *testing.T) {
+	registry, _ := newRegistry(t, nil)
+	on := date(2026, time.October, 9)
+
+	for i := 0; i < 2; i++ {
+		if number, err := registry.Peek("invoice", "{n}/{MM}/{YYYY}", on); err != nil || number != "1/10/2026" {
+			t.Errorf("Peek = %q, %v, want 1/10/2026", number, err)
+		}
+	}
+}
+
+func TestIssueReleasesTheNumberWhenPersistFails(t *testing.T) {
+	registry, path := newRegistry(t, nil)
+	pattern := "{n}/{MM}/{YYYY}"
+	on := date(2026, time.October, 9)
+
+	issue(t, registry, pattern, on)
+
+	persistErr := errors.New("disk full")
+	var persisted string
+	_, err := registry.Issue("invoice", pattern, on, func(number string) error {
+		persisted = number
+
+		/* the number is recorded before the invoice is saved */
+		if content, readErr := os.ReadFile(path); readErr != nil || !strings.Contains(string(content), `"2/10/2026"`) {
+			t.Errorf("registry while persisting 2/10/2026:\n%s", content)
+		}
+
+		return persistErr
+	})
+	if err != persistErr {
+		t.Fatalf("Issue = %v, want the error of persist as it is", err)
+	}
+	if persisted != "2/10/2026" {
+		t.Errorf("persist got %q, want 2/10/2026", persisted)
+	}
+
+	/* the failed save leaves no gap and the number is not refused as reused */
+	if number := issue(t, registry, pattern, on); number != "2/10/2026" {
+		t.Errorf("number after a failed save = %q, want 2/10/2026 again", number)
+	}
+	if number := issue(t, registry, pattern, on); number != "3/10/2026" {
+		t.Errorf("next number = %q, want 3/10/2026", number)
+	}
+}
+
+func TestIssueRefusesReusedNumbers(t *testing.T) {
+	/* without a year in the pattern the numbers of October 2026 come back in October 2027 */
+	registry, _ := newRegistry(t, nil)
+	issue(t, registry, "{n}/{M}", date(2026, time.October, 1))
+	if _, err := registry.Issue("invoice", "{n}/{M}", date(2027, time.October, 1), saved); !errors.Is(err, InvoiceNumbering.ErrNumberReused) {
+		t.Errorf("Issue of 1/10 a year later = %v, want ErrNumberReused", err)
+	}
+
+	/* a number stored out of its period, e.g. with a date of issue edited by hand */
+	registry, _ = newRegistry(t, func(series string) ([]InvoiceNumbering.IssuedNumber, error) {
+		return []InvoiceNumbering.IssuedNumber{{Number: "1/11/2026", Date: date(2026, time.October, 30)}}, nil
+	})
+
+	persisted := false
+	_, err := registry.Issue("invoice", "{n}/{MM}/{YYYY}", date(2026, time.November, 2), func(string) error {
+		persisted = true
+		return nil
+	})
+
+	if !errors.Is(err, InvoiceNumbering.ErrNumberReused) || persisted {
+		t.Errorf("Issue of 1/11/2026 again = %v (persisted %v), want ErrNumberReused", err, persisted)
+	}
+}
+
+func TestNewSeriesContinueAfterIssuedNumbers(t *testing.T) {
+	asked := 0
+	registry, _ := newRegistry(t, func(series string) ([]InvoiceNumbering.IssuedNumber, error) {
+		asked++
+		if series != "invoice" {
+			return nil, nil
+		}
+
+		return []InvoiceNumbering.IssuedNumber{
+			{Number: "3/10/2026", Date: date(2026, time.October, 20)},
+			{Number: "1/10/2026", Date: date(2026, time.October, 1)},
+			{Number: "2/10/2026", Date: date(2026, time.October, 5)},
+			{Number: "7/09/2026", Date: date(2026, time.September, 30)},
+			/* issued with another pattern, only guarded against reuse */
+			{Number: "FV-99", Date: date(2026, time.October, 2)},
+		}, nil
+	})
+	pattern := "{n}/{MM}/{YYYY}"
+
+	if number, err := registry.Peek("invoice", pattern, date(2026, time.October, 25)); err != nil || number != "4/10/2026" {
+		t.Errorf("Peek = %q, %v, want 4/10/2026", number, err)
+	}
+
+	expected := map[time.Time]string{
+		date(2026, time.October, 25):   "4/10/2026",
+		date(2026, time.September, 30): "8/09/2026",
+		date(2026, time.November, 2):   "1/11/2026",
+	}
+	for on, want := range expected {
+		if number := issue(t, registry, pattern, on); number != want {
+			t.Errorf("number on %s = %q, want %q", on.Format("2006-01-02"), number, want)
+		}
+	}
+
+	/* the seed is kept in the registry, stored invoices are listed once per series */
+	if asked != 2 {
+		t.Errorf("issued numbers asked %d times, want once by Peek and once by the first Issue", asked)
+	}
+}
+
+func TestSeedFailureIsReported(t *testing.T) {
+	storageErr := errors.New("database is locked")
+	registry, _ := newRegistry(t, func(string) ([]InvoiceNumbering.IssuedNumber, error) {
+		return nil, storageErr
+	})
+
+	if _, err := registry.Issue("invoice", "{n}", date(2026, time.October, 9), saved); !errors.Is(err, storageErr) {
+		t.Errorf("Issue = %v, want the error of the issued numbers", err)
+	}
+}
+
+func TestStaleLockIsTakenOver(t *testing.T) {
+	registry, path := newRegistry(t, nil)
+
+	lockPath := path + ".lock"
+	if err := os.WriteFile(lockPath, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	crashed := time.Now().Add(-time.Minute)
+	if err := os.Chtimes(lockPath, crashed, crashed); err != nil {
+		t.Fatal(err)
+	}
+
+	if number := issue(t, registry, "{n}", date(2026, time.October, 9)); number != "1" {
+		t.Errorf("number = %q, want 1", number)
+	}
+	if _, err := os.Stat(lockPath); !os.IsNotExist(err) {
+		t.Errorf("lock left behind after Issue: %v", err)
+	}
+}
+
+func TestFreshLockIsRespected(t *testing.T) {
+	if testing.Short() {
+		t.Skip("waits for the lock timeout")
+	}
+
+	registry, path := newRegistry(t, nil)
+	if err := os.WriteFile(path+".lock", nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := registry.Issue("invoice", "{n}", date(2026, time.October, 9), saved); !errors.Is(err, InvoiceNumbering.ErrRegistryLocked) {
+		t.Errorf("Issue under a held lock = %v, want ErrRegistryLocked", err)
+	}
+}
@@ -0,0 +1,279 @@
+package InvoiceSpec
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	TaxRate "moneybringer/invoice-manager/tax-rate"
+	Money "moneybringer/utils/money"
+	TimeUtils "moneybringer/utils/time"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+type PositionSpec struct {
+	Product                                string           `json:"product" yaml:"product"`
+	Unit                                   string           `json:"unit" yaml:"unit"`
+	NetPrice                               *Amount          `json:"netPrice" yaml:"netPrice"`
+	TaxRate                                *TaxRate.TaxRate `json:"taxRate" yaml:"taxRate"`
+	ExemptionBasis                         string           `json:"exemptionBasis" yaml:"exemptionBasis"`
+	PolishClassificationOfGoodsAndServices string           `json:"polishClassificationOfGoodsAndServices" yaml:"polishClassificationOfGoodsAndServices"`
+	Quantity                               *int             `json:"quantity" yaml:"quantity"`
+	Currency                               string           `json:"currency" yaml:"currency"`
+}
+
+/*
+Amount is a decimal amount kept as written in the spec, 12.5 or "12,50", so it is never
+rounded through a float. Money converts it, more than 2 decimal places are refused.
+*/
+type Amount string
+
+var currencyRegexp = regexp.MustCompile(`^[A-Za-z]{3}$`)
+
+func AmountOf(money Money.Money) *Amount {
+	amount := Amount(money.String())
+	return &amount
+}
+
+func (amount Amount) Money(currency string) (Money.Money, error) {
+	return Money.Parse(string(amount), currency)
+}
+
+/* UnmarshalJSON accepts a JSON number or string and keeps its digits */
+func (amount *Amount) UnmarshalJSON(data []byte) error {
+	var text string
+	if err := json.Unmarshal(data, &text); err == nil {
+		*amount = Amount(text)
+		return nil
+	}
+
+	var number json.Number
+	if err := json.Unmarshal(data, &number); err != nil {
+		return fmt.Errorf("amount must be a number or a string, got %s", data)
+	}
+	*amount = Amount(number)
+
+	return nil
+}
+
+/* MarshalJSON writes amounts that are JSON numbers as numbers, so front-ends get numbers as before */
+func (amount Amount) MarshalJSON() ([]byte, error) {
+	if data, err := json.Marshal(json.Number(amount)); err == nil && amount != "" {
+		return data, nil
+	}
+
+	return json.Marshal(string(amount))
+}
+
+func (amount *Amount) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind != yaml.ScalarNode {
+		return fmt.Errorf("line %d: amount must be a number or a string", node.Line)
+	}
+	*amount = Amount(node.Value)
+
+	return nil
+}
+
+/*
+Spec describes a whole invoice so it can be created without stdin prompts.
+Empty optional fields (unit, taxRate, exemptionBasis, classification, currency,
+paymentDeadline, placeOfIssue, notes) fall back to the defaults from company.json.
+Currency applies to the whole invoice, a currency given on a position must match it.
+*/
+type Spec struct {
+	Customer         string         `json:"customer" yaml:"customer"`
+	DateOfIssue      string         `json:"dateOfIssue" yaml:"dateOfIssue"`
+	PlaceOfIssue     string         `json:"placeOfIssue" yaml:"placeOfIssue"`
+	ServiceStartDate string         `json:"serviceStartDate" yaml:"serviceStartDate"`
+	ServiceEndDate   string         `json:"serviceEndDate" yaml:"serviceEndDate"`
+	Currency         string         `json:"currency" yaml:"currency"`
+	PaymentDeadline  string         `json:"paymentDeadline" yaml:"paymentDeadline"`
+	Positions        []PositionSpec `json:"positions" yaml:"positions"`
+	Notes            []string       `json:"notes" yaml:"notes"`
+}
+
+/*
+CorrectionSpec describes the state of an invoice after correction: positions
+replace the positions of the corrected invoice, an empty list cancels it.
+*/
+type CorrectionSpec struct {
+	DateOfIssue string         `json:"dateOfIssue" yaml:"dateOfIssue"`
+	Reason      string         `json:"reason" yaml:"reason"`
+	Positions   []PositionSpec `json:"positions" yaml:"positions"`
+}
+
+type ValidationError struct {
+	Path     string
+	Problems []string
+}
+
+func (e *ValidationError) Error() string {
+	return fmt.Sprintf("invalid input spec %s:\n  - %s", e.Path, strings.Join(e.Problems, "\n  - "))
+}
+
+func Load(path string) (Spec, error) {
+	var spec Spec
+
+	if err := decode(path, &spec); err != nil {
+		return spec, err
+	}
+
+	if problems := spec.Validate(); len(problems) > 0 {
+		return spec, &ValidationError{Path: path, Problems: problems}
+	}
+
+	return spec, nil
+}
+
+func LoadCorrection(path string) (CorrectionSpec, error) {
+	var spec CorrectionSpec
+
+	if err := decode(path, &spec); err != nil {
+		return spec, err
+	}
+
+	if problems := spec.Validate(); len(problems) > 0 {
+		return spec, &ValidationError{Path: path, Problems: problems}
+	}
+
+	return spec, nil
+}
+
+func decode(path string, target interface{}) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, target)
+	default:
+		decoder := json.NewDecoder(bytes.NewReader(data))
+		decoder.DisallowUnknownFields()
+		err = decoder.Decode(target)
+	}
+
+	if err != nil {
+		return fmt.Errorf("cannot parse %s: %w", path, err)
+	}
+
+	return nil
+}
+
+/* InvoiceCurrency is the invoice currency, older specs only set it on the positions */
+func (spec Spec) InvoiceCurrency() string {
+	if strings.TrimSpace(spec.Currency) != "" {
+		return spec.Currency
+	}
+
+	for _, position := range spec.Positions {
+		if strings.TrimSpace(position.Currency) != "" {
+			return position.Currency
+		}
+	}
+
+	return ""
+}
+
+func (spec Spec) Validate() []string {
+	var problems []string
+
+	if strings.TrimSpace(spec.Customer) == "" {
+		problems = append(problems, "customer is required")
+	}
+
+	problems = append(problems, validateDate("dateOfIssue", spec.DateOfIssue, true)...)
+	problems = append(problems, validateDate("serviceStartDate", spec.ServiceStartDate, true)...)
+	problems = append(problems, validateDate("serviceEndDate", spec.ServiceEndDate, true)...)
+	problems = append(problems, validateDate("paymentDeadline", spec.PaymentDeadline, false)...)
+
+	if len(spec.Positions) == 0 {
+		problems = append(problems, "positions must contain at least one position")
+	}
+
+	problems = append(problems, validatePositions(spec.Positions)...)
+	problems = append(problems, validateCurrency(spec.InvoiceCurrency(), spec.Positions)...)
+
+	return problems
+}
+
+func (spec CorrectionSpec) Validate() []string {
+	var problems []string
+
+	problems = append(problems, validateDate("dateOfIssue", spec.DateOfIssue, true)...)
+
+	if strings.TrimSpace(spec.Reason) == "" {
+		problems = append(problems, "reason is required")
+	}
+
+	problems = append(problems, validatePositions(spec.Positions)...)
+
+	return problems
+}
+
+func validatePositions(positions []PositionSpec) []string {
+	var problems []string
+
+	for i, position := range positions {
+		field := fmt.Sprintf("positions[%d]", i)
+
+		if strings.TrimSpace(position.Product) == "" {
+			problems = append(problems, field+".product is required")
+		}
+
+		if position.NetPrice == nil {
+			problems = append(problems, field+".netPrice is required")
+		} else if netPrice, err := position.NetPrice.Money(""); err != nil {
+			problems = append(problems, fmt.Sprintf("%s.netPrice %q must be an amount with at most 2 decimal places", field, string(*position.NetPrice)))
+		} else if netPrice.Amount < 0 {
+			problems = append(problems, field+".netPrice must not be negative")
+		}
+
+		if position.Quantity == nil {
+			problems = append(problems, field+".quantity is required")
+		} else if *position.Quantity <= 0 {
+			problems = append(problems, field+".quantity must be greater than 0")
+		}
+	}
+
+	return problems
+}
+
+func validateCurrency(currency string, positions []PositionSpec) []string {
+	if currency == "" {
+		return nil
+	}
+
+	if !currencyRegexp.MatchString(currency) {
+		return []string{fmt.Sprintf("currency %q is not a three letter ISO 4217 code", currency)}
+	}
+
+	var problems []string
+	for i, position := range positions {
+		if position.Currency != "" && !strings.EqualFold(position.Currency, currency) {
+			problems = append(problems, fmt.Sprintf("positions[%d].currency %s differs from the invoice currency %s, all positions must be in one currency", i, position.Currency, currency))
+		}
+	}
+
+	return problems
+}
+
+func validateDate(field string, value string, required bool) []string {
+	if value == "" {
+		if required {
+			return []string{field + " is required (DD-MM-YYYY)"}
+		}
+		return nil
+	}
+
+	if _, err := TimeUtils.ParseDdMmYyyy(value); err != nil {
+		return []string{fmt.Sprintf("%s %q is not a valid DD-MM-YYYY date", field, value)}
+	}
+
+	return nil
+}
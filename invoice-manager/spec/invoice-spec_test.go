@@ -0,0 +1,96 @@
+package InvoiceSpec_test
+
+import (
+	"errors"
+	InvoiceSpec "moneybringer/invoice-manager/spec"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeSpec(t *testing.T, name string, content string) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	return path
+}
+
+func jsonSpec(netPrice string) string {
+	return `{"customer": "SomeCompany", "dateOfIssue": "09-10-2026", "serviceStartDate": "10-09-2026", "serviceEndDate": "09-10-2026",
+		"positions": [{"product": "Consulting", "netPrice": ` + netPrice + `, "quantity": 1}]}`
+}
+
+func yamlSpec(netPrice string) string {
+	return "customer: SomeCompany\ndateOfIssue: 09-10-2026\nserviceStartDate: 10-09-2026\nserviceEndDate: 09-10-2026\n" +
+		"positions:\n  - product: Consulting\n    netPrice: " + netPrice + "\n    quantity: 1\n"
+}
+
+func TestNetPriceIsParsedExactly(t *testing.T) {
+	cases := []struct {
+		name     string
+		content  string
+		expected int64
+	}{
+		{"spec.json", jsonSpec("19.99"), 1999},
+		{"spec.json", jsonSpec("1234567.1"), 123456710},
+		{"spec.json", jsonSpec(`"12,50"`), 1250},
+		{"spec.json", jsonSpec("50"), 5000},
+		{"spec.yaml", yamlSpec("19.99"), 1999},
+		{"spec.yaml", yamlSpec(`"0.10"`), 10},
+	}
+
+	for _, c := range cases {
+		spec, err := InvoiceSpec.Load(writeSpec(t, c.name, c.content))
+		if err != nil {
+			t.Errorf("%s: %v", c.content, err)
+			continue
+		}
+
+		netPrice, err := spec.Positions[0].NetPrice.Money("PLN")
+		if err != nil || netPrice.Amount != c.expected {
+			t.Errorf("net price of %s = %d (%v), want %d", *spec.Positions[0].NetPrice, netPrice.Amount, err, c.expected)
+		}
+	}
+}
+
+func TestNetPriceWithMoreThanTwoDecimalsIsRejected(t *testing.T) {
+	for _, path := range []string{
+		writeSpec(t, "spec.json", jsonSpec("10.005")),
+		writeSpec(t, "spec.json", jsonSpec(`"10.005"`)),
+		writeSpec(t, "spec.json", jsonSpec("1e3")),
+		writeSpec(t, "spec.yaml", yamlSpec("10.005")),
+	} {
+		_, err := InvoiceSpec.Load(path)
+
+		var validationErr *InvoiceSpec.ValidationError
+		if !errors.As(err, &validationErr) {
+			t.Errorf("%s: got %v, want a validation error", path, err)
+			continue
+		}
+		if !strings.Contains(validationErr.Error(), "positions[0].netPrice") {
+			t.Errorf("problems do not name the net price: %v", validationErr)
+		}
+	}
+}
+
+func TestNetPriceMustBeNumberOrString(t *testing.T) {
+	if _, err := InvoiceSpec.Load(writeSpec(t, "spec.json", jsonSpec("true"))); err == nil {
+		t.Error("a boolean net price was accepted")
+	}
+}
+
+/* the legal basis may come from defaultExemptionBasis in company.json, it is checked on the created invoice */
+func TestExemptPositionMayLeaveExemptionBasisToConfig(t *testing.T) {
+	spec, err := InvoiceSpec.Load(writeSpec(t, "spec.yaml", yamlSpec("100")+"    taxRate: zw\n"))
+	if err != nil {
+		t.Fatalf("zw position without exemptionBasis: %v", err)
+	}
+	if basis := spec.Positions[0].ExemptionBasis; basis != "" {
+		t.Errorf("exemption basis = %q, want it left to the configured default", basis)
+	}
+}
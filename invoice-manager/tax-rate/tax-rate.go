@@ -0,0 +1,129 @@
+package TaxRate
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+/*
+TaxRate holds either a percentage ("23", "8", "5", "0") or one of the codes
+used on Polish invoices for positions without a VAT amount:
+zw - exempt, np - not subject to VAT in Poland, oo - reverse charge.
+*/
+type TaxRate string
+
+const EXEMPT TaxRate = "zw"
+const NOT_SUBJECT TaxRate = "np"
+const REVERSE_CHARGE TaxRate = "oo"
+
+func FromPercent(percent int) TaxRate {
+	return TaxRate(strconv.Itoa(percent))
+}
+
+func Parse(value string) (TaxRate, error) {
+	normalized := strings.ToLower(strings.TrimSpace(value))
+	normalized = strings.TrimSuffix(normalized, "%")
+
+	switch TaxRate(normalized) {
+	case EXEMPT, NOT_SUBJECT, REVERSE_CHARGE:
+		return TaxRate(normalized), nil
+	}
+
+	percent, err := strconv.ParseFloat(normalized, 64)
+	if err != nil || percent < 0 || percent > 100 || percent != float64(int(percent)) {
+		return "", fmt.Errorf("invalid tax rate %q, expected a whole percentage or one of zw, np, oo", value)
+	}
+
+	return FromPercent(int(percent)), nil
+}
+
+func (r TaxRate) IsNumeric() bool {
+	_, err := strconv.Atoi(string(r))
+	return err == nil
+}
+
+/* Percent returns 0 for zw, np and oo, no VAT is charged on those positions */
+func (r TaxRate) Percent() int {
+	percent, err := strconv.Atoi(string(r))
+	if err != nil {
+		return 0
+	}
+
+	return percent
+}
+
+func (r TaxRate) Label() string {
+	if r.IsNumeric() {
+		return string(r) + "%"
+	}
+
+	return string(r)
+}
+
+func (r TaxRate) Description() string {
+	switch r {
+	case EXEMPT:
+		return "Zwolnione z VAT (VAT exempt)"
+	case NOT_SUBJECT:
+		return "Nie podlega opodatkowaniu (not subject to VAT)"
+	case REVERSE_CHARGE:
+		return "Odwrotne obciążenie (reverse charge)"
+	}
+
+	return r.Label()
+}
+
+/* Less orders rates the way VAT tables list them: 23%, 8%, 5%, 0%, zw, np, oo */
+func Less(a TaxRate, b TaxRate) bool {
+	return a.sortKey() < b.sortKey()
+}
+
+func (r TaxRate) sortKey() int {
+	switch r {
+	case EXEMPT:
+		return 1
+	case NOT_SUBJECT:
+		return 2
+	case REVERSE_CHARGE:
+		return 3
+	}
+
+	return -r.Percent()
+}
+
+/* UnmarshalJSON accepts both plain numbers (older configs and raw invoices) and strings */
+func (r *TaxRate) UnmarshalJSON(data []byte) error {
+	var value string
+	if err := json.Unmarshal(data, &value); err != nil {
+		var number float64
+		if numberErr := json.Unmarshal(data, &number); numberErr != nil {
+			return fmt.Errorf("invalid tax rate %s", string(data))
+		}
+		value = strconv.FormatFloat(number, 'f', -1, 64)
+	}
+
+	parsed, err := Parse(value)
+	if err != nil {
+		return err
+	}
+
+	*r = parsed
+	return nil
+}
+
+func (r *TaxRate) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var value string
+	if err := unmarshal(&value); err != nil {
+		return err
+	}
+
+	parsed, err := Parse(value)
+	if err != nil {
+		return err
+	}
+
+	*r = parsed
+	return nil
+}
@@ -0,0 +1,182 @@
+package InvoiceStore
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	InvoiceManager "moneybringer/invoice-manager"
+	CompanyData "moneybringer/invoice-manager/company"
+	AppErrors "moneybringer/utils/app-errors"
+	AppPaths "moneybringer/utils/app-paths"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+/* FileRepository keeps every invoice as a raw JSON file in invoices/<year>/<month>/raw, next to its exports */
+type FileRepository struct{}
+
+func NewFileRepository() *FileRepository {
+	return &FileRepository{}
+}
+
+func (r *FileRepository) Save(invoice InvoiceManager.InvoiceCreatedData) (StoredInvoice, error) {
+	stored := StoredInvoice{Invoice: invoice, filesBase: filesBaseOf(invoice)}
+	stored.Path = stored.FilePath("json")
+
+	_, err := r.Get(invoice.InvoiceNo, invoice.SellerProfile())
+	if err == nil {
+		return stored, fmt.Errorf("%w: %s", ErrInvoiceExists, invoice.InvoiceNo)
+	}
+	if !errors.Is(err, ErrInvoiceNotFound) {
+		return stored, err
+	}
+
+	if err := stored.makeFileDirs(); err != nil {
+		return stored, err
+	}
+
+	return stored, write(stored)
+}
+
+/* Get only reads the raw files named after invoiceNo, see fileBaseName */
+func (r *FileRepository) Get(invoiceNo string, profile string) (StoredInvoice, error) {
+	storedInvoices, err := loadRaw(rawFileNamesOf(invoiceNo, profile))
+	if err != nil {
+		return StoredInvoice{}, err
+	}
+
+	selected, err := selectInvoices(storedInvoices, Filter{Profile: profile})
+	if err != nil {
+		return StoredInvoice{}, err
+	}
+
+	return findOne(selected, invoiceNo)
+}
+
+/* List reads every raw invoice JSON stored under invoices/<year>/<month>/raw */
+func (r *FileRepository) List(filter Filter) ([]StoredInvoice, error) {
+	storedInvoices, err := loadRaw("*.json")
+	if err != nil {
+		return nil, err
+	}
+
+	return selectInvoices(storedInvoices, filter)
+}
+
+func (r *FileRepository) UpdateStatus(stored *StoredInvoice, status Status) error {
+	if err := applyStatus(&stored.Invoice, status); err != nil {
+		return err
+	}
+
+	return write(*stored)
+}
+
+func (r *FileRepository) Close() error {
+	return nil
+}
+
+/* Load reads one raw invoice JSON file */
+func Load(path string) (InvoiceManager.InvoiceCreatedData, error) {
+	var invoice InvoiceManager.InvoiceCreatedData
+
+	jsonData, err := os.ReadFile(path)
+	if err != nil {
+		return invoice, fmt.Errorf("%w: %w", AppErrors.ErrStorage, err)
+	}
+
+	if err := json.Unmarshal(jsonData, &invoice); err != nil {
+		return invoice, fmt.Errorf("%w: cannot parse raw invoice %s: %w", AppErrors.ErrStorage, path, err)
+	}
+
+	return invoice, nil
+}
+
+/* rawInvoicePaths finds the raw invoice JSON files matching the file name pattern */
+func rawInvoicePaths(pattern string) ([]string, error) {
+	paths, err := filepath.Glob(filepath.Join(AppPaths.InvoicesDir(), "*", "*", "raw", pattern))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", AppErrors.ErrStorage, err)
+	}
+
+	return paths, nil
+}
+
+func loadRaw(pattern string) ([]StoredInvoice, error) {
+	paths, err := rawInvoicePaths(pattern)
+	if err != nil {
+		return nil, err
+	}
+
+	var storedInvoices []StoredInvoice
+	for _, path := range paths {
+		invoice, err := Load(path)
+		if err != nil {
+			return nil, err
+		}
+
+		storedInvoices = append(storedInvoices, StoredInvoice{Path: path, Invoice: invoice, filesBase: filesBaseOfPath(path)})
+	}
+
+	return storedInvoices, nil
+}
+
+func filesBaseOfPath(path string) string {
+	filesBase, _ := filepath.Rel(AppPaths.InvoicesDir(), strings.TrimSuffix(path, ".json"))
+
+	return filesBase
+}
+
+/*
+rawFileNamesOf matches the raw files an invoice number may be stored in, other numbers sharing
+the prefix are dropped by the caller. A number with glob characters matches every file.
+*/
+func rawFileNamesOf(invoiceNo string, profile string) string {
+	if strings.ContainsAny(invoiceNo, `*?[\`) {
+		return "*.json"
+	}
+
+	baseName := strings.ReplaceAll(invoiceNo, "/", "_") + "_*.json"
+
+	switch profile {
+	case "":
+		return "*" + baseName
+	case CompanyData.DEFAULT_PROFILE:
+		return baseName
+	}
+
+	return profile + "_" + baseName
+}
+
+/* write replaces the raw JSON in one rename, a crash leaves either the old or the new invoice */
+func write(stored StoredInvoice) error {
+	jsonData, err := json.MarshalIndent(stored.Invoice, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	file, err := os.CreateTemp(filepath.Dir(stored.Path), filepath.Base(stored.Path)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("%w: %w", AppErrors.ErrStorage, err)
+	}
+	defer os.Remove(file.Name())
+
+	_, err = file.Write(jsonData)
+	if err == nil {
+		err = file.Sync()
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Chmod(file.Name(), 0644)
+	}
+	if err == nil {
+		err = os.Rename(file.Name(), stored.Path)
+	}
+	if err != nil {
+		return fmt.Errorf("%w: %w", AppErrors.ErrStorage, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,185 @@
+package InvoiceStore
+
+import (
+	"errors"
+	"fmt"
+	InvoiceManager "moneybringer/invoice-manager"
+	CompanyData "moneybringer/invoice-manager/company"
+	AppErrors "moneybringer/utils/app-errors"
+	AppPaths "moneybringer/utils/app-paths"
+	TimeUtils "moneybringer/utils/time"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+/*
+InvoiceRepository stores issued invoices. The document itself never changes once it is
+saved, later events (sending to KSeF, converting a proforma, settling an advance) are
+recorded with UpdateStatus. FileRepository keeps the raw JSON files of the invoices
+directory, SqliteRepository keeps them in one database file; storage.json selects one.
+*/
+type InvoiceRepository interface {
+	/* Save stores a newly issued invoice, a number the seller profile already stored is refused with ErrInvoiceExists */
+	Save(invoice InvoiceManager.InvoiceCreatedData) (StoredInvoice, error)
+	/* Get finds the invoice by number among invoices of profile, an empty profile searches every profile */
+	Get(invoiceNo string, profile string) (StoredInvoice, error)
+	/* List returns the invoices matching filter, ordered by date of issue and number */
+	List(filter Filter) ([]StoredInvoice, error)
+	/* UpdateStatus applies status to the stored invoice and persists it */
+	UpdateStatus(stored *StoredInvoice, status Status) error
+	Close() error
+}
+
+type StoredInvoice struct {
+	/* Path is the raw invoice JSON, or the database file holding the invoice */
+	Path    string
+	Invoice InvoiceManager.InvoiceCreatedData
+	/* filesBase names the files kept with the invoice relative to the invoices directory, e.g. 2026/October/raw/1_10_2026_John_Doe */
+	filesBase string
+}
+
+/* Filter selects stored invoices, zero fields select everything */
+type Filter struct {
+	/* Year and Month select invoices issued in that month */
+	Year    int
+	Month   time.Month
+	Profile string
+	/* Customer is a part of the buyer name, letter case is ignored */
+	Customer string
+	/* CorrectedInvoiceNo selects the correction invoices issued for that invoice */
+	CorrectedInvoiceNo string
+}
+
+/* Status holds what may change after an invoice is issued, empty fields are left as they are */
+type Status struct {
+	KsefReferenceNumber  string
+	ConvertedToInvoiceNo string
+	SettledByInvoiceNo   string
+}
+
+var ErrInvoiceNotFound = errors.New("invoice not found")
+var ErrAmbiguousInvoice = errors.New("invoice number was issued by several seller profiles, choose one with --profile")
+var ErrInvoiceExists = errors.New("invoice number is already stored")
+
+/* FilePath returns a file kept next to the raw JSON, e.g. the FA(2) XML or the UPO */
+func (s StoredInvoice) FilePath(extension string) string {
+	return filepath.Join(AppPaths.InvoicesDir(), s.filesBase) + "." + extension
+}
+
+/* PdfPath returns the PDF location in the month folder, one level above raw */
+func (s StoredInvoice) PdfPath() string {
+	monthDirPath := filepath.Dir(filepath.Dir(s.filesBase))
+	return filepath.Join(AppPaths.InvoicesDir(), monthDirPath, filepath.Base(s.filesBase)+".pdf")
+}
+
+/* makeFileDirs creates the raw folder of the month, the exports and the PDF are written there and one level above */
+func (s StoredInvoice) makeFileDirs() error {
+	if err := os.MkdirAll(filepath.Dir(s.FilePath("json")), os.ModePerm); err != nil {
+		return fmt.Errorf("%w: %w", AppErrors.ErrStorage, err)
+	}
+
+	return nil
+}
+
+/* filesBaseOf places the files of a freshly issued invoice in the raw folder of the current month */
+func filesBaseOf(invoice InvoiceManager.InvoiceCreatedData) string {
+	currentTime := TimeUtils.GetCurrentTime()
+
+	return filepath.Join(strconv.Itoa(currentTime.Year()), currentTime.Month().String(), "raw", fileBaseName(invoice))
+}
+
+/* Profiles may use the same numbering pattern, files of other than the default profile are prefixed with its key */
+func fileBaseName(invoice InvoiceManager.InvoiceCreatedData) string {
+	invoiceNo := strings.ReplaceAll(invoice.InvoiceNo, "/", "_")
+	baseName := fmt.Sprintf("%s_%s_%s", invoiceNo, invoice.AuthorFirstName, invoice.AuthorLastName)
+
+	if profile := invoice.SellerProfile(); profile != CompanyData.DEFAULT_PROFILE {
+		return profile + "_" + baseName
+	}
+
+	return baseName
+}
+
+func applyStatus(invoice *InvoiceManager.InvoiceCreatedData, status Status) error {
+	if status.SettledByInvoiceNo != "" {
+		if invoice.Advance == nil {
+			return fmt.Errorf("invoice %s is not an advance invoice, it cannot be settled", invoice.InvoiceNo)
+		}
+		invoice.Advance.SettledByInvoiceNo = status.SettledByInvoiceNo
+	}
+	if status.KsefReferenceNumber != "" {
+		invoice.KsefReferenceNumber = status.KsefReferenceNumber
+	}
+	if status.ConvertedToInvoiceNo != "" {
+		invoice.ConvertedToInvoiceNo = status.ConvertedToInvoiceNo
+	}
+
+	return nil
+}
+
+func (f Filter) matches(invoice InvoiceManager.InvoiceCreatedData, dateOfIssue time.Time) bool {
+	if f.Year != 0 && (dateOfIssue.Year() != f.Year || dateOfIssue.Month() != f.Month) {
+		return false
+	}
+	if f.Profile != "" && invoice.SellerProfile() != f.Profile {
+		return false
+	}
+	if f.Customer != "" && !strings.Contains(strings.ToLower(invoice.InvoiceTo.FullName), strings.ToLower(f.Customer)) {
+		return false
+	}
+	if f.CorrectedInvoiceNo != "" && (invoice.Correction == nil || invoice.Correction.OriginalInvoiceNo != f.CorrectedInvoiceNo) {
+		return false
+	}
+
+	return true
+}
+
+/* selectInvoices keeps the invoices matching filter ordered by date of issue, invoices of one day by file name */
+func selectInvoices(storedInvoices []StoredInvoice, filter Filter) ([]StoredInvoice, error) {
+	selected := []StoredInvoice{}
+	dates := map[string]time.Time{}
+
+	for _, stored := range storedInvoices {
+		dateOfIssue, err := TimeUtils.ParseDdMmYyyy(stored.Invoice.DateOfIssue)
+		if err != nil {
+			return nil, fmt.Errorf("invoice %s has invalid date of issue %q", stored.Invoice.InvoiceNo, stored.Invoice.DateOfIssue)
+		}
+
+		if filter.matches(stored.Invoice, dateOfIssue) {
+			selected = append(selected, stored)
+			dates[stored.filesBase] = dateOfIssue
+		}
+	}
+
+	sort.SliceStable(selected, func(i, j int) bool {
+		if !dates[selected[i].filesBase].Equal(dates[selected[j].filesBase]) {
+			return dates[selected[i].filesBase].Before(dates[selected[j].filesBase])
+		}
+		return selected[i].filesBase < selected[j].filesBase
+	})
+
+	return selected, nil
+}
+
+/* findOne picks the invoice with the number, there may be one per seller profile */
+func findOne(storedInvoices []StoredInvoice, invoiceNo string) (StoredInvoice, error) {
+	var found []StoredInvoice
+	for _, stored := range storedInvoices {
+		if stored.Invoice.InvoiceNo == invoiceNo {
+			found = append(found, stored)
+		}
+	}
+
+	switch len(found) {
+	case 0:
+		return StoredInvoice{}, fmt.Errorf("%w: %s", ErrInvoiceNotFound, invoiceNo)
+	case 1:
+		return found[0], nil
+	}
+
+	return StoredInvoice{}, fmt.Errorf("%w: %s", ErrAmbiguousInvoice, invoiceNo)
+}
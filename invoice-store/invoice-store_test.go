@@ -0,0 +1,297 @@
+package InvoiceStore_test
+
+import (
+	"errors"
+	InvoiceManager "moneybringer/invoice-manager"
+	InvoiceStore "moneybringer/invoice-store"
+	AppPaths "moneybringer/utils/app-paths"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+/* useTempDirs keeps config and invoices in temporary directories, storage.json selects the backend */
+func useTempDirs(t *testing.T, storageConfig string) {
+	t.Helper()
+
+	configDir := t.TempDir()
+	if storageConfig != "" {
+		if err := os.WriteFile(filepath.Join(configDir, InvoiceStore.STORAGE_CONFIG_JSON_FILE), []byte(storageConfig), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	AppPaths.SetConfigDir(configDir)
+	AppPaths.SetInvoicesDir(t.TempDir())
+	t.Cleanup(func() {
+		AppPaths.SetConfigDir("")
+		AppPaths.SetInvoicesDir("")
+	})
+}
+
+type backend struct {
+	name string
+	open func(t *testing.T) InvoiceStore.InvoiceRepository
+}
+
+func backends() []backend {
+	return []backend{
+		{name: "files", open: func(t *testing.T) InvoiceStore.InvoiceRepository {
+			useTempDirs(t, "")
+			return InvoiceStore.NewFileRepository()
+		}},
+		{name: "sqlite", open: func(t *testing.T) InvoiceStore.InvoiceRepository {
+			useTempDirs(t, `{"backend": "sqlite"}`)
+			repository, err := InvoiceStore.Open()
+			if err != nil {
+				t.Fatal(err)
+			}
+			t.Cleanup(func() { repository.Close() })
+			return repository
+		}},
+	}
+}
+
+func invoice(number string, profile string, dateOfIssue string, customer string) InvoiceManager.InvoiceCreatedData {
+	created := InvoiceManager.InvoiceCreatedData{InvoiceNo: number, Profile: profile, DateOfIssue: dateOfIssue, AuthorFirstName: "John", AuthorLastName: "Doe"}
+	created.InvoiceTo.FullName = customer
+
+	return created
+}
+
+func correction(number string, dateOfIssue string, correctedNo string) InvoiceManager.InvoiceCreatedData {
+	created := invoice(number, "", dateOfIssue, "Some Company Inc")
+	created.DocumentKind = InvoiceManager.DOCUMENT_KIND_CORRECTION
+	created.Correction = &InvoiceManager.InvoiceCorrection{OriginalInvoiceNo: correctedNo}
+
+	return created
+}
+
+func save(t *testing.T, repository InvoiceStore.InvoiceRepository, invoices ...InvoiceManager.InvoiceCreatedData) {
+	t.Helper()
+
+	for _, created := range invoices {
+		if _, err := repository.Save(created); err != nil {
+			t.Fatalf("Save(%s): %v", created.InvoiceNo, err)
+		}
+	}
+}
+
+func numbers(storedInvoices []InvoiceStore.StoredInvoice) string {
+	var invoiceNumbers []string
+	for _, stored := range storedInvoices {
+		invoiceNumbers = append(invoiceNumbers, stored.Invoice.SellerProfile()+":"+stored.Invoice.InvoiceNo)
+	}
+
+	return strings.Join(invoiceNumbers, " ")
+}
+
+func storeMonth(t *testing.T, repository InvoiceStore.InvoiceRepository) {
+	t.Helper()
+
+	save(t, repository,
+		invoice("2/10/2026", "", "12-10-2026", "Other Company"),
+		invoice("1/10/2026", "", "09-10-2026", "Some Company Inc"),
+		invoice("11/10/2026", "", "30-10-2026", "Some Company Inc"),
+		invoice("1/10/2026", "acme", "10-10-2026", "Some Company Inc"),
+		invoice("7/09/2026", "", "30-09-2026", "Some Company Inc"),
+		correction("KOR/1/10/2026", "20-10-2026", "1/10/2026"),
+	)
+}
+
+func TestRepositoriesSaveAndFind(t *testing.T) {
+	for _, backend := range backends() {
+		t.Run(backend.name, func(t *testing.T) {
+			repository := backend.open(t)
+			storeMonth(t, repository)
+
+			if _, err := repository.Save(invoice("1/10/2026", "", "09-10-2026", "Somebody Else")); !errors.Is(err, InvoiceStore.ErrInvoiceExists) {
+				t.Errorf("Save of a stored number = %v, want ErrInvoiceExists", err)
+			}
+			if _, err := repository.Save(invoice("1/10/2026", "default", "09-10-2026", "Somebody Else")); !errors.Is(err, InvoiceStore.ErrInvoiceExists) {
+				t.Errorf("Save of a stored number with the default profile named = %v, want ErrInvoiceExists", err)
+			}
+
+			found := map[string]string{
+				"default": "09-10-2026",
+				"acme":    "10-10-2026",
+			}
+			for profile, dateOfIssue := range found {
+				stored, err := repository.Get("1/10/2026", profile)
+				if err != nil {
+					t.Errorf("Get(1/10/2026, %s): %v", profile, err)
+					continue
+				}
+				if stored.Invoice.DateOfIssue != dateOfIssue || stored.Invoice.SellerProfile() != profile {
+					t.Errorf("Get(1/10/2026, %s) = %s of %s", profile, stored.Invoice.DateOfIssue, stored.Invoice.SellerProfile())
+				}
+			}
+
+			if stored, err := repository.Get("11/10/2026", ""); err != nil || stored.Invoice.DateOfIssue != "30-10-2026" {
+				t.Errorf("Get(11/10/2026) = %s, %v", stored.Invoice.DateOfIssue, err)
+			}
+			if _, err := repository.Get("1/10/2026", ""); !errors.Is(err, InvoiceStore.ErrAmbiguousInvoice) {
+				t.Errorf("Get(1/10/2026) of every profile = %v, want ErrAmbiguousInvoice", err)
+			}
+			if _, err := repository.Get("10/2026", ""); !errors.Is(err, InvoiceStore.ErrInvoiceNotFound) {
+				t.Errorf("Get(10/2026) = %v, want ErrInvoiceNotFound", err)
+			}
+			if _, err := repository.Get("2/10/2026", "acme"); !errors.Is(err, InvoiceStore.ErrInvoiceNotFound) {
+				t.Errorf("Get(2/10/2026, acme) = %v, want ErrInvoiceNotFound", err)
+			}
+		})
+	}
+}
+
+func TestRepositoriesList(t *testing.T) {
+	cases := []struct {
+		name     string
+		filter   InvoiceStore.Filter
+		expected string
+	}{
+		{"everything", InvoiceStore.Filter{}, "default:7/09/2026 default:1/10/2026 acme:1/10/2026 default:2/10/2026 default:KOR/1/10/2026 default:11/10/2026"},
+		{"month", InvoiceStore.Filter{Year: 2026, Month: time.September}, "default:7/09/2026"},
+		{"profile", InvoiceStore.Filter{Profile: "acme"}, "acme:1/10/2026"},
+		{"customer", InvoiceStore.Filter{Year: 2026, Month: time.October, Customer: "OTHER"}, "default:2/10/2026"},
+		{"corrections", InvoiceStore.Filter{CorrectedInvoiceNo: "1/10/2026"}, "default:KOR/1/10/2026"},
+		{"nothing", InvoiceStore.Filter{Year: 2026, Month: time.November}, ""},
+	}
+
+	for _, backend := range backends() {
+		t.Run(backend.name, func(t *testing.T) {
+			repository := backend.open(t)
+			storeMonth(t, repository)
+
+			for _, c := range cases {
+				storedInvoices, err := repository.List(c.filter)
+				if err != nil {
+					t.Errorf("%s: %v", c.name, err)
+					continue
+				}
+				if got := numbers(storedInvoices); got != c.expected {
+					t.Errorf("%s = %q, want %q", c.name, got, c.expected)
+				}
+			}
+		})
+	}
+}
+
+func TestRepositoriesUpdateStatus(t *testing.T) {
+	for _, backend := range backends() {
+		t.Run(backend.name, func(t *testing.T) {
+			repository := backend.open(t)
+			storeMonth(t, repository)
+
+			stored, err := repository.Get("1/10/2026", "acme")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := repository.UpdateStatus(&stored, InvoiceStore.Status{KsefReferenceNumber: "20261009-SE-ABC"}); err != nil {
+				t.Fatal(err)
+			}
+
+			reloaded, err := repository.Get("1/10/2026", "acme")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if reloaded.Invoice.KsefReferenceNumber != "20261009-SE-ABC" || reloaded.PdfPath() != stored.PdfPath() {
+				t.Errorf("reloaded %q at %s, want the KSeF number at %s", reloaded.Invoice.KsefReferenceNumber, reloaded.PdfPath(), stored.PdfPath())
+			}
+			if other, _ := repository.Get("1/10/2026", "default"); other.Invoice.KsefReferenceNumber != "" {
+				t.Errorf("the invoice of the default profile got the KSeF number too")
+			}
+
+			if err := repository.UpdateStatus(&stored, InvoiceStore.Status{SettledByInvoiceNo: "5/10/2026"}); err == nil {
+				t.Errorf("settling an invoice that is not an advance succeeded")
+			}
+		})
+	}
+}
+
+func TestFileRepositoryLeavesNoTemporaryFiles(t *testing.T) {
+	useTempDirs(t, "")
+	repository := InvoiceStore.NewFileRepository()
+
+	stored, err := repository.Save(invoice("1/10/2026", "", "09-10-2026", "Some Company Inc"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := repository.UpdateStatus(&stored, InvoiceStore.Status{KsefReferenceNumber: "20261009-SE-ABC"}); err != nil {
+		t.Fatal(err)
+	}
+
+	entries, err := os.ReadDir(filepath.Dir(stored.Path))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || entries[0].Name() != "1_10_2026_John_Doe.json" {
+		var names []string
+		for _, entry := range entries {
+			names = append(names, entry.Name())
+		}
+		t.Errorf("raw folder holds %v, want only 1_10_2026_John_Doe.json", names)
+	}
+}
+
+func TestSqliteRefusesInvoicesStoredAsFilesUntilImported(t *testing.T) {
+	useTempDirs(t, "")
+	save(t, InvoiceStore.NewFileRepository(),
+		invoice("1/10/2026", "", "09-10-2026", "Some Company Inc"),
+		invoice("1/10/2026", "acme", "10-10-2026", "Some Company Inc"),
+	)
+	fileStored, err := InvoiceStore.NewFileRepository().Get("1/10/2026", "default")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	storagePath := InvoiceStore.StorageConfigJsonPath()
+	if err := os.WriteFile(storagePath, []byte(`{"backend": "sqlite"}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := InvoiceStore.Open(); !errors.Is(err, InvoiceStore.ErrFilesNotImported) {
+		t.Fatalf("Open with invoices stored as files = %v, want ErrFilesNotImported", err)
+	}
+
+	config, err := InvoiceStore.LoadConfig(storagePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	database, err := InvoiceStore.NewSqliteRepository(config.SqlitePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer database.Close()
+
+	for run, expected := range []int{2, 0} {
+		if imported, err := database.ImportFiles(); err != nil || imported != expected {
+			t.Errorf("import %d = %d, %v, want %d imported", run+1, imported, err, expected)
+		}
+	}
+
+	repository, err := InvoiceStore.Open()
+	if err != nil {
+		t.Fatalf("Open after the import: %v", err)
+	}
+	defer repository.Close()
+
+	stored, err := repository.Get("1/10/2026", "default")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored.PdfPath() != fileStored.PdfPath() || stored.FilePath("xml") != fileStored.FilePath("xml") {
+		t.Errorf("imported invoice keeps its files at %s, want %s", stored.PdfPath(), fileStored.PdfPath())
+	}
+
+	/* a raw file with a number the database stored for other files is not imported over it */
+	if _, err := repository.Save(invoice("2/10/2026", "", "12-10-2026", "Some Company Inc")); err != nil {
+		t.Fatal(err)
+	}
+	other := invoice("2/10/2026", "", "12-10-2026", "Other Company")
+	other.AuthorFirstName = "Jane"
+	save(t, InvoiceStore.NewFileRepository(), other)
+	if _, err := database.ImportFiles(); !errors.Is(err, InvoiceStore.ErrInvoiceExists) {
+		t.Errorf("import of a number stored in the database = %v, want ErrInvoiceExists", err)
+	}
+}
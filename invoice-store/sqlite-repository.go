@@ -0,0 +1,286 @@
+package InvoiceStore
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	InvoiceManager "moneybringer/invoice-manager"
+	AppErrors "moneybringer/utils/app-errors"
+	TimeUtils "moneybringer/utils/time"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	/* the SQLite C sources are compiled in with cgo, no SQLite library is needed at run time */
+	_ "github.com/mattn/go-sqlite3"
+)
+
+/*
+SqliteRepository keeps the invoices in one SQLite database: the raw invoice JSON as the
+document and next to it the columns invoices are looked up and filtered by. The exports
+and PDFs stay files in the invoices directory, laid out as with FileRepository.
+*/
+type SqliteRepository struct {
+	path string
+	db   *sql.DB
+}
+
+/* busyTimeoutMs lets a second process, e.g. serve next to the CLI, wait for a write to finish */
+const busyTimeoutMs = 5000
+
+const sqliteSchema = `
+CREATE TABLE IF NOT EXISTS invoices (
+	id                      INTEGER PRIMARY KEY,
+	profile                 TEXT NOT NULL,
+	invoice_no              TEXT NOT NULL,
+	document_kind           TEXT NOT NULL,
+	date_of_issue           TEXT NOT NULL,
+	customer                TEXT NOT NULL,
+	corrected_invoice_no    TEXT NOT NULL DEFAULT '',
+	ksef_reference_number   TEXT NOT NULL DEFAULT '',
+	converted_to_invoice_no TEXT NOT NULL DEFAULT '',
+	settled_by_invoice_no   TEXT NOT NULL DEFAULT '',
+	files_base              TEXT NOT NULL,
+	document                TEXT NOT NULL,
+	UNIQUE (profile, invoice_no)
+);
+CREATE INDEX IF NOT EXISTS invoices_date_of_issue ON invoices (date_of_issue);
+CREATE INDEX IF NOT EXISTS invoices_corrected_invoice_no ON invoices (corrected_invoice_no);
+`
+
+/* NewSqliteRepository opens the database at path, creating it and its tables on first use */
+func NewSqliteRepository(path string) (*SqliteRepository, error) {
+	if err := os.MkdirAll(filepath.Dir(path), os.ModePerm); err != nil {
+		return nil, fmt.Errorf("%w: %w", AppErrors.ErrStorage, err)
+	}
+
+	db, err := sql.Open("sqlite3", fmt.Sprintf("%s?_busy_timeout=%d", path, busyTimeoutMs))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", AppErrors.ErrStorage, err)
+	}
+
+	if _, err := db.Exec(sqliteSchema); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("%w: cannot open invoice database %s: %w", AppErrors.ErrStorage, path, err)
+	}
+
+	return &SqliteRepository{path: path, db: db}, nil
+}
+
+func (r *SqliteRepository) Save(invoice InvoiceManager.InvoiceCreatedData) (StoredInvoice, error) {
+	stored := StoredInvoice{Path: r.path, Invoice: invoice, filesBase: filesBaseOf(invoice)}
+
+	if err := r.insert(stored); err != nil {
+		return stored, err
+	}
+
+	return stored, stored.makeFileDirs()
+}
+
+/* insert adds the invoice with the place of its files, a number the profile already stored is refused */
+func (r *SqliteRepository) insert(stored StoredInvoice) error {
+	invoice := stored.Invoice
+
+	dateOfIssue, err := TimeUtils.ParseDdMmYyyy(invoice.DateOfIssue)
+	if err != nil {
+		return fmt.Errorf("invoice %s has invalid date of issue %q", invoice.InvoiceNo, invoice.DateOfIssue)
+	}
+
+	document, err := json.Marshal(invoice)
+	if err != nil {
+		return err
+	}
+
+	correctedInvoiceNo := ""
+	if invoice.Correction != nil {
+		correctedInvoiceNo = invoice.Correction.OriginalInvoiceNo
+	}
+	settledByInvoiceNo := ""
+	if invoice.Advance != nil {
+		settledByInvoiceNo = invoice.Advance.SettledByInvoiceNo
+	}
+
+	result, err := r.db.Exec(`INSERT INTO invoices (profile, invoice_no, document_kind, date_of_issue, customer, corrected_invoice_no,
+		ksef_reference_number, converted_to_invoice_no, settled_by_invoice_no, files_base, document)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT (profile, invoice_no) DO NOTHING`,
+		invoice.SellerProfile(), invoice.InvoiceNo, invoice.Kind(), dateOfIssue.Format(time.DateOnly), invoice.InvoiceTo.FullName, correctedInvoiceNo,
+		invoice.KsefReferenceNumber, invoice.ConvertedToInvoiceNo, settledByInvoiceNo, stored.filesBase, string(document))
+	if err != nil {
+		return fmt.Errorf("%w: %w", AppErrors.ErrStorage, err)
+	}
+
+	if inserted, _ := result.RowsAffected(); inserted == 0 {
+		return fmt.Errorf("%w: %s", ErrInvoiceExists, invoice.InvoiceNo)
+	}
+
+	return nil
+}
+
+/*
+ImportFiles copies the invoices stored as raw JSON files (the files backend) into the database
+and returns how many were added. Their exports and PDFs stay where they are and the raw files
+are left in place. Invoices imported before are skipped, so an interrupted import can be run again.
+*/
+func (r *SqliteRepository) ImportFiles() (int, error) {
+	storedInvoices, err := NewFileRepository().List(Filter{})
+	if err != nil {
+		return 0, err
+	}
+
+	imported := 0
+	for _, stored := range storedInvoices {
+		var filesBase string
+		err := r.db.QueryRow("SELECT files_base FROM invoices WHERE profile = ? AND invoice_no = ?",
+			stored.Invoice.SellerProfile(), stored.Invoice.InvoiceNo).Scan(&filesBase)
+
+		switch {
+		case err == nil && filesBase == stored.filesBase:
+			continue
+		case err == nil:
+			return imported, fmt.Errorf("%w: %s of profile %s is in %s and in %s", ErrInvoiceExists, stored.Invoice.InvoiceNo, stored.Invoice.SellerProfile(), r.path, stored.Path)
+		case err != sql.ErrNoRows:
+			return imported, fmt.Errorf("%w: %w", AppErrors.ErrStorage, err)
+		}
+
+		stored.Path = r.path
+		if err := r.insert(stored); err != nil {
+			return imported, err
+		}
+		imported++
+	}
+
+	return imported, nil
+}
+
+/* notImported lists the raw invoice files the database does not hold, see ImportFiles */
+func (r *SqliteRepository) notImported() ([]string, error) {
+	paths, err := rawInvoicePaths("*.json")
+	if err != nil {
+		return nil, err
+	}
+
+	var missing []string
+	for _, path := range paths {
+		var count int
+		if err := r.db.QueryRow("SELECT COUNT(*) FROM invoices WHERE files_base = ?", filesBaseOfPath(path)).Scan(&count); err != nil {
+			return nil, fmt.Errorf("%w: %w", AppErrors.ErrStorage, err)
+		}
+		if count == 0 {
+			missing = append(missing, path)
+		}
+	}
+
+	return missing, nil
+}
+
+func (r *SqliteRepository) Get(invoiceNo string, profile string) (StoredInvoice, error) {
+	where := []string{"invoice_no = ?"}
+	args := []interface{}{invoiceNo}
+	if profile != "" {
+		where = append(where, "profile = ?")
+		args = append(args, profile)
+	}
+
+	storedInvoices, err := r.query(where, args)
+	if err != nil {
+		return StoredInvoice{}, err
+	}
+
+	return findOne(storedInvoices, invoiceNo)
+}
+
+/* List narrows the rows with the indexed columns, the filter itself is applied as by FileRepository */
+func (r *SqliteRepository) List(filter Filter) ([]StoredInvoice, error) {
+	var where []string
+	var args []interface{}
+
+	if filter.Year != 0 {
+		firstDay := time.Date(filter.Year, filter.Month, 1, 0, 0, 0, 0, time.UTC)
+		where = append(where, "date_of_issue >= ? AND date_of_issue < ?")
+		args = append(args, firstDay.Format(time.DateOnly), firstDay.AddDate(0, 1, 0).Format(time.DateOnly))
+	}
+	if filter.Profile != "" {
+		where = append(where, "profile = ?")
+		args = append(args, filter.Profile)
+	}
+	if filter.CorrectedInvoiceNo != "" {
+		where = append(where, "corrected_invoice_no = ?")
+		args = append(args, filter.CorrectedInvoiceNo)
+	}
+
+	storedInvoices, err := r.query(where, args)
+	if err != nil {
+		return nil, err
+	}
+
+	return selectInvoices(storedInvoices, filter)
+}
+
+func (r *SqliteRepository) UpdateStatus(stored *StoredInvoice, status Status) error {
+	if err := applyStatus(&stored.Invoice, status); err != nil {
+		return err
+	}
+
+	invoice := stored.Invoice
+	document, err := json.Marshal(invoice)
+	if err != nil {
+		return err
+	}
+
+	settledByInvoiceNo := ""
+	if invoice.Advance != nil {
+		settledByInvoiceNo = invoice.Advance.SettledByInvoiceNo
+	}
+
+	result, err := r.db.Exec(`UPDATE invoices SET ksef_reference_number = ?, converted_to_invoice_no = ?, settled_by_invoice_no = ?, document = ?
+		WHERE profile = ? AND invoice_no = ?`,
+		invoice.KsefReferenceNumber, invoice.ConvertedToInvoiceNo, settledByInvoiceNo, string(document), invoice.SellerProfile(), invoice.InvoiceNo)
+	if err != nil {
+		return fmt.Errorf("%w: %w", AppErrors.ErrStorage, err)
+	}
+
+	if updated, _ := result.RowsAffected(); updated == 0 {
+		return fmt.Errorf("%w: %s", ErrInvoiceNotFound, invoice.InvoiceNo)
+	}
+
+	return nil
+}
+
+func (r *SqliteRepository) Close() error {
+	return r.db.Close()
+}
+
+func (r *SqliteRepository) query(where []string, args []interface{}) ([]StoredInvoice, error) {
+	query := "SELECT invoice_no, files_base, document FROM invoices"
+	if len(where) > 0 {
+		query += " WHERE " + strings.Join(where, " AND ")
+	}
+
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", AppErrors.ErrStorage, err)
+	}
+	defer rows.Close()
+
+	var storedInvoices []StoredInvoice
+	for rows.Next() {
+		var invoiceNo, filesBase, document string
+		if err := rows.Scan(&invoiceNo, &filesBase, &document); err != nil {
+			return nil, fmt.Errorf("%w: %w", AppErrors.ErrStorage, err)
+		}
+
+		stored := StoredInvoice{Path: r.path, filesBase: filesBase}
+		if err := json.Unmarshal([]byte(document), &stored.Invoice); err != nil {
+			return nil, fmt.Errorf("%w: cannot parse invoice %s in %s: %w", AppErrors.ErrStorage, invoiceNo, r.path, err)
+		}
+
+		storedInvoices = append(storedInvoices, stored)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%w: %w", AppErrors.ErrStorage, err)
+	}
+
+	return storedInvoices, nil
+}
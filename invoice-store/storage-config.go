@@ -0,0 +1,97 @@
+package InvoiceStore
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	AppErrors "moneybringer/utils/app-errors"
+	AppPaths "moneybringer/utils/app-paths"
+	"os"
+	"path/filepath"
+)
+
+/* Config is storage.json, an installation without it keeps invoices as files */
+type Config struct {
+	Backend string `json:"backend"`
+	/* SqlitePath is relative to the invoices directory, like numbering.json */
+	SqlitePath string `json:"sqlitePath"`
+}
+
+const STORAGE_CONFIG_JSON_FILE = "storage.json"
+
+const BACKEND_FILES = "files"
+const BACKEND_SQLITE = "sqlite"
+
+const DEFAULT_SQLITE_PATH = "invoices.db"
+
+func StorageConfigJsonPath() string {
+	return AppPaths.ConfigFile(STORAGE_CONFIG_JSON_FILE)
+}
+
+/* LoadConfig reads storage.json, a missing file selects the files backend */
+func LoadConfig(path string) (Config, error) {
+	config := Config{Backend: BACKEND_FILES}
+
+	jsonData, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return config, nil
+	}
+	if err != nil {
+		return config, fmt.Errorf("%w: %w", AppErrors.ErrConfigInvalid, err)
+	}
+
+	if err := json.Unmarshal(jsonData, &config); err != nil {
+		return config, fmt.Errorf("%w: %s: %w", AppErrors.ErrConfigInvalid, path, err)
+	}
+
+	switch config.Backend {
+	case "":
+		config.Backend = BACKEND_FILES
+	case BACKEND_FILES, BACKEND_SQLITE:
+	default:
+		return config, fmt.Errorf("%w: %s: backend must be %q or %q", AppErrors.ErrConfigInvalid, path, BACKEND_FILES, BACKEND_SQLITE)
+	}
+
+	if config.SqlitePath == "" {
+		config.SqlitePath = DEFAULT_SQLITE_PATH
+	}
+	if !filepath.IsAbs(config.SqlitePath) {
+		config.SqlitePath = filepath.Join(AppPaths.InvoicesDir(), config.SqlitePath)
+	}
+
+	return config, nil
+}
+
+var ErrFilesNotImported = errors.New("invoices stored as files are not in the SQLite database, import them with `config import-files` or keep the files backend")
+
+/*
+Open returns the repository selected in storage.json, the caller closes it. The SQLite
+database is refused while invoices stored as files are missing in it, they would be
+silently left out of listings, exports and numbering.
+*/
+func Open() (InvoiceRepository, error) {
+	config, err := LoadConfig(StorageConfigJsonPath())
+	if err != nil {
+		return nil, err
+	}
+
+	if config.Backend != BACKEND_SQLITE {
+		return NewFileRepository(), nil
+	}
+
+	repository, err := NewSqliteRepository(config.SqlitePath)
+	if err != nil {
+		return nil, err
+	}
+
+	missing, err := repository.notImported()
+	if err == nil && len(missing) > 0 {
+		err = fmt.Errorf("%w (%d files, e.g. %s)", ErrFilesNotImported, len(missing), missing[0])
+	}
+	if err != nil {
+		repository.Close()
+		return nil, err
+	}
+
+	return repository, nil
+}
@@ -0,0 +1,382 @@
+package JpkExporter
+
+import (
+	"encoding/xml"
+	"fmt"
+	InvoiceManager "moneybringer/invoice-manager"
+	CompanyData "moneybringer/invoice-manager/company"
+	TaxRate "moneybringer/invoice-manager/tax-rate"
+	Money "moneybringer/utils/money"
+	TimeUtils "moneybringer/utils/time"
+	"regexp"
+	"strconv"
+	"time"
+)
+
+const JPK_V7M_NAMESPACE = "http://crd.gov.pl/wzor/2021/12/27/11148/"
+const ETD_NAMESPACE = "http://crd.gov.pl/xml/schematy/dziedzinowe/mf/2021/06/08/eD/DefinicjeTypy/"
+
+const TAXPAYER_COMPANY = "company"
+const TAXPAYER_PERSON = "person"
+
+type Control struct {
+	LiczbaWierszySprzedazy int
+	PodatekNalezny         Money.Money
+}
+
+type kodFormularza struct {
+	KodSystemowy string `xml:"kodSystemowy,attr"`
+	WersjaSchemy string `xml:"wersjaSchemy,attr"`
+	Value        string `xml:",chardata"`
+}
+
+type celZlozenia struct {
+	Poz   string `xml:"poz,attr"`
+	Value string `xml:",chardata"`
+}
+
+type naglowek struct {
+	KodFormularza      kodFormularza
+	WariantFormularza  string
+	DataWytworzeniaJPK string
+	NazwaSystemu       string
+	CelZlozenia        celZlozenia
+	KodUrzedu          string
+	Rok                int
+	Miesiac            int
+}
+
+type osobaNiefizyczna struct {
+	NIP        string `xml:"NIP"`
+	PelnaNazwa string `xml:"PelnaNazwa"`
+	Email      string `xml:"Email"`
+}
+
+type osobaFizyczna struct {
+	NIP           string `xml:"etd:NIP"`
+	ImiePierwsze  string `xml:"etd:ImiePierwsze"`
+	Nazwisko      string `xml:"etd:Nazwisko"`
+	DataUrodzenia string `xml:"etd:DataUrodzenia"`
+	Email         string `xml:"Email"`
+}
+
+type podmiot1 struct {
+	Rola             string            `xml:"rola,attr"`
+	OsobaFizyczna    *osobaFizyczna    `xml:",omitempty"`
+	OsobaNiefizyczna *osobaNiefizyczna `xml:",omitempty"`
+}
+
+type kodFormularzaDekl struct {
+	KodSystemowy       string `xml:"kodSystemowy,attr"`
+	KodPodatku         string `xml:"kodPodatku,attr"`
+	RodzajZobowiazania string `xml:"rodzajZobowiazania,attr"`
+	WersjaSchemy       string `xml:"wersjaSchemy,attr"`
+	Value              string `xml:",chardata"`
+}
+
+type pozycjeSzczegolowe struct {
+	P_10 string `xml:",omitempty"`
+	P_11 string `xml:",omitempty"`
+	P_13 string `xml:",omitempty"`
+	P_15 string `xml:",omitempty"`
+	P_16 string `xml:",omitempty"`
+	P_17 string `xml:",omitempty"`
+	P_18 string `xml:",omitempty"`
+	P_19 string `xml:",omitempty"`
+	P_20 string `xml:",omitempty"`
+	P_21 string `xml:",omitempty"`
+	P_22 string `xml:",omitempty"`
+	P_31 string `xml:",omitempty"`
+	P_37 string
+	P_38 string
+	P_51 string
+}
+
+type deklaracja struct {
+	Naglowek struct {
+		KodFormularzaDekl     kodFormularzaDekl
+		WariantFormularzaDekl string
+	}
+	PozycjeSzczegolowe pozycjeSzczegolowe
+	Pouczenia          string
+}
+
+type sprzedazWiersz struct {
+	LpSprzedazy        int
+	KodKrajuNadaniaTIN string `xml:",omitempty"`
+	NrKontrahenta      string
+	NazwaKontrahenta   string
+	DowodSprzedazy     string
+	DataWystawienia    string
+	DataSprzedazy      string `xml:",omitempty"`
+	K_10               string `xml:",omitempty"`
+	K_11               string `xml:",omitempty"`
+	K_13               string `xml:",omitempty"`
+	K_15               string `xml:",omitempty"`
+	K_16               string `xml:",omitempty"`
+	K_17               string `xml:",omitempty"`
+	K_18               string `xml:",omitempty"`
+	K_19               string `xml:",omitempty"`
+	K_20               string `xml:",omitempty"`
+	K_21               string `xml:",omitempty"`
+	K_22               string `xml:",omitempty"`
+	K_31               string `xml:",omitempty"`
+}
+
+type sprzedazCtrl struct {
+	LiczbaWierszySprzedazy int
+	PodatekNalezny         string
+}
+
+type zakupCtrl struct {
+	LiczbaWierszyZakupow int
+	PodatekNaliczony     string
+}
+
+type ewidencja struct {
+	SprzedazWiersz []sprzedazWiersz
+	SprzedazCtrl   sprzedazCtrl
+	ZakupCtrl      zakupCtrl
+}
+
+type jpk struct {
+	XMLName    xml.Name `xml:"JPK"`
+	Xmlns      string   `xml:"xmlns,attr"`
+	XmlnsEtd   string   `xml:"xmlns:etd,attr"`
+	Naglowek   naglowek
+	Podmiot1   podmiot1
+	Deklaracja deklaracja
+	Ewidencja  ewidencja
+}
+
+/* kColumns maps the sales register columns (K_x) filled for each tax rate: net and, where VAT is due, tax, see zeroRateColumns for 0% */
+var kColumns = map[TaxRate.TaxRate][2]string{
+	"23":                   {"K_19", "K_20"},
+	"22":                   {"K_19", "K_20"},
+	"8":                    {"K_17", "K_18"},
+	"7":                    {"K_17", "K_18"},
+	"5":                    {"K_15", "K_16"},
+	"0":                    {"K_13", ""},
+	TaxRate.EXEMPT:         {"K_10", ""},
+	TaxRate.NOT_SUBJECT:    {"K_11", ""},
+	TaxRate.REVERSE_CHARGE: {"K_31", ""},
+}
+
+/* zeroRateColumns splits sales at 0% by transaction as the FA(2) export does: domestic, intra-EU supply (WDT) and export */
+var zeroRateColumns = map[string]string{
+	InvoiceManager.TRANSACTION_DOMESTIC:        "K_13",
+	InvoiceManager.TRANSACTION_INTRA_EU_SUPPLY: "K_21",
+	InvoiceManager.TRANSACTION_EXPORT:          "K_22",
+}
+
+var nonDigitRegexp = regexp.MustCompile(`\D`)
+
+/*
+GenerateV7M builds the JPK_V7M sales register for one month from issued invoices.
+Purchases are not tracked by moneybringer, so the purchase register stays empty
+and the declaration part only reflects output VAT.
+*/
+func GenerateV7M(invoices []InvoiceManager.InvoiceCreatedData, company CompanyData.Company, year int, month time.Month) ([]byte, Control, error) {
+	var control Control
+	document := jpk{Xmlns: JPK_V7M_NAMESPACE, XmlnsEtd: ETD_NAMESPACE}
+
+	document.Naglowek = naglowek{
+		KodFormularza:      kodFormularza{KodSystemowy: "JPK_V7M (2)", WersjaSchemy: "1-0E", Value: "JPK_VAT"},
+		WariantFormularza:  "2",
+		DataWytworzeniaJPK: time.Now().UTC().Format("2006-01-02T15:04:05Z"),
+		NazwaSystemu:       "moneybringer",
+		CelZlozenia:        celZlozenia{Poz: "P_7", Value: "1"},
+		KodUrzedu:          company.Jpk.TaxOfficeCode,
+		Rok:                year,
+		Miesiac:            int(month),
+	}
+
+	podmiot, err := buildPodmiot1(company)
+	if err != nil {
+		return nil, control, err
+	}
+	document.Podmiot1 = podmiot
+
+	totals := map[string]Money.Money{}
+	for _, invoice := range invoices {
+		/* a proforma is not a VAT invoice and creates no tax obligation */
+		if invoice.Kind() == InvoiceManager.DOCUMENT_KIND_PROFORMA {
+			continue
+		}
+
+		row, err := buildSprzedazWiersz(len(document.Ewidencja.SprzedazWiersz)+1, invoice, totals)
+		if err != nil {
+			return nil, control, err
+		}
+		document.Ewidencja.SprzedazWiersz = append(document.Ewidencja.SprzedazWiersz, row)
+	}
+
+	control.LiczbaWierszySprzedazy = len(document.Ewidencja.SprzedazWiersz)
+	control.PodatekNalezny = totals["K_16"].Add(totals["K_18"]).Add(totals["K_20"])
+
+	document.Ewidencja.SprzedazCtrl = sprzedazCtrl{
+		LiczbaWierszySprzedazy: control.LiczbaWierszySprzedazy,
+		PodatekNalezny:         control.PodatekNalezny.String(),
+	}
+	document.Ewidencja.ZakupCtrl = zakupCtrl{PodatekNaliczony: "0.00"}
+	document.Deklaracja = buildDeklaracja(totals, control)
+
+	xmlData, err := xml.MarshalIndent(document, "", "  ")
+	if err != nil {
+		return nil, control, err
+	}
+
+	return append([]byte(xml.Header), xmlData...), control, nil
+}
+
+func buildPodmiot1(company CompanyData.Company) (podmiot1, error) {
+	nip := nonDigitRegexp.ReplaceAllString(company.CompanyDetails.TaxNumber, "")
+
+	switch company.Jpk.TaxpayerType {
+	case TAXPAYER_COMPANY, "":
+		return podmiot1{Rola: "Podatnik", OsobaNiefizyczna: &osobaNiefizyczna{
+			NIP:        nip,
+			PelnaNazwa: company.CompanyDetails.FullName,
+			Email:      company.CompanyDetails.Email,
+		}}, nil
+	case TAXPAYER_PERSON:
+		birthDate, err := TimeUtils.ParseDdMmYyyy(company.Jpk.BirthDate)
+		if err != nil {
+			return podmiot1{}, fmt.Errorf("jpk.birthDate must be a DD-MM-YYYY date for taxpayerType %q", TAXPAYER_PERSON)
+		}
+
+		return podmiot1{Rola: "Podatnik", OsobaFizyczna: &osobaFizyczna{
+			NIP:           nip,
+			ImiePierwsze:  company.PersonalDetails.FirstName,
+			Nazwisko:      company.PersonalDetails.LastName,
+			DataUrodzenia: birthDate.Format("2006-01-02"),
+			Email:         company.PersonalDetails.Email,
+		}}, nil
+	}
+
+	return podmiot1{}, fmt.Errorf("unknown jpk.taxpayerType %q, use %s or %s", company.Jpk.TaxpayerType, TAXPAYER_COMPANY, TAXPAYER_PERSON)
+}
+
+func buildSprzedazWiersz(lp int, invoice InvoiceManager.InvoiceCreatedData, totals map[string]Money.Money) (sprzedazWiersz, error) {
+	dateOfIssue, err := TimeUtils.ParseDdMmYyyy(invoice.DateOfIssue)
+	if err != nil {
+		return sprzedazWiersz{}, fmt.Errorf("invoice %s: invalid date of issue %q", invoice.InvoiceNo, invoice.DateOfIssue)
+	}
+
+	row := sprzedazWiersz{
+		LpSprzedazy:      lp,
+		NrKontrahenta:    "BRAK",
+		NazwaKontrahenta: invoice.InvoiceTo.FullName,
+		DowodSprzedazy:   invoice.InvoiceNo,
+		DataWystawienia:  dateOfIssue.Format("2006-01-02"),
+	}
+
+	switch buyer := invoice.InvoiceTo; {
+	case InvoiceManager.IsDomesticBuyer(invoice):
+		row.NrKontrahenta = buyer.TaxNumber
+	case len(buyer.VatEuNumber) > 2:
+		row.KodKrajuNadaniaTIN = buyer.VatEuNumber[:2]
+		row.NrKontrahenta = buyer.VatEuNumber[2:]
+	}
+
+	if serviceEnd, err := TimeUtils.ParseDdMmYyyy(invoice.ServiceEndDate); err == nil && !serviceEnd.Equal(dateOfIssue) {
+		row.DataSprzedazy = serviceEnd.Format("2006-01-02")
+	}
+
+	vatBreakdown := InvoiceManager.VatBreakdownOf(invoice)
+	if invoice.Conversion != nil {
+		vatBreakdown = invoice.Conversion.Summary.VatBreakdown
+	}
+
+	columns := map[string]Money.Money{}
+	for _, rateSummary := range vatBreakdown {
+		currency := rateSummary.NetValue.Currency
+		if currency != "" && currency != InvoiceManager.PLN {
+			return row, fmt.Errorf("invoice %s is in %s and has no PLN conversion, JPK needs amounts in PLN", invoice.InvoiceNo, currency)
+		}
+
+		column, exists := kColumns[rateSummary.TaxRate]
+		if !exists {
+			return row, fmt.Errorf("invoice %s: tax rate %s is not supported in JPK_V7M", invoice.InvoiceNo, rateSummary.TaxRate.Label())
+		}
+		if rateSummary.TaxRate == "0" {
+			column[0] = zeroRateColumns[InvoiceManager.TransactionTypeOf(invoice)]
+		}
+
+		columns[column[0]] = columns[column[0]].Add(rateSummary.NetValue)
+		if column[1] != "" {
+			columns[column[1]] = columns[column[1]].Add(rateSummary.TaxAmount)
+		}
+	}
+
+	format := func(column string) string {
+		amount, exists := columns[column]
+		if !exists {
+			return ""
+		}
+		totals[column] = totals[column].Add(amount)
+		return amount.String()
+	}
+
+	row.K_10, row.K_11, row.K_13 = format("K_10"), format("K_11"), format("K_13")
+	row.K_15, row.K_16 = format("K_15"), format("K_16")
+	row.K_17, row.K_18 = format("K_17"), format("K_18")
+	row.K_19, row.K_20 = format("K_19"), format("K_20")
+	row.K_21, row.K_22 = format("K_21"), format("K_22")
+	row.K_31 = format("K_31")
+
+	return row, nil
+}
+
+/* The declaration uses whole zloty amounts */
+func buildDeklaracja(totals map[string]Money.Money, control Control) deklaracja {
+	var declaration deklaracja
+
+	declaration.Naglowek.KodFormularzaDekl = kodFormularzaDekl{
+		KodSystemowy:       "VAT-7 (22)",
+		KodPodatku:         "VAT",
+		RodzajZobowiazania: "Z",
+		WersjaSchemy:       "1-0E",
+		Value:              "VAT-7",
+	}
+	declaration.Naglowek.WariantFormularzaDekl = "22"
+	declaration.Pouczenia = "1"
+
+	whole := func(column string) string {
+		amount, exists := totals[column]
+		if !exists {
+			return ""
+		}
+		return strconv.FormatInt(roundToZloty(amount), 10)
+	}
+
+	positions := pozycjeSzczegolowe{
+		P_10: whole("K_10"), P_11: whole("K_11"), P_13: whole("K_13"),
+		P_15: whole("K_15"), P_16: whole("K_16"),
+		P_17: whole("K_17"), P_18: whole("K_18"),
+		P_19: whole("K_19"), P_20: whole("K_20"),
+		P_21: whole("K_21"), P_22: whole("K_22"),
+		P_31: whole("K_31"),
+	}
+
+	var totalNet int64
+	for _, column := range []string{"K_10", "K_11", "K_13", "K_15", "K_17", "K_19", "K_21", "K_22", "K_31"} {
+		totalNet += roundToZloty(totals[column])
+	}
+	totalTax := roundToZloty(totals["K_16"]) + roundToZloty(totals["K_18"]) + roundToZloty(totals["K_20"])
+
+	positions.P_37 = strconv.FormatInt(totalNet, 10)
+	positions.P_38 = strconv.FormatInt(totalTax, 10)
+	positions.P_51 = strconv.FormatInt(totalTax, 10)
+	declaration.PozycjeSzczegolowe = positions
+
+	return declaration
+}
+
+func roundToZloty(amount Money.Money) int64 {
+	if amount.Amount < 0 {
+		return -((-amount.Amount + 50) / 100)
+	}
+
+	return (amount.Amount + 50) / 100
+}
@@ -0,0 +1,240 @@
+package JpkExporter_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	InvoiceManager "moneybringer/invoice-manager"
+	CompanyData "moneybringer/invoice-manager/company"
+	TaxRate "moneybringer/invoice-manager/tax-rate"
+	JpkExporter "moneybringer/jpk-exporter"
+	Money "moneybringer/utils/money"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+/* go test ./jpk-exporter -update rewrites the golden XML files after an intended change of the exporter */
+var update = flag.Bool("update", false, "rewrite testdata/*.xml from the generated registers")
+
+/* DataWytworzeniaJPK is the time of the export, it is the only value differing between runs */
+var creationTimeRegexp = regexp.MustCompile(`<DataWytworzeniaJPK>[^<]*</DataWytworzeniaJPK>`)
+
+const fixedCreationTime = "<DataWytworzeniaJPK>2026-11-05T12:00:00Z</DataWytworzeniaJPK>"
+
+func testCompany() CompanyData.Company {
+	var company CompanyData.Company
+	company.CompanyDetails.FullName = "John Doe Inc."
+	company.CompanyDetails.TaxNumber = "PL2222222222"
+	company.CompanyDetails.Email = "john.doe.inc@gmail.com"
+	company.Jpk = CompanyData.Jpk{TaxOfficeCode: "3021", TaxpayerType: JpkExporter.TAXPAYER_COMPANY}
+
+	return company
+}
+
+/* loadMonth reads the stored invoices of testdata/<month> in the order of their file names */
+func loadMonth(t *testing.T, month string) []InvoiceManager.InvoiceCreatedData {
+	t.Helper()
+
+	paths, err := filepath.Glob(filepath.Join("testdata", month, "*.json"))
+	if err != nil || len(paths) == 0 {
+		t.Fatalf("no invoices in testdata/%s: %v", month, err)
+	}
+
+	var invoices []InvoiceManager.InvoiceCreatedData
+	for _, path := range paths {
+		jsonData, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var invoice InvoiceManager.InvoiceCreatedData
+		if err := json.Unmarshal(jsonData, &invoice); err != nil {
+			t.Fatalf("%s: %v", path, err)
+		}
+		invoices = append(invoices, invoice)
+	}
+
+	return invoices
+}
+
+/* field returns the text of the only element name in the document */
+func field(t *testing.T, xmlData []byte, name string) string {
+	t.Helper()
+
+	matches := regexp.MustCompile(`<`+name+`>([^<]*)</`+name+`>`).FindAllSubmatch(xmlData, -1)
+	if len(matches) != 1 {
+		t.Fatalf("%d <%s> elements, want one", len(matches), name)
+	}
+
+	return string(matches[0][1])
+}
+
+/*
+testdata/2026-10 holds a month with 23%, 8% and zw sales, an EU service outside Poland (np)
+and a 23% invoice in EUR converted to PLN, a WDT and an export at 0%, a correction lowering
+8% and zw to zero and a proforma, which is not part of the register
+*/
+func TestGenerateV7MMatchesGoldenFile(t *testing.T) {
+	xmlData, control, err := JpkExporter.GenerateV7M(loadMonth(t, "2026-10"), testCompany(), 2026, time.October)
+	if err != nil {
+		t.Fatal(err)
+	}
+	xmlData = creationTimeRegexp.ReplaceAll(xmlData, []byte(fixedCreationTime))
+
+	goldenPath := filepath.Join("testdata", "2026-10.xml")
+	if *update {
+		if err := os.WriteFile(goldenPath, xmlData, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	golden, err := os.ReadFile(goldenPath)
+	if err != nil {
+		t.Fatalf("%v (run with -update to create it)", err)
+	}
+	if !bytes.Equal(xmlData, golden) {
+		t.Errorf("generated XML differs from %s (run with -update after an intended change):\n%s", goldenPath, xmlData)
+	}
+
+	/* the proforma is left out, the correction is a row of its own */
+	if control.LiczbaWierszySprzedazy != 6 || field(t, xmlData, "LiczbaWierszySprzedazy") != "6" {
+		t.Errorf("LiczbaWierszySprzedazy = %d, want 6", control.LiczbaWierszySprzedazy)
+	}
+
+	/* 277.15 + 977.82 (230.01 EUR at 4.2512) + 1378.85 of the correction, 8% is 24.00 - 24.00 */
+	if control.PodatekNalezny != Money.New(263382, "PLN") || field(t, xmlData, "PodatekNalezny") != "2633.82" {
+		t.Errorf("PodatekNalezny = %s %s, want 2633.82 PLN", control.PodatekNalezny, control.PodatekNalezny.Currency)
+	}
+
+	declaration := map[string]string{
+		"P_10": "0", "P_11": "20640", "P_17": "0", "P_18": "0",
+		"P_19": "11451", // 1205.00 + 4251.41 + 5995.00
+		"P_20": "2634",
+		"P_21": "10000", "P_22": "10000",
+		"P_37": "52091", "P_38": "2634", "P_51": "2634",
+	}
+	for name, expected := range declaration {
+		if got := field(t, xmlData, name); got != expected {
+			t.Errorf("%s = %s, want %s", name, got, expected)
+		}
+	}
+}
+
+func rateRow(t *testing.T, rate TaxRate.TaxRate, net string, tax string) InvoiceManager.VatRateSummary {
+	t.Helper()
+
+	netValue, err := Money.Parse(net, "PLN")
+	if err != nil {
+		t.Fatal(err)
+	}
+	taxAmount, err := Money.Parse(tax, "PLN")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return InvoiceManager.VatRateSummary{TaxRate: rate, NetValue: netValue, TaxAmount: taxAmount, GrossValue: netValue.Add(taxAmount)}
+}
+
+func domesticInvoice(number string, rows ...InvoiceManager.VatRateSummary) InvoiceManager.InvoiceCreatedData {
+	invoice := InvoiceManager.InvoiceCreatedData{InvoiceNo: number, DateOfIssue: "09-10-2026", Currency: "PLN"}
+	invoice.InvoiceTo.FullName = "Some Company Inc"
+	invoice.InvoiceTo.TaxNumber = "7781234563"
+	invoice.InvoiceSummary.VatBreakdown = rows
+
+	return invoice
+}
+
+func TestSalesRowColumnsPerTaxRate(t *testing.T) {
+	cases := []struct {
+		rate    TaxRate.TaxRate
+		columns map[string]string
+	}{
+		{"23", map[string]string{"K_19": "100.00", "K_20": "23.00"}},
+		{"8", map[string]string{"K_17": "100.00", "K_18": "8.00"}},
+		{"5", map[string]string{"K_15": "100.00", "K_16": "5.00"}},
+		{"0", map[string]string{"K_13": "100.00"}},
+		{TaxRate.EXEMPT, map[string]string{"K_10": "100.00"}},
+		{TaxRate.NOT_SUBJECT, map[string]string{"K_11": "100.00"}},
+		{TaxRate.REVERSE_CHARGE, map[string]string{"K_31": "100.00"}},
+	}
+	kRegexp := regexp.MustCompile(`<(K_\d+)>([^<]*)</K_\d+>`)
+
+	for _, c := range cases {
+		tax := map[TaxRate.TaxRate]string{"23": "23.00", "8": "8.00", "5": "5.00"}[c.rate]
+		if tax == "" {
+			tax = "0.00"
+		}
+		invoice := domesticInvoice("1/10/2026", rateRow(t, c.rate, "100.00", tax))
+
+		xmlData, _, err := JpkExporter.GenerateV7M([]InvoiceManager.InvoiceCreatedData{invoice}, testCompany(), 2026, time.October)
+		if err != nil {
+			t.Fatalf("rate %s: %v", c.rate, err)
+		}
+
+		columns := map[string]string{}
+		for _, match := range kRegexp.FindAllSubmatch(xmlData, -1) {
+			columns[string(match[1])] = string(match[2])
+		}
+		if len(columns) != len(c.columns) {
+			t.Errorf("rate %s filled %v, want %v", c.rate, columns, c.columns)
+		}
+		for column, expected := range c.columns {
+			if columns[column] != expected {
+				t.Errorf("rate %s: %s = %q, want %s", c.rate, column, columns[column], expected)
+			}
+		}
+	}
+}
+
+func TestDeclarationRoundsEveryColumnToWholeZloty(t *testing.T) {
+	invoices := []InvoiceManager.InvoiceCreatedData{
+		domesticInvoice("1/10/2026", rateRow(t, "23", "435.65", "100.20"), rateRow(t, "8", "6.05", "0.49")),
+		domesticInvoice("2/10/2026", rateRow(t, "23", "1.30", "0.30")),
+		/* a correction lowering 8% */
+		domesticInvoice("KOR/1/10/2026", rateRow(t, "8", "-12.55", "-1.00")),
+	}
+
+	xmlData, control, err := JpkExporter.GenerateV7M(invoices, testCompany(), 2026, time.October)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	/* the sales register keeps grosze, the declaration rounds each column half away from zero */
+	if control.PodatekNalezny.String() != "99.99" {
+		t.Errorf("PodatekNalezny = %s, want 100.20 + 0.30 + 0.49 - 1.00 = 99.99", control.PodatekNalezny)
+	}
+	expected := map[string]string{
+		"P_19": "437", // 436.95
+		"P_20": "101", // 100.50
+		"P_17": "-7",  // -6.50
+		"P_18": "-1",  // -0.51
+		"P_37": "430",
+		"P_38": "100",
+		"P_51": "100",
+	}
+	for name, want := range expected {
+		if got := field(t, xmlData, name); got != want {
+			t.Errorf("%s = %s, want %s", name, got, want)
+		}
+	}
+}
+
+func TestForeignCurrencyInvoiceNeedsConversion(t *testing.T) {
+	invoices := loadMonth(t, "2026-10")
+
+	for _, invoice := range invoices {
+		if invoice.Currency == "PLN" {
+			continue
+		}
+		invoice.Conversion = nil
+
+		_, _, err := JpkExporter.GenerateV7M([]InvoiceManager.InvoiceCreatedData{invoice}, testCompany(), 2026, time.October)
+		if err == nil || !strings.Contains(err.Error(), "no PLN conversion") {
+			t.Errorf("%s in %s without conversion = %v, want an error", invoice.InvoiceNo, invoice.Currency, err)
+		}
+	}
+}
@@ -0,0 +1,384 @@
+package FakeKsef
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"time"
+)
+
+/*
+Server imitates the KSeF online interface on top of httptest so the client can
+be exercised offline: it checks the encrypted token, accepts FA documents,
+reports "processing" on the first status poll and issues UPO receipts. Like KSeF
+it rejects a second invoice with an already accepted number (processing code 440).
+*/
+type Server struct {
+	Token string
+
+	httpServer *httptest.Server
+	privateKey *rsa.PrivateKey
+	nip        string
+
+	mutex      sync.Mutex
+	counter    int
+	challenges map[string]time.Time
+	sessions   map[string]*session
+	invoices   map[string]*invoice
+}
+
+type session struct {
+	referenceNumber string
+	terminated      bool
+	invoices        []string
+}
+
+type invoice struct {
+	ksefReferenceNumber string
+	invoiceNumber       string
+	acquired            time.Time
+	polls               int
+	duplicate           bool
+}
+
+type initSessionTokenRequest struct {
+	Context struct {
+		Challenge  string `xml:"Challenge"`
+		Identifier struct {
+			Identifier string `xml:"Identifier"`
+		} `xml:"Identifier"`
+		Token string `xml:"Token"`
+	} `xml:"Context"`
+}
+
+/* PROCESSING_CODE_DUPLICATE is the status of an invoice whose number KSeF already holds */
+const PROCESSING_CODE_DUPLICATE = 440
+
+func NewServer(token string) (*Server, error) {
+	privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		return nil, err
+	}
+
+	server := &Server{
+		Token:      token,
+		privateKey: privateKey,
+		challenges: map[string]time.Time{},
+		sessions:   map[string]*session{},
+		invoices:   map[string]*invoice{},
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /api/online/Session/AuthorisationChallenge", server.handleChallenge)
+	mux.HandleFunc("POST /api/online/Session/InitToken", server.handleInitToken)
+	mux.HandleFunc("PUT /api/online/Invoice/Send", server.handleSend)
+	mux.HandleFunc("GET /api/online/Invoice/Status/{reference}", server.handleInvoiceStatus)
+	mux.HandleFunc("GET /api/online/Session/Terminate", server.handleTerminate)
+	mux.HandleFunc("GET /api/common/Status/{reference}", server.handleSessionStatus)
+
+	server.httpServer = httptest.NewServer(mux)
+
+	return server, nil
+}
+
+func (s *Server) URL() string {
+	return s.httpServer.URL
+}
+
+func (s *Server) Close() {
+	s.httpServer.Close()
+}
+
+/* PublicKeyPEM returns the key the client must use to encrypt the token, like the MF public key */
+func (s *Server) PublicKeyPEM() []byte {
+	publicKey, _ := x509.MarshalPKIXPublicKey(&s.privateKey.PublicKey)
+	return pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: publicKey})
+}
+
+func (s *Server) handleChallenge(w http.ResponseWriter, r *http.Request) {
+	var request struct {
+		ContextIdentifier struct {
+			Identifier string `json:"identifier"`
+		} `json:"contextIdentifier"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid challenge request")
+		return
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	timestamp := time.Now().UTC()
+	challenge := fmt.Sprintf("%s-CR-%s", timestamp.Format("20060102-150405"), s.nextId())
+	s.challenges[challenge] = timestamp
+	s.nip = request.ContextIdentifier.Identifier
+
+	writeJSON(w, http.StatusCreated, map[string]string{
+		"challenge": challenge,
+		"timestamp": timestamp.Format(time.RFC3339Nano),
+	})
+}
+
+func (s *Server) handleInitToken(w http.ResponseWriter, r *http.Request) {
+	var request initSessionTokenRequest
+	if err := xml.NewDecoder(r.Body).Decode(&request); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid InitSessionTokenRequest")
+		return
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	timestamp, exists := s.challenges[request.Context.Challenge]
+	if !exists {
+		writeError(w, http.StatusUnauthorized, "unknown challenge")
+		return
+	}
+	delete(s.challenges, request.Context.Challenge)
+
+	encrypted, err := base64.StdEncoding.DecodeString(request.Context.Token)
+	if err != nil {
+		writeError(w, http.StatusUnauthorized, "token is not base64")
+		return
+	}
+
+	plain, err := rsa.DecryptPKCS1v15(nil, s.privateKey, encrypted)
+	expected := fmt.Sprintf("%s|%d", s.Token, timestamp.UnixMilli())
+	if err != nil || string(plain) != expected {
+		writeError(w, http.StatusUnauthorized, "invalid token")
+		return
+	}
+
+	reference := fmt.Sprintf("%s-SO-%s", time.Now().UTC().Format("20060102-150405"), s.nextId())
+	s.sessions[reference] = &session{referenceNumber: reference}
+
+	writeJSON(w, http.StatusCreated, map[string]interface{}{
+		"referenceNumber": reference,
+		"sessionToken":    map[string]string{"token": "session-" + reference},
+	})
+}
+
+func (s *Server) handleSend(w http.ResponseWriter, r *http.Request) {
+	var request struct {
+		InvoiceHash struct {
+			HashSHA struct {
+				Value string `json:"value"`
+			} `json:"hashSHA"`
+		} `json:"invoiceHash"`
+		InvoicePayload struct {
+			InvoiceBody string `json:"invoiceBody"`
+		} `json:"invoicePayload"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid send request")
+		return
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	activeSession := s.sessionFor(r)
+	if activeSession == nil {
+		writeError(w, http.StatusUnauthorized, "missing or expired SessionToken")
+		return
+	}
+
+	body, err := base64.StdEncoding.DecodeString(request.InvoicePayload.InvoiceBody)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invoice body is not base64")
+		return
+	}
+
+	hash := sha256.Sum256(body)
+	if base64.StdEncoding.EncodeToString(hash[:]) != request.InvoiceHash.HashSHA.Value {
+		writeError(w, http.StatusBadRequest, "invoice hash mismatch")
+		return
+	}
+
+	var document struct {
+		Fa struct {
+			P_2 string `xml:"P_2"`
+		} `xml:"Fa"`
+	}
+	if err := xml.Unmarshal(body, &document); err != nil || document.Fa.P_2 == "" {
+		writeError(w, http.StatusBadRequest, "invoice body is not an FA document")
+		return
+	}
+
+	now := time.Now().UTC()
+	elementReference := fmt.Sprintf("%s-EE-%s", now.Format("20060102-150405"), s.nextId())
+	checksum := sha256.Sum256([]byte(elementReference))
+	s.invoices[elementReference] = &invoice{
+		ksefReferenceNumber: fmt.Sprintf("%s-%s-%s-%s", s.nip, now.Format("20060102"), strings.ToUpper(hex.EncodeToString(checksum[:6])), strings.ToUpper(hex.EncodeToString(checksum[6:7]))),
+		invoiceNumber:       document.Fa.P_2,
+		acquired:            now,
+		duplicate:           s.accepted(document.Fa.P_2),
+	}
+	activeSession.invoices = append(activeSession.invoices, elementReference)
+
+	writeJSON(w, http.StatusAccepted, map[string]interface{}{
+		"elementReferenceNumber": elementReference,
+		"processingCode":         100,
+		"processingDescription":  "Invoice accepted for processing",
+	})
+}
+
+func (s *Server) handleInvoiceStatus(w http.ResponseWriter, r *http.Request) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.sessionFor(r) == nil {
+		writeError(w, http.StatusUnauthorized, "missing or expired SessionToken")
+		return
+	}
+
+	storedInvoice, exists := s.invoices[r.PathValue("reference")]
+	if !exists {
+		writeError(w, http.StatusNotFound, "unknown element reference number")
+		return
+	}
+
+	storedInvoice.polls++
+	if storedInvoice.polls == 1 {
+		writeJSON(w, http.StatusOK, map[string]interface{}{
+			"processingCode":        100,
+			"processingDescription": "Invoice is being processed",
+		})
+		return
+	}
+
+	if storedInvoice.duplicate {
+		writeJSON(w, http.StatusOK, map[string]interface{}{
+			"processingCode":         PROCESSING_CODE_DUPLICATE,
+			"processingDescription":  "Duplicate invoice " + storedInvoice.invoiceNumber,
+			"elementReferenceNumber": r.PathValue("reference"),
+		})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"processingCode":         200,
+		"processingDescription":  "Invoice stored",
+		"elementReferenceNumber": r.PathValue("reference"),
+		"invoiceStatus": map[string]string{
+			"ksefReferenceNumber":  storedInvoice.ksefReferenceNumber,
+			"invoiceNumber":        storedInvoice.invoiceNumber,
+			"acquisitionTimestamp": storedInvoice.acquired.Format(time.RFC3339Nano),
+		},
+	})
+}
+
+func (s *Server) handleTerminate(w http.ResponseWriter, r *http.Request) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	activeSession := s.sessionFor(r)
+	if activeSession == nil {
+		writeError(w, http.StatusUnauthorized, "missing or expired SessionToken")
+		return
+	}
+
+	activeSession.terminated = true
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"referenceNumber": activeSession.referenceNumber,
+		"processingCode":  200,
+	})
+}
+
+func (s *Server) handleSessionStatus(w http.ResponseWriter, r *http.Request) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	closedSession, exists := s.sessions[r.PathValue("reference")]
+	if !exists {
+		writeError(w, http.StatusNotFound, "unknown session reference number")
+		return
+	}
+
+	if !closedSession.terminated {
+		writeJSON(w, http.StatusOK, map[string]interface{}{
+			"processingCode":        300,
+			"processingDescription": "Session is still open",
+		})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"processingCode":        200,
+		"processingDescription": "UPO generated",
+		"upo":                   base64.StdEncoding.EncodeToString([]byte(s.upo(closedSession))),
+	})
+}
+
+func (s *Server) upo(closedSession *session) string {
+	var builder strings.Builder
+
+	builder.WriteString(`<?xml version="1.0" encoding="UTF-8"?>` + "\n")
+	builder.WriteString(`<Potwierdzenie>` + "\n")
+	builder.WriteString("  <NumerReferencyjnySesji>" + closedSession.referenceNumber + "</NumerReferencyjnySesji>\n")
+	for _, elementReference := range closedSession.invoices {
+		storedInvoice := s.invoices[elementReference]
+		if storedInvoice.duplicate {
+			continue
+		}
+		builder.WriteString("  <Dokument>\n")
+		builder.WriteString("    <NumerKSeFDokumentu>" + storedInvoice.ksefReferenceNumber + "</NumerKSeFDokumentu>\n")
+		builder.WriteString("    <NumerFaktury>" + storedInvoice.invoiceNumber + "</NumerFaktury>\n")
+		builder.WriteString("    <DataPrzyjecia>" + storedInvoice.acquired.Format(time.RFC3339) + "</DataPrzyjecia>\n")
+		builder.WriteString("  </Dokument>\n")
+	}
+	builder.WriteString(`</Potwierdzenie>` + "\n")
+
+	return builder.String()
+}
+
+/* accepted tells whether an invoice with the number was already sent and not rejected */
+func (s *Server) accepted(invoiceNumber string) bool {
+	for _, storedInvoice := range s.invoices {
+		if storedInvoice.invoiceNumber == invoiceNumber && !storedInvoice.duplicate {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (s *Server) sessionFor(r *http.Request) *session {
+	reference := strings.TrimPrefix(r.Header.Get("SessionToken"), "session-")
+	activeSession, exists := s.sessions[reference]
+	if !exists || activeSession.terminated {
+		return nil
+	}
+
+	return activeSession
+}
+
+func (s *Server) nextId() string {
+	s.counter++
+	return fmt.Sprintf("%010d", s.counter)
+}
+
+func writeJSON(w http.ResponseWriter, status int, payload interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(payload)
+}
+
+func writeError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, map[string]interface{}{
+		"exception": map[string]string{"exceptionDescription": message},
+	})
+}
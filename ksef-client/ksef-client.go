@@ -0,0 +1,363 @@
+package KsefClient
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	AppPaths "moneybringer/utils/app-paths"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+type Config struct {
+	BaseURL             string `json:"baseUrl"`
+	Token               string `json:"token"`
+	PublicKeyPath       string `json:"publicKeyPath"`
+	PollIntervalSeconds int    `json:"pollIntervalSeconds"`
+	PollTimeoutSeconds  int    `json:"pollTimeoutSeconds"`
+}
+
+type InvoiceStatus struct {
+	ProcessingCode        int    `json:"processingCode"`
+	ProcessingDescription string `json:"processingDescription"`
+	ElementReference      string `json:"elementReferenceNumber"`
+	Invoice               struct {
+		KsefReferenceNumber  string `json:"ksefReferenceNumber"`
+		InvoiceNumber        string `json:"invoiceNumber"`
+		AcquisitionTimestamp string `json:"acquisitionTimestamp"`
+	} `json:"invoiceStatus"`
+}
+
+/*
+Client talks to the KSeF "online" interface: token session, invoice upload,
+status polling and UPO download. BaseURL selects the environment, e.g.
+https://ksef-test.mf.gov.pl or the URL of the bundled fake server.
+*/
+type Client struct {
+	config       Config
+	publicKey    []byte
+	httpClient   *http.Client
+	sessionToken string
+	sessionRef   string
+}
+
+const KSEF_CONFIG_JSON_FILE = "ksef.json"
+const TOKEN_ENV = "MONEYBRINGER_KSEF_TOKEN"
+
+const PROCESSING_CODE_DONE = 200
+
+var ErrNoSession = errors.New("KSeF session is not initialised")
+var ErrTimeout = errors.New("timed out waiting for KSeF")
+
+func KsefConfigJsonPath() string {
+	return AppPaths.ConfigFile(KSEF_CONFIG_JSON_FILE)
+}
+
+/* LoadConfig reads ksef.json, the token may instead come from MONEYBRINGER_KSEF_TOKEN */
+func LoadConfig(path string) (Config, error) {
+	var config Config
+
+	jsonData, err := os.ReadFile(path)
+	if err != nil {
+		return config, err
+	}
+
+	if err := json.Unmarshal(jsonData, &config); err != nil {
+		return config, fmt.Errorf("cannot parse %s: %w", path, err)
+	}
+
+	config.PublicKeyPath = AppPaths.ResolveConfigPath(config.PublicKeyPath)
+
+	if token := os.Getenv(TOKEN_ENV); token != "" {
+		config.Token = token
+	}
+
+	return config, nil
+}
+
+/* New creates a client, publicKeyPEM is the MF key used to encrypt the authorisation token */
+func New(config Config, publicKeyPEM []byte) *Client {
+	if config.PollIntervalSeconds <= 0 {
+		config.PollIntervalSeconds = 2
+	}
+	if config.PollTimeoutSeconds <= 0 {
+		config.PollTimeoutSeconds = 120
+	}
+
+	return &Client{
+		config:     config,
+		publicKey:  publicKeyPEM,
+		httpClient: &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+func NewFromConfig(config Config) (*Client, error) {
+	publicKeyPEM, err := os.ReadFile(config.PublicKeyPath)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read KSeF public key: %w", err)
+	}
+
+	return New(config, publicKeyPEM), nil
+}
+
+func (c *Client) SessionReference() string {
+	return c.sessionRef
+}
+
+/* InitSession authorises with the token for the given seller NIP */
+func (c *Client) InitSession(nip string) error {
+	var challenge struct {
+		Challenge string `json:"challenge"`
+		Timestamp string `json:"timestamp"`
+	}
+
+	challengeRequest := map[string]interface{}{
+		"contextIdentifier": map[string]string{"type": "onip", "identifier": nip},
+	}
+	if err := c.doJSON(http.MethodPost, "/api/online/Session/AuthorisationChallenge", challengeRequest, &challenge); err != nil {
+		return fmt.Errorf("authorisation challenge: %w", err)
+	}
+
+	timestamp, err := time.Parse(time.RFC3339Nano, challenge.Timestamp)
+	if err != nil {
+		return fmt.Errorf("invalid challenge timestamp %q: %w", challenge.Timestamp, err)
+	}
+
+	encryptedToken, err := c.encryptToken(fmt.Sprintf("%s|%d", c.config.Token, timestamp.UnixMilli()))
+	if err != nil {
+		return err
+	}
+
+	var session struct {
+		ReferenceNumber string `json:"referenceNumber"`
+		SessionToken    struct {
+			Token string `json:"token"`
+		} `json:"sessionToken"`
+	}
+
+	body := []byte(initSessionTokenRequest(challenge.Challenge, nip, encryptedToken))
+	if err := c.do(http.MethodPost, "/api/online/Session/InitToken", "application/octet-stream", body, &session); err != nil {
+		return fmt.Errorf("init session: %w", err)
+	}
+
+	c.sessionToken = session.SessionToken.Token
+	c.sessionRef = session.ReferenceNumber
+
+	return nil
+}
+
+/* SendInvoice uploads an FA XML document and returns its element reference number */
+func (c *Client) SendInvoice(invoiceXml []byte) (string, error) {
+	if c.sessionToken == "" {
+		return "", ErrNoSession
+	}
+
+	hash := sha256.Sum256(invoiceXml)
+	request := map[string]interface{}{
+		"invoiceHash": map[string]interface{}{
+			"hashSHA": map[string]string{
+				"algorithm": "SHA-256",
+				"encoding":  "Base64",
+				"value":     base64.StdEncoding.EncodeToString(hash[:]),
+			},
+			"fileSize": len(invoiceXml),
+		},
+		"invoicePayload": map[string]string{
+			"type":        "plain",
+			"invoiceBody": base64.StdEncoding.EncodeToString(invoiceXml),
+		},
+	}
+
+	var response InvoiceStatus
+	if err := c.doJSON(http.MethodPut, "/api/online/Invoice/Send", request, &response); err != nil {
+		return "", fmt.Errorf("send invoice: %w", err)
+	}
+
+	return response.ElementReference, nil
+}
+
+func (c *Client) InvoiceStatus(elementReference string) (InvoiceStatus, error) {
+	var status InvoiceStatus
+	err := c.doJSON(http.MethodGet, "/api/online/Invoice/Status/"+elementReference, nil, &status)
+
+	return status, err
+}
+
+/* WaitForInvoice polls the invoice status until KSeF assigns the reference number */
+func (c *Client) WaitForInvoice(elementReference string) (InvoiceStatus, error) {
+	deadline := time.Now().Add(time.Duration(c.config.PollTimeoutSeconds) * time.Second)
+
+	for {
+		status, err := c.InvoiceStatus(elementReference)
+		if err != nil {
+			return status, err
+		}
+
+		if status.ProcessingCode == PROCESSING_CODE_DONE {
+			return status, nil
+		}
+
+		if status.ProcessingCode >= 400 {
+			return status, fmt.Errorf("invoice rejected by KSeF: %d %s", status.ProcessingCode, status.ProcessingDescription)
+		}
+
+		if time.Now().After(deadline) {
+			return status, fmt.Errorf("%w: invoice %s", ErrTimeout, elementReference)
+		}
+
+		time.Sleep(time.Duration(c.config.PollIntervalSeconds) * time.Second)
+	}
+}
+
+/* TerminateSession closes the session, the UPO for it becomes available afterwards */
+func (c *Client) TerminateSession() error {
+	if c.sessionToken == "" {
+		return ErrNoSession
+	}
+
+	if err := c.doJSON(http.MethodGet, "/api/online/Session/Terminate", nil, nil); err != nil {
+		return fmt.Errorf("terminate session: %w", err)
+	}
+
+	c.sessionToken = ""
+
+	return nil
+}
+
+/* DownloadUpo waits for the official receipt (UPO) of a terminated session and returns its XML */
+func (c *Client) DownloadUpo(sessionRef string) ([]byte, error) {
+	deadline := time.Now().Add(time.Duration(c.config.PollTimeoutSeconds) * time.Second)
+
+	for {
+		var status struct {
+			ProcessingCode        int    `json:"processingCode"`
+			ProcessingDescription string `json:"processingDescription"`
+			Upo                   string `json:"upo"`
+		}
+
+		if err := c.doJSON(http.MethodGet, "/api/common/Status/"+sessionRef, nil, &status); err != nil {
+			return nil, fmt.Errorf("session status: %w", err)
+		}
+
+		if status.ProcessingCode == PROCESSING_CODE_DONE {
+			return base64.StdEncoding.DecodeString(status.Upo)
+		}
+
+		if status.ProcessingCode >= 400 {
+			return nil, fmt.Errorf("session failed: %d %s", status.ProcessingCode, status.ProcessingDescription)
+		}
+
+		if time.Now().After(deadline) {
+			return nil, fmt.Errorf("%w: UPO for session %s", ErrTimeout, sessionRef)
+		}
+
+		time.Sleep(time.Duration(c.config.PollIntervalSeconds) * time.Second)
+	}
+}
+
+func (c *Client) encryptToken(plain string) (string, error) {
+	block, _ := pem.Decode(c.publicKey)
+	if block == nil {
+		return "", errors.New("KSeF public key is not valid PEM")
+	}
+
+	parsed, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return "", fmt.Errorf("cannot parse KSeF public key: %w", err)
+	}
+
+	publicKey, ok := parsed.(*rsa.PublicKey)
+	if !ok {
+		return "", errors.New("KSeF public key is not an RSA key")
+	}
+
+	encrypted, err := rsa.EncryptPKCS1v15(rand.Reader, publicKey, []byte(plain))
+	if err != nil {
+		return "", err
+	}
+
+	return base64.StdEncoding.EncodeToString(encrypted), nil
+}
+
+func (c *Client) doJSON(method string, path string, request interface{}, response interface{}) error {
+	var body []byte
+	if request != nil {
+		var err error
+		body, err = json.Marshal(request)
+		if err != nil {
+			return err
+		}
+	}
+
+	return c.do(method, path, "application/json", body, response)
+}
+
+func (c *Client) do(method string, path string, contentType string, body []byte, response interface{}) error {
+	url := strings.TrimRight(c.config.BaseURL, "/") + path
+
+	httpRequest, err := http.NewRequest(method, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+
+	httpRequest.Header.Set("Accept", "application/json")
+	if body != nil {
+		httpRequest.Header.Set("Content-Type", contentType)
+	}
+	if c.sessionToken != "" {
+		httpRequest.Header.Set("SessionToken", c.sessionToken)
+	}
+
+	httpResponse, err := c.httpClient.Do(httpRequest)
+	if err != nil {
+		return err
+	}
+	defer httpResponse.Body.Close()
+
+	responseData, err := io.ReadAll(httpResponse.Body)
+	if err != nil {
+		return err
+	}
+
+	if httpResponse.StatusCode >= 300 {
+		return fmt.Errorf("%s %s: HTTP %d: %s", method, path, httpResponse.StatusCode, strings.TrimSpace(string(responseData)))
+	}
+
+	if response == nil || len(responseData) == 0 {
+		return nil
+	}
+
+	return json.Unmarshal(responseData, response)
+}
+
+func initSessionTokenRequest(challenge string, nip string, encryptedToken string) string {
+	return `<?xml version="1.0" encoding="UTF-8"?>
+<ns3:InitSessionTokenRequest xmlns="http://ksef.mf.gov.pl/schema/gtw/svc/online/types/2021/10/01/0001" xmlns:ns2="http://ksef.mf.gov.pl/schema/gtw/svc/types/2021/10/01/0001" xmlns:ns3="http://ksef.mf.gov.pl/schema/gtw/svc/online/auth/request/2021/10/01/0001" xmlns:xsi="http://www.w3.org/2001/XMLSchema-instance">
+  <ns3:Context>
+    <Challenge>` + challenge + `</Challenge>
+    <Identifier xsi:type="ns2:SubjectIdentifierByCompanyType">
+      <ns2:Identifier>` + nip + `</ns2:Identifier>
+    </Identifier>
+    <DocumentType>
+      <ns2:Service>KSeF</ns2:Service>
+      <ns2:FormCode>
+        <ns2:SystemCode>FA (2)</ns2:SystemCode>
+        <ns2:SchemaVersion>1-0E</ns2:SchemaVersion>
+        <ns2:TargetNamespace>http://crd.gov.pl/wzor/2023/06/29/12648/</ns2:TargetNamespace>
+        <ns2:Value>FA</ns2:Value>
+      </ns2:FormCode>
+    </DocumentType>
+    <Token>` + encryptedToken + `</Token>
+  </ns3:Context>
+</ns3:InitSessionTokenRequest>`
+}
@@ -0,0 +1,126 @@
+package KsefClient_test
+
+import (
+	"errors"
+	KsefClient "moneybringer/ksef-client"
+	FakeKsef "moneybringer/ksef-client/fake"
+	"strings"
+	"testing"
+)
+
+const sellerNip = "2222222222"
+
+func invoiceXml(invoiceNo string) []byte {
+	return []byte(`<?xml version="1.0" encoding="UTF-8"?>
+<Faktura><Fa><KodWaluty>PLN</KodWaluty><P_1>2026-10-09</P_1><P_2>` + invoiceNo + `</P_2></Fa></Faktura>`)
+}
+
+/* newClient starts a fake KSeF server, it is closed when the test ends */
+func newClient(t *testing.T, token string) *KsefClient.Client {
+	t.Helper()
+
+	server, err := FakeKsef.NewServer("fake-token")
+	if err != nil {
+		t.Fatalf("starting fake KSeF: %v", err)
+	}
+	t.Cleanup(server.Close)
+
+	config := KsefClient.Config{BaseURL: server.URL(), Token: token, PollIntervalSeconds: 1, PollTimeoutSeconds: 10}
+
+	return KsefClient.New(config, server.PublicKeyPEM())
+}
+
+func sendInvoice(t *testing.T, client *KsefClient.Client, invoiceNo string) (KsefClient.InvoiceStatus, error) {
+	t.Helper()
+
+	elementReference, err := client.SendInvoice(invoiceXml(invoiceNo))
+	if err != nil {
+		t.Fatalf("SendInvoice(%s): %v", invoiceNo, err)
+	}
+
+	return client.WaitForInvoice(elementReference)
+}
+
+func TestSendInvoiceAndDownloadUpo(t *testing.T) {
+	client := newClient(t, "fake-token")
+
+	if err := client.InitSession(sellerNip); err != nil {
+		t.Fatalf("InitSession: %v", err)
+	}
+
+	status, err := sendInvoice(t, client, "1/10/2026")
+	if err != nil {
+		t.Fatalf("WaitForInvoice: %v", err)
+	}
+	if status.ProcessingCode != KsefClient.PROCESSING_CODE_DONE {
+		t.Errorf("processing code = %d, want %d", status.ProcessingCode, KsefClient.PROCESSING_CODE_DONE)
+	}
+	if !strings.HasPrefix(status.Invoice.KsefReferenceNumber, sellerNip+"-") {
+		t.Errorf("KSeF number %q does not start with the seller NIP", status.Invoice.KsefReferenceNumber)
+	}
+	if status.Invoice.InvoiceNumber != "1/10/2026" {
+		t.Errorf("invoice number = %q, want 1/10/2026", status.Invoice.InvoiceNumber)
+	}
+
+	sessionRef := client.SessionReference()
+	if err := client.TerminateSession(); err != nil {
+		t.Fatalf("TerminateSession: %v", err)
+	}
+
+	upo, err := client.DownloadUpo(sessionRef)
+	if err != nil {
+		t.Fatalf("DownloadUpo: %v", err)
+	}
+	for _, want := range []string{sessionRef, status.Invoice.KsefReferenceNumber, "<NumerFaktury>1/10/2026</NumerFaktury>"} {
+		if !strings.Contains(string(upo), want) {
+			t.Errorf("UPO does not contain %q:\n%s", want, upo)
+		}
+	}
+}
+
+func TestRejectedInvoice(t *testing.T) {
+	client := newClient(t, "fake-token")
+
+	if err := client.InitSession(sellerNip); err != nil {
+		t.Fatalf("InitSession: %v", err)
+	}
+	if _, err := sendInvoice(t, client, "1/10/2026"); err != nil {
+		t.Fatalf("first invoice: %v", err)
+	}
+
+	status, err := sendInvoice(t, client, "1/10/2026")
+	if err == nil {
+		t.Fatal("a duplicate invoice was accepted")
+	}
+	if status.ProcessingCode < 400 {
+		t.Errorf("processing code = %d, want a rejection (>= 400)", status.ProcessingCode)
+	}
+	if status.Invoice.KsefReferenceNumber != "" {
+		t.Errorf("rejected invoice got KSeF number %q", status.Invoice.KsefReferenceNumber)
+	}
+
+	sessionRef := client.SessionReference()
+	if err := client.TerminateSession(); err != nil {
+		t.Fatalf("TerminateSession: %v", err)
+	}
+
+	upo, err := client.DownloadUpo(sessionRef)
+	if err != nil {
+		t.Fatalf("DownloadUpo: %v", err)
+	}
+	if count := strings.Count(string(upo), "<Dokument>"); count != 1 {
+		t.Errorf("UPO lists %d documents, want only the accepted one:\n%s", count, upo)
+	}
+}
+
+func TestInvalidToken(t *testing.T) {
+	client := newClient(t, "wrong-token")
+
+	if err := client.InitSession(sellerNip); err == nil {
+		t.Fatal("InitSession accepted a wrong token")
+	}
+
+	if _, err := client.SendInvoice(invoiceXml("1/10/2026")); !errors.Is(err, KsefClient.ErrNoSession) {
+		t.Errorf("SendInvoice without session = %v, want ErrNoSession", err)
+	}
+}
@@ -0,0 +1,612 @@
+package KsefExporter
+
+import (
+	"encoding/xml"
+	"fmt"
+	InvoiceManager "moneybringer/invoice-manager"
+	Invoice "moneybringer/invoice-manager/invoice"
+	TaxRate "moneybringer/invoice-manager/tax-rate"
+	Money "moneybringer/utils/money"
+	TimeUtils "moneybringer/utils/time"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const FA2_NAMESPACE = "http://crd.gov.pl/wzor/2023/06/29/12648/"
+
+type kodFormularza struct {
+	KodSystemowy string `xml:"kodSystemowy,attr"`
+	WersjaSchemy string `xml:"wersjaSchemy,attr"`
+	Value        string `xml:",chardata"`
+}
+
+type naglowek struct {
+	KodFormularza     kodFormularza
+	WariantFormularza string
+	DataWytworzeniaFa string
+	SystemInfo        string
+}
+
+type adres struct {
+	KodKraju string
+	AdresL1  string
+	AdresL2  string `xml:",omitempty"`
+}
+
+type daneKontaktowe struct {
+	Email string `xml:",omitempty"`
+}
+
+type podmiot1 struct {
+	DaneIdentyfikacyjne struct {
+		NIP   string
+		Nazwa string
+	}
+	Adres          adres
+	DaneKontaktowe *daneKontaktowe `xml:",omitempty"`
+}
+
+type daneIdentyfikacyjne2 struct {
+	NIP      string `xml:",omitempty"`
+	KodUE    string `xml:",omitempty"`
+	NrVatUE  string `xml:",omitempty"`
+	KodKraju string `xml:",omitempty"`
+	NrID     string `xml:",omitempty"`
+	BrakID   string `xml:",omitempty"`
+	Nazwa    string `xml:",omitempty"`
+}
+
+type podmiot2 struct {
+	DaneIdentyfikacyjne daneIdentyfikacyjne2
+	Adres               *adres          `xml:",omitempty"`
+	DaneKontaktowe      *daneKontaktowe `xml:",omitempty"`
+}
+
+type okresFa struct {
+	P_6_Od string
+	P_6_Do string
+}
+
+type zwolnienie struct {
+	P_19  string `xml:",omitempty"`
+	P_19A string `xml:",omitempty"`
+	P_19N string `xml:",omitempty"`
+}
+
+type adnotacje struct {
+	P_16                 string
+	P_17                 string
+	P_18                 string
+	P_18A                string
+	Zwolnienie           zwolnienie
+	NoweSrodkiTransportu struct {
+		P_22N string
+	}
+	P_23   string
+	PMarzy struct {
+		P_PMarzyN string
+	}
+}
+
+type faWiersz struct {
+	NrWierszaFa int
+	P_7         string `xml:",omitempty"`
+	PKWiU       string `xml:",omitempty"`
+	P_8A        string `xml:",omitempty"`
+	P_8B        string `xml:",omitempty"`
+	P_9A        string `xml:",omitempty"`
+	P_11        string `xml:",omitempty"`
+	P_12        string `xml:",omitempty"`
+	StanPrzed   string `xml:",omitempty"`
+}
+
+type fakturaZaliczkowa struct {
+	NrKSeFZN            string `xml:",omitempty"`
+	NrFaZaliczkowej     string `xml:",omitempty"`
+	NrKSeFFaZaliczkowej string `xml:",omitempty"`
+}
+
+type zamowienieWiersz struct {
+	NrWierszaZam int
+	P_7Z         string `xml:",omitempty"`
+	PKWiUZ       string `xml:",omitempty"`
+	P_8AZ        string `xml:",omitempty"`
+	P_8BZ        string `xml:",omitempty"`
+	P_9AZ        string `xml:",omitempty"`
+	P_11NettoZ   string `xml:",omitempty"`
+	P_11VatZ     string `xml:",omitempty"`
+	P_12Z        string `xml:",omitempty"`
+}
+
+type zamowienie struct {
+	WartoscZamowienia string
+	ZamowienieWiersz  []zamowienieWiersz
+}
+
+type daneFaKorygowanej struct {
+	DataWystFaKorygowanej string
+	NrFaKorygowanej       string
+	NrKSeF                string `xml:",omitempty"`
+	NrKSeFFaKorygowanej   string `xml:",omitempty"`
+	NrKSeFN               string `xml:",omitempty"`
+}
+
+type rachunekBankowy struct {
+	NrRB  string
+	SWIFT string `xml:",omitempty"`
+}
+
+type terminPlatnosci struct {
+	Termin string
+}
+
+type platnosc struct {
+	TerminPlatnosci *terminPlatnosci `xml:",omitempty"`
+	FormaPlatnosci  string           `xml:",omitempty"`
+	RachunekBankowy *rachunekBankowy `xml:",omitempty"`
+}
+
+type fa struct {
+	KodWaluty         string
+	P_1               string
+	P_1M              string `xml:",omitempty"`
+	P_2               string
+	P_6               string   `xml:",omitempty"`
+	OkresFa           *okresFa `xml:",omitempty"`
+	P_13_1            string   `xml:",omitempty"`
+	P_14_1            string   `xml:",omitempty"`
+	P_14_1W           string   `xml:",omitempty"`
+	P_13_2            string   `xml:",omitempty"`
+	P_14_2            string   `xml:",omitempty"`
+	P_14_2W           string   `xml:",omitempty"`
+	P_13_3            string   `xml:",omitempty"`
+	P_14_3            string   `xml:",omitempty"`
+	P_14_3W           string   `xml:",omitempty"`
+	P_13_6_1          string   `xml:",omitempty"`
+	P_13_6_2          string   `xml:",omitempty"`
+	P_13_6_3          string   `xml:",omitempty"`
+	P_13_7            string   `xml:",omitempty"`
+	P_13_8            string   `xml:",omitempty"`
+	P_13_10           string   `xml:",omitempty"`
+	P_15              string
+	Adnotacje         adnotacje
+	RodzajFaktury     string
+	PrzyczynaKorekty  string             `xml:",omitempty"`
+	TypKorekty        string             `xml:",omitempty"`
+	DaneFaKorygowanej *daneFaKorygowanej `xml:",omitempty"`
+	FakturaZaliczkowa []fakturaZaliczkowa
+	FaWiersz          []faWiersz
+	Platnosc          *platnosc   `xml:",omitempty"`
+	Zamowienie        *zamowienie `xml:",omitempty"`
+}
+
+type faktura struct {
+	XMLName  xml.Name `xml:"Faktura"`
+	Xmlns    string   `xml:"xmlns,attr"`
+	Naglowek naglowek
+	Podmiot1 podmiot1
+	Podmiot2 podmiot2
+	Fa       fa
+}
+
+var nonDigitRegexp = regexp.MustCompile(`\D`)
+
+/* ExportFA2 converts an invoice into a KSeF FA(2) document, Validate checks it against the official schema */
+func ExportFA2(invoice InvoiceManager.InvoiceCreatedData) ([]byte, error) {
+	if invoice.Kind() == InvoiceManager.DOCUMENT_KIND_PROFORMA {
+		return nil, fmt.Errorf("%s is a proforma, only VAT invoices go to KSeF", invoice.InvoiceNo)
+	}
+
+	document, err := buildFaktura(invoice)
+	if err != nil {
+		return nil, err
+	}
+
+	xmlData, err := xml.MarshalIndent(document, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+
+	return append([]byte(xml.Header), xmlData...), nil
+}
+
+func SaveFA2(invoice InvoiceManager.InvoiceCreatedData, filePath string) error {
+	xmlData, err := ExportFA2(invoice)
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(filePath, xmlData, 0644)
+}
+
+func buildFaktura(invoice InvoiceManager.InvoiceCreatedData) (faktura, error) {
+	var document faktura
+
+	dateOfIssue, err := toXmlDate(invoice.DateOfIssue)
+	if err != nil {
+		return document, fmt.Errorf("date of issue: %w", err)
+	}
+
+	document.Xmlns = FA2_NAMESPACE
+	document.Naglowek = naglowek{
+		KodFormularza:     kodFormularza{KodSystemowy: "FA (2)", WersjaSchemy: "1-0E", Value: "FA"},
+		WariantFormularza: "2",
+		DataWytworzeniaFa: time.Now().UTC().Format("2006-01-02T15:04:05Z"),
+		SystemInfo:        "moneybringer",
+	}
+
+	document.Podmiot1.DaneIdentyfikacyjne.NIP = SellerNip(invoice)
+	document.Podmiot1.DaneIdentyfikacyjne.Nazwa = invoice.InvoiceFrom.FullName
+	document.Podmiot1.Adres = adres{KodKraju: "PL", AdresL1: invoice.InvoiceFrom.Address}
+	if invoice.InvoiceFrom.Email != "" {
+		document.Podmiot1.DaneKontaktowe = &daneKontaktowe{Email: invoice.InvoiceFrom.Email}
+	}
+
+	document.Podmiot2 = buildPodmiot2(invoice)
+
+	document.Fa, err = buildFa(invoice, dateOfIssue)
+	if err != nil {
+		return document, err
+	}
+
+	return document, nil
+}
+
+/*
+buildPodmiot2 identifies the buyer by NIP, by the EU VAT number (KodUE and NrVatUE) or by
+a foreign tax number (KodKraju and NrID); consumers without one get BrakID.
+*/
+func buildPodmiot2(invoice InvoiceManager.InvoiceCreatedData) podmiot2 {
+	invoiceTo := invoice.InvoiceTo
+	address := InvoiceManager.BuyerAddressOf(invoice)
+	identification := daneIdentyfikacyjne2{Nazwa: invoiceTo.FullName}
+
+	switch {
+	case InvoiceManager.IsDomesticBuyer(invoice):
+		identification.NIP = invoiceTo.TaxNumber
+	case len(invoiceTo.VatEuNumber) > 2:
+		identification.KodUE = invoiceTo.VatEuNumber[:2]
+		identification.NrVatUE = invoiceTo.VatEuNumber[2:]
+	case invoiceTo.TaxNumber != "":
+		identification.KodKraju = address.CountryCode
+		identification.NrID = invoiceTo.TaxNumber
+	default:
+		identification.BrakID = "1"
+	}
+
+	buyer := podmiot2{DaneIdentyfikacyjne: identification}
+
+	if address.StreetAddress != "" {
+		buyer.Adres = &adres{
+			KodKraju: address.CountryCode,
+			AdresL1:  strings.TrimSpace(address.StreetAddress + " " + address.Number),
+			AdresL2:  strings.TrimSpace(address.ZipCode + " " + address.City),
+		}
+	}
+
+	if invoiceTo.Email != "" {
+		buyer.DaneKontaktowe = &daneKontaktowe{Email: invoiceTo.Email}
+	}
+
+	return buyer
+}
+
+func buildFa(invoice InvoiceManager.InvoiceCreatedData, dateOfIssue string) (fa, error) {
+	summary := invoice.InvoiceSummary
+	currency := InvoiceManager.CurrencyOf(invoice)
+
+	invoiceFa := fa{
+		KodWaluty:     currency,
+		P_1:           dateOfIssue,
+		P_1M:          invoice.PlaceOfIssue,
+		P_2:           invoice.InvoiceNo,
+		P_15:          summary.TotalGrossValue.String(),
+		RodzajFaktury: "VAT",
+	}
+
+	serviceStart, startErr := toXmlDate(invoice.ServiceStartDate)
+	serviceEnd, endErr := toXmlDate(invoice.ServiceEndDate)
+	if startErr == nil && endErr == nil {
+		invoiceFa.OkresFa = &okresFa{P_6_Od: serviceStart, P_6_Do: serviceEnd}
+	}
+
+	if err := setRateAmounts(&invoiceFa, summary.VatBreakdown, InvoiceManager.TransactionTypeOf(invoice)); err != nil {
+		return invoiceFa, err
+	}
+
+	if invoice.Conversion != nil {
+		setConvertedTaxAmounts(&invoiceFa, invoice.Conversion.Summary.VatBreakdown)
+	}
+
+	annotations, err := buildAdnotacje(invoice)
+	if err != nil {
+		return invoiceFa, err
+	}
+	invoiceFa.Adnotacje = annotations
+
+	if invoice.Correction != nil {
+		if err := setCorrection(&invoiceFa, invoice.Correction); err != nil {
+			return invoiceFa, err
+		}
+	}
+
+	switch {
+	case invoice.Advance != nil:
+		if err := setAdvance(&invoiceFa, invoice); err != nil {
+			return invoiceFa, err
+		}
+	case invoice.Settlement != nil:
+		setSettlement(&invoiceFa, invoice.Settlement)
+	}
+
+	/* the ordered positions of an advance invoice go to Zamowienie instead of FaWiersz */
+	if invoice.Advance == nil {
+		for _, position := range invoice.InvoicePositions {
+			invoiceFa.FaWiersz = append(invoiceFa.FaWiersz, buildFaWiersz(len(invoiceFa.FaWiersz)+1, position))
+		}
+	}
+
+	invoiceFa.Platnosc = buildPlatnosc(invoice)
+
+	return invoiceFa, nil
+}
+
+func buildFaWiersz(lineNo int, position Invoice.InvoicePosition) faWiersz {
+	return faWiersz{
+		NrWierszaFa: lineNo,
+		P_7:         position.ProductOrServiceName,
+		PKWiU:       position.PolishClassificationOfGoodsAndServices,
+		P_8A:        position.Unit,
+		P_8B:        strconv.Itoa(position.Quantity),
+		P_9A:        position.NetPrice.String(),
+		P_11:        position.NetValue.String(),
+		P_12:        string(position.TaxRate),
+	}
+}
+
+/*
+setCorrection marks the document as KOR, the amounts already hold the difference.
+Rows before correction are listed with StanPrzed, the rows after follow them.
+TypKorekty 2 books the correction on its own date of issue.
+*/
+func setCorrection(invoiceFa *fa, correction *InvoiceManager.InvoiceCorrection) error {
+	originalDate, err := toXmlDate(correction.OriginalDateOfIssue)
+	if err != nil {
+		return fmt.Errorf("date of corrected invoice: %w", err)
+	}
+
+	invoiceFa.RodzajFaktury = "KOR"
+	invoiceFa.PrzyczynaKorekty = correction.Reason
+	invoiceFa.TypKorekty = "2"
+	invoiceFa.DaneFaKorygowanej = &daneFaKorygowanej{DataWystFaKorygowanej: originalDate, NrFaKorygowanej: correction.OriginalInvoiceNo}
+
+	if correction.OriginalKsefReferenceNumber != "" {
+		invoiceFa.DaneFaKorygowanej.NrKSeF = "1"
+		invoiceFa.DaneFaKorygowanej.NrKSeFFaKorygowanej = correction.OriginalKsefReferenceNumber
+	} else {
+		invoiceFa.DaneFaKorygowanej.NrKSeFN = "1"
+	}
+
+	for _, position := range correction.PositionsBefore {
+		row := buildFaWiersz(len(invoiceFa.FaWiersz)+1, position)
+		row.StanPrzed = "1"
+		invoiceFa.FaWiersz = append(invoiceFa.FaWiersz, row)
+	}
+
+	return nil
+}
+
+/* setAdvance marks the document as ZAL, P_6 is the date the payment was received */
+func setAdvance(invoiceFa *fa, invoice InvoiceManager.InvoiceCreatedData) error {
+	paymentDate, err := toXmlDate(invoice.Advance.PaymentDate)
+	if err != nil {
+		return fmt.Errorf("advance payment date: %w", err)
+	}
+
+	invoiceFa.RodzajFaktury = "ZAL"
+	invoiceFa.P_6 = paymentDate
+	invoiceFa.OkresFa = nil
+	invoiceFa.Zamowienie = &zamowienie{WartoscZamowienia: invoice.Advance.OrderSummary.TotalGrossValue.String()}
+
+	for i, position := range invoice.InvoicePositions {
+		invoiceFa.Zamowienie.ZamowienieWiersz = append(invoiceFa.Zamowienie.ZamowienieWiersz, zamowienieWiersz{
+			NrWierszaZam: i + 1,
+			P_7Z:         position.ProductOrServiceName,
+			PKWiUZ:       position.PolishClassificationOfGoodsAndServices,
+			P_8AZ:        position.Unit,
+			P_8BZ:        strconv.Itoa(position.Quantity),
+			P_9AZ:        position.NetPrice.String(),
+			P_11NettoZ:   position.NetValue.String(),
+			P_11VatZ:     position.TaxAmount.String(),
+			P_12Z:        string(position.TaxRate),
+		})
+	}
+
+	return nil
+}
+
+/* setSettlement marks the document as ROZ and lists the settled advance invoices */
+func setSettlement(invoiceFa *fa, settlement *InvoiceManager.InvoiceSettlement) {
+	invoiceFa.RodzajFaktury = "ROZ"
+
+	for _, advance := range settlement.Advances {
+		if advance.KsefReferenceNumber != "" {
+			invoiceFa.FakturaZaliczkowa = append(invoiceFa.FakturaZaliczkowa, fakturaZaliczkowa{NrKSeFFaZaliczkowej: advance.KsefReferenceNumber})
+		} else {
+			invoiceFa.FakturaZaliczkowa = append(invoiceFa.FakturaZaliczkowa, fakturaZaliczkowa{NrKSeFZN: "1", NrFaZaliczkowej: advance.InvoiceNo})
+		}
+	}
+}
+
+/*
+setRateAmounts fills P_13_x (net) and P_14_x (VAT) fields from the per-rate summary.
+Sales at 0% go to P_13_6_1 (domestic), P_13_6_2 (intra-EU supply) or P_13_6_3 (export).
+*/
+func setRateAmounts(invoiceFa *fa, vatBreakdown []InvoiceManager.VatRateSummary, transactionType string) error {
+	zeroRateField := map[string]string{
+		InvoiceManager.TRANSACTION_DOMESTIC:        "P_13_6_1",
+		InvoiceManager.TRANSACTION_INTRA_EU_SUPPLY: "P_13_6_2",
+		InvoiceManager.TRANSACTION_EXPORT:          "P_13_6_3",
+	}[transactionType]
+
+	amounts := map[string]Money.Money{}
+	add := func(field string, amount Money.Money) {
+		amounts[field] = amounts[field].Add(amount)
+	}
+
+	for _, rateSummary := range vatBreakdown {
+		switch rateSummary.TaxRate {
+		case "23", "22":
+			add("P_13_1", rateSummary.NetValue)
+			add("P_14_1", rateSummary.TaxAmount)
+		case "8", "7":
+			add("P_13_2", rateSummary.NetValue)
+			add("P_14_2", rateSummary.TaxAmount)
+		case "5":
+			add("P_13_3", rateSummary.NetValue)
+			add("P_14_3", rateSummary.TaxAmount)
+		case "0":
+			add(zeroRateField, rateSummary.NetValue)
+		case TaxRate.EXEMPT:
+			add("P_13_7", rateSummary.NetValue)
+		case TaxRate.NOT_SUBJECT:
+			add("P_13_8", rateSummary.NetValue)
+		case TaxRate.REVERSE_CHARGE:
+			add("P_13_10", rateSummary.NetValue)
+		default:
+			return fmt.Errorf("tax rate %s is not supported by the FA(2) export", rateSummary.TaxRate.Label())
+		}
+	}
+
+	format := func(field string) string {
+		amount, exists := amounts[field]
+		if !exists {
+			return ""
+		}
+		return amount.String()
+	}
+
+	invoiceFa.P_13_1, invoiceFa.P_14_1 = format("P_13_1"), format("P_14_1")
+	invoiceFa.P_13_2, invoiceFa.P_14_2 = format("P_13_2"), format("P_14_2")
+	invoiceFa.P_13_3, invoiceFa.P_14_3 = format("P_13_3"), format("P_14_3")
+	invoiceFa.P_13_6_1, invoiceFa.P_13_6_2, invoiceFa.P_13_6_3 = format("P_13_6_1"), format("P_13_6_2"), format("P_13_6_3")
+	invoiceFa.P_13_7 = format("P_13_7")
+	invoiceFa.P_13_8 = format("P_13_8")
+	invoiceFa.P_13_10 = format("P_13_10")
+
+	return nil
+}
+
+/* setConvertedTaxAmounts fills P_14_xW, the VAT of a foreign currency invoice converted to PLN */
+func setConvertedTaxAmounts(invoiceFa *fa, vatBreakdown []InvoiceManager.VatRateSummary) {
+	amounts := map[string]Money.Money{}
+
+	for _, rateSummary := range vatBreakdown {
+		switch rateSummary.TaxRate {
+		case "23", "22":
+			amounts["P_14_1W"] = amounts["P_14_1W"].Add(rateSummary.TaxAmount)
+		case "8", "7":
+			amounts["P_14_2W"] = amounts["P_14_2W"].Add(rateSummary.TaxAmount)
+		case "5":
+			amounts["P_14_3W"] = amounts["P_14_3W"].Add(rateSummary.TaxAmount)
+		}
+	}
+
+	format := func(field string) string {
+		amount, exists := amounts[field]
+		if !exists {
+			return ""
+		}
+		return amount.String()
+	}
+
+	invoiceFa.P_14_1W = format("P_14_1W")
+	invoiceFa.P_14_2W = format("P_14_2W")
+	invoiceFa.P_14_3W = format("P_14_3W")
+}
+
+/* Adnotacje flags use 1 for "yes" and 2 for "no" */
+func buildAdnotacje(invoice InvoiceManager.InvoiceCreatedData) (adnotacje, error) {
+	annotations := adnotacje{P_16: "2", P_17: "2", P_18: "2", P_18A: "2", P_23: "2"}
+	annotations.NoweSrodkiTransportu.P_22N = "1"
+	annotations.PMarzy.P_PMarzyN = "1"
+	annotations.Zwolnienie.P_19N = "1"
+
+	var exemptionBases []string
+	for _, position := range invoice.InvoicePositions {
+		switch position.TaxRate {
+		case TaxRate.REVERSE_CHARGE:
+			annotations.P_18 = "1"
+		case TaxRate.EXEMPT:
+			if position.ExemptionBasis == "" {
+				return annotations, fmt.Errorf("position %d is VAT exempt but has no legal basis (P_19A)", position.ItemNo)
+			}
+			if !contains(exemptionBases, position.ExemptionBasis) {
+				exemptionBases = append(exemptionBases, position.ExemptionBasis)
+			}
+		}
+	}
+
+	if len(exemptionBases) > 0 {
+		annotations.Zwolnienie = zwolnienie{P_19: "1", P_19A: strings.Join(exemptionBases, "; ")}
+	}
+
+	return annotations, nil
+}
+
+func buildPlatnosc(invoice InvoiceManager.InvoiceCreatedData) *platnosc {
+	payment := &platnosc{FormaPlatnosci: paymentForm(invoice.Payment.Method)}
+
+	if deadline, err := toXmlDate(invoice.Payment.Deadline); err == nil {
+		payment.TerminPlatnosci = &terminPlatnosci{Termin: deadline}
+	}
+
+	if iban := strings.ReplaceAll(invoice.IBAN, " ", ""); iban != "" {
+		payment.RachunekBankowy = &rachunekBankowy{NrRB: iban, SWIFT: invoice.SWIFT}
+	}
+
+	return payment
+}
+
+/* paymentForm maps the payment method from company.json onto the FA(2) FormaPlatnosci codes */
+func paymentForm(method string) string {
+	method = strings.ToLower(method)
+
+	switch {
+	case strings.HasPrefix(method, "cash"):
+		return "1"
+	case strings.HasPrefix(method, "card"):
+		return "2"
+	case strings.HasPrefix(method, "transfer"):
+		return "6"
+	}
+
+	return ""
+}
+
+/* SellerNip returns the seller tax number without the PL prefix and separators, as KSeF expects it */
+func SellerNip(invoice InvoiceManager.InvoiceCreatedData) string {
+	return nonDigitRegexp.ReplaceAllString(invoice.InvoiceFrom.TaxNumber, "")
+}
+
+func toXmlDate(date string) (string, error) {
+	parsed, err := TimeUtils.ParseDdMmYyyy(date)
+	if err != nil {
+		return "", err
+	}
+
+	return parsed.Format("2006-01-02"), nil
+}
+
+func contains(values []string, value string) bool {
+	for _, existing := range values {
+		if existing == value {
+			return true
+		}
+	}
+
+	return false
+}
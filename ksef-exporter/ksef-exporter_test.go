@@ -0,0 +1,176 @@
+package KsefExporter_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	InvoiceManager "moneybringer/invoice-manager"
+	KsefExporter "moneybringer/ksef-exporter"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+/* go test ./ksef-exporter -update rewrites the golden XML files after an intended change of the exporter */
+var update = flag.Bool("update", false, "rewrite testdata/*.xml from the exported invoices")
+
+/* DataWytworzeniaFa is the time of the export, it is the only value differing between runs */
+var creationTimeRegexp = regexp.MustCompile(`<DataWytworzeniaFa>[^<]*</DataWytworzeniaFa>`)
+
+const fixedCreationTime = "<DataWytworzeniaFa>2026-10-09T12:00:00Z</DataWytworzeniaFa>"
+
+/* goldenInvoices are stored invoices in testdata, each with the FA(2) XML expected for it */
+func goldenInvoices(t *testing.T) []string {
+	t.Helper()
+
+	paths, err := filepath.Glob("testdata/*.json")
+	if err != nil || len(paths) == 0 {
+		t.Fatalf("no invoices in testdata: %v", err)
+	}
+
+	return paths
+}
+
+func exportInvoice(t *testing.T, path string) []byte {
+	t.Helper()
+
+	jsonData, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var invoice InvoiceManager.InvoiceCreatedData
+	if err := json.Unmarshal(jsonData, &invoice); err != nil {
+		t.Fatalf("%s: %v", path, err)
+	}
+
+	xmlData, err := KsefExporter.ExportFA2(invoice)
+	if err != nil {
+		t.Fatalf("ExportFA2(%s): %v", path, err)
+	}
+
+	return creationTimeRegexp.ReplaceAll(xmlData, []byte(fixedCreationTime))
+}
+
+func TestExportFA2MatchesGoldenFiles(t *testing.T) {
+	for _, path := range goldenInvoices(t) {
+		goldenPath := strings.TrimSuffix(path, ".json") + ".xml"
+
+		t.Run(filepath.Base(goldenPath), func(t *testing.T) {
+			xmlData := exportInvoice(t, path)
+
+			if *update {
+				if err := os.WriteFile(goldenPath, xmlData, 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			golden, err := os.ReadFile(goldenPath)
+			if err != nil {
+				t.Fatalf("%v (run with -update to create it)", err)
+			}
+			if !bytes.Equal(xmlData, golden) {
+				t.Errorf("exported XML differs from %s (run with -update after an intended change):\n%s", goldenPath, xmlData)
+			}
+		})
+	}
+}
+
+/* requireSchema skips validation tests where the official schema or xmllint is not available */
+func requireSchema(t *testing.T) {
+	t.Helper()
+
+	err := KsefExporter.Validate(nil)
+	if errors.Is(err, KsefExporter.ErrSchemaNotBundled) || errors.Is(err, KsefExporter.ErrValidatorMissing) {
+		t.Skip(err)
+	}
+}
+
+func TestGoldenFilesMatchOfficialSchema(t *testing.T) {
+	requireSchema(t)
+
+	for _, path := range goldenInvoices(t) {
+		goldenPath := strings.TrimSuffix(path, ".json") + ".xml"
+
+		golden, err := os.ReadFile(goldenPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := KsefExporter.Validate(golden); err != nil {
+			t.Errorf("%s: %v", goldenPath, err)
+		}
+	}
+}
+
+func TestValidateRejectsInvalidDocuments(t *testing.T) {
+	requireSchema(t)
+
+	valid := exportInvoice(t, "testdata/invoice-vat-rates.json")
+
+	documents := map[string][]byte{
+		"missing invoice number": regexp.MustCompile(`\s*<P_2>[^<]*</P_2>`).ReplaceAll(valid, nil),
+		"invalid seller NIP":     bytes.Replace(valid, []byte("<NIP>2222222222</NIP>"), []byte("<NIP>0222222222</NIP>"), 1),
+		"invalid date of issue":  regexp.MustCompile(`<P_1>[^<]*</P_1>`).ReplaceAll(valid, []byte("<P_1>09-10-2026</P_1>")),
+		"unknown element":        bytes.Replace(valid, []byte("<P_2>"), []byte("<Unknown>x</Unknown><P_2>"), 1),
+		"elements out of order":  regexp.MustCompile(`(<P_1>[^<]*</P_1>)(\s*)(<P_1M>[^<]*</P_1M>)`).ReplaceAll(valid, []byte("$3$2$1")),
+		"amount with 3 decimals": regexp.MustCompile(`<P_13_1>[^<]*</P_13_1>`).ReplaceAll(valid, []byte("<P_13_1>1.005</P_13_1>")),
+	}
+
+	for name, document := range documents {
+		t.Run(name, func(t *testing.T) {
+			if bytes.Equal(document, valid) {
+				t.Fatal("the test document was not changed, the golden XML has a different shape")
+			}
+
+			var validationErr *KsefExporter.ValidationError
+			if err := KsefExporter.Validate(document); !errors.As(err, &validationErr) {
+				t.Errorf("Validate of a document with %s = %v, want a ValidationError", name, err)
+			}
+		})
+	}
+}
+
+func TestZeroRateFieldFollowsTransactionType(t *testing.T) {
+	jsonData, err := os.ReadFile("testdata/invoice-wdt.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		name        string
+		vatEuNumber string
+		taxNumber   string
+		countryCode string
+		field       string
+	}{
+		{"domestic", "", "7781234563", "PL", "P_13_6_1"},
+		{"intra-EU supply", "DE123456789", "", "DE", "P_13_6_2"},
+		{"export", "", "98-7654321", "US", "P_13_6_3"},
+		{"EU buyer without EU VAT number", "", "", "DE", "P_13_6_1"},
+	}
+
+	for _, c := range cases {
+		var invoice InvoiceManager.InvoiceCreatedData
+		if err := json.Unmarshal(jsonData, &invoice); err != nil {
+			t.Fatal(err)
+		}
+		invoice.InvoiceTo.VatEuNumber = c.vatEuNumber
+		invoice.InvoiceTo.TaxNumber = c.taxNumber
+		invoice.InvoiceTo.Address.CountryCode = c.countryCode
+
+		xmlData, err := KsefExporter.ExportFA2(invoice)
+		if err != nil {
+			t.Fatalf("%s: %v", c.name, err)
+		}
+
+		for _, field := range []string{"P_13_6_1", "P_13_6_2", "P_13_6_3"} {
+			expected := field == c.field
+			if found := bytes.Contains(xmlData, []byte("<"+field+">10000.00</"+field+">")); found != expected {
+				t.Errorf("%s: %s present = %v, want %v", c.name, field, found, expected)
+			}
+		}
+	}
+}
@@ -0,0 +1,119 @@
+package KsefExporter
+
+import (
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+/*
+schema holds the official FA(2) schema (schemat.xsd) with the schemas it imports and
+catalog.xml mapping their URLs onto the files, as downloaded by schema/fetch-schema.sh
+*/
+//go:embed schema
+var schema embed.FS
+
+const SCHEMA_FILE = "schemat.xsd"
+
+var ErrSchemaNotBundled = errors.New("the official FA(2) schema is not bundled, run ksef-exporter/schema/fetch-schema.sh and rebuild")
+
+var ErrValidatorMissing = errors.New("FA(2) validation needs xmllint from libxml2 (libxml2-utils) on the PATH")
+
+/* xmllint exits with 3 or 4 when the document is not valid, other codes are failures of xmllint itself */
+const xmllintInvalidDocument = 3
+const xmllintInvalidStructure = 4
+
+/* ValidationError lists the schema violations of an FA(2) document */
+type ValidationError struct {
+	Problems []string
+}
+
+func (e *ValidationError) Error() string {
+	return fmt.Sprintf("FA(2) document does not match the official schema:\n  - %s", strings.Join(e.Problems, "\n  - "))
+}
+
+/*
+Validate checks an FA(2) document against the bundled official schema with xmllint,
+offline: the imported StrukturyDanych and ElementarneTypyDanych definitions are resolved
+through the bundled catalog and xmllint is not allowed to use the network.
+*/
+func Validate(document []byte) error {
+	if _, err := fs.Stat(schema, path.Join("schema", SCHEMA_FILE)); err != nil {
+		return ErrSchemaNotBundled
+	}
+
+	xmllint, err := exec.LookPath("xmllint")
+	if err != nil {
+		return ErrValidatorMissing
+	}
+
+	dir, err := os.MkdirTemp("", "moneybringer-fa2-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+
+	if err := copySchema(dir); err != nil {
+		return err
+	}
+
+	documentPath := filepath.Join(dir, "document.xml")
+	if err := os.WriteFile(documentPath, document, 0644); err != nil {
+		return err
+	}
+
+	command := exec.Command(xmllint, "--nonet", "--noout", "--schema", filepath.Join(dir, SCHEMA_FILE), documentPath)
+	command.Env = append(os.Environ(), "XML_CATALOG_FILES="+filepath.Join(dir, "catalog.xml"))
+	output, err := command.CombinedOutput()
+	if err == nil {
+		return nil
+	}
+
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) && (exitErr.ExitCode() == xmllintInvalidDocument || exitErr.ExitCode() == xmllintInvalidStructure) {
+		return &ValidationError{Problems: xmllintProblems(string(output), documentPath)}
+	}
+
+	return fmt.Errorf("xmllint failed: %w\n%s", err, output)
+}
+
+func copySchema(dir string) error {
+	return fs.WalkDir(schema, "schema", func(name string, entry fs.DirEntry, err error) error {
+		if err != nil || entry.IsDir() {
+			return err
+		}
+
+		data, err := schema.ReadFile(name)
+		if err != nil {
+			return err
+		}
+
+		return os.WriteFile(filepath.Join(dir, path.Base(name)), data, 0644)
+	})
+}
+
+/* xmllintProblems keeps the messages of xmllint without the temporary file name and its summary line */
+func xmllintProblems(output string, documentPath string) []string {
+	var problems []string
+
+	for _, line := range strings.Split(strings.TrimSpace(output), "\n") {
+		if line == "" || strings.HasSuffix(line, " fails to validate") {
+			continue
+		}
+
+		/* /tmp/.../document.xml:12: Schemas validity error : Element ... */
+		lineNo, message, _ := strings.Cut(strings.TrimPrefix(line, documentPath+":"), ": ")
+		if _, violation, found := strings.Cut(message, "validity error : "); found {
+			line = fmt.Sprintf("line %s: %s", lineNo, violation)
+		}
+		problems = append(problems, line)
+	}
+
+	return problems
+}
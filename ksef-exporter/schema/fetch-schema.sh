@@ -0,0 +1,52 @@
+#!/usr/bin/env bash
+# Downloads the official KSeF FA(2) schema with every schema it imports (StrukturyDanych,
+# ElementarneTypyDanych, KodyKrajow) into this directory and writes catalog.xml, which maps
+# the import URLs onto the downloaded files, so validation never goes to the network.
+# The files are built into the binary, commit them and rebuild after running this script.
+set -euo pipefail
+
+cd "$(dirname "$0")"
+
+SCHEMA_URL="https://crd.gov.pl/wzor/2023/06/29/12648/schemat.xsd"
+
+declare -A fetched
+files=()
+queue=("$SCHEMA_URL")
+entries=""
+
+while ((${#queue[@]} > 0)); do
+  url=${queue[0]}
+  queue=("${queue[@]:1}")
+  file=${url##*/}
+
+  if [[ -n ${fetched[$url]:-} ]]; then
+    continue
+  fi
+  fetched[$url]=$file
+  entries+="  <uri name=\"$url\" uri=\"$file\"/>"$'\n'"  <system systemId=\"$url\" uri=\"$file\"/>"$'\n'
+
+  # the same schema may be imported over http and https
+  if [[ " ${files[*]} " == *" $file "* ]]; then
+    continue
+  fi
+  files+=("$file")
+
+  echo "Fetching $url"
+  curl --fail --silent --show-error --location --retry 3 --output "$file" "$url"
+
+  for location in $(grep -o 'schemaLocation="[^"]*"' "$file" | sed 's/^schemaLocation="//; s/"$//'); do
+    case $location in
+      http://* | https://*) queue+=("$location") ;;
+      *) queue+=("${url%/*}/$location") ;;
+    esac
+  done
+done
+
+{
+  echo '<?xml version="1.0" encoding="UTF-8"?>'
+  echo '<catalog xmlns="urn:oasis:names:tc:entity:xmlns:xml:catalog">'
+  printf '%s' "$entries"
+  echo '</catalog>'
+} > catalog.xml
+
+echo "Saved ${#files[@]} schema files and catalog.xml in $(pwd)"
@@ -0,0 +1,123 @@
+package Tui
+
+import (
+	"fmt"
+	CustomerData "moneybringer/invoice-manager/customer"
+	"sort"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+/* customerPicker lists the customers of customers.json, typing filters by key and full name */
+type customerPicker struct {
+	customers map[string]CustomerData.Customer
+	filter    textinput.Model
+	keys      []string
+	matches   []string
+	cursor    int
+}
+
+func newCustomerPicker(customers map[string]CustomerData.Customer, selected string) customerPicker {
+	filter := textinput.New()
+	filter.Prompt = "Filter: "
+	filter.Placeholder = "type a key or a name"
+	filter.Focus()
+
+	keys := make([]string, 0, len(customers))
+	for key := range customers {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	picker := customerPicker{customers: customers, filter: filter, keys: keys}
+	picker.refilter()
+
+	for i, key := range picker.matches {
+		if key == selected {
+			picker.cursor = i
+		}
+	}
+
+	return picker
+}
+
+func (p *customerPicker) refilter() {
+	needle := strings.ToLower(strings.TrimSpace(p.filter.Value()))
+
+	p.matches = p.matches[:0]
+	for _, key := range p.keys {
+		if strings.Contains(strings.ToLower(key), needle) || strings.Contains(strings.ToLower(p.customers[key].FullName), needle) {
+			p.matches = append(p.matches, key)
+		}
+	}
+
+	p.cursor = min(p.cursor, max(len(p.matches)-1, 0))
+}
+
+/* selected is the key under the cursor, empty when nothing matches the filter */
+func (p customerPicker) selected() string {
+	if len(p.matches) == 0 {
+		return ""
+	}
+
+	return p.matches[p.cursor]
+}
+
+func (p customerPicker) update(msg tea.Msg) (customerPicker, tea.Cmd) {
+	if key, ok := msg.(tea.KeyMsg); ok {
+		switch key.String() {
+		case "up":
+			p.cursor = max(p.cursor-1, 0)
+			return p, nil
+		case "down":
+			p.cursor = min(p.cursor+1, max(len(p.matches)-1, 0))
+			return p, nil
+		}
+	}
+
+	var cmd tea.Cmd
+	p.filter, cmd = p.filter.Update(msg)
+	p.refilter()
+
+	return p, cmd
+}
+
+func (p customerPicker) view() string {
+	var view strings.Builder
+
+	view.WriteString(p.filter.View() + "\n\n")
+
+	if len(p.matches) == 0 {
+		view.WriteString(errorStyle.Render("No customer matches, add one with: moneybringer customers add") + "\n")
+		return view.String()
+	}
+
+	for i, key := range p.matches {
+		line := fmt.Sprintf("%-20s %s", key, p.customers[key].FullName)
+		if i == p.cursor {
+			view.WriteString(selectedStyle.Render("> "+line) + "\n")
+		} else {
+			view.WriteString("  " + line + "\n")
+		}
+	}
+
+	customer := p.customers[p.selected()]
+	view.WriteString("\n" + labelStyle.Render(strings.Join(customerDetails(customer), "  ·  ")) + "\n")
+
+	return view.String()
+}
+
+func customerDetails(customer CustomerData.Customer) []string {
+	details := []string{strings.TrimSpace(customer.Address.ZipCode + " " + customer.Address.City + ", " + customer.Address.CountryCode)}
+
+	if customer.TaxNumber != "" {
+		details = append(details, "NIP "+customer.TaxNumber)
+	}
+	if customer.VatEuNumber != "" {
+		details = append(details, "VAT EU "+customer.VatEuNumber)
+	}
+
+	return details
+}
@@ -0,0 +1,119 @@
+package Tui
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+/* field is one labelled input of a form, validate may be nil for free text */
+type field struct {
+	label    string
+	input    textinput.Model
+	validate func(value string) error
+	err      error
+}
+
+/* form is a column of fields, tab and the arrows move between them, other keys go to the focused input */
+type form struct {
+	fields []field
+	focus  int
+}
+
+func newField(label string, value string, validate func(value string) error) field {
+	input := textinput.New()
+	input.Prompt = ""
+	input.Width = 48
+	input.SetValue(value)
+
+	return field{label: label, input: input, validate: validate}
+}
+
+func newForm(fields ...field) form {
+	f := form{fields: fields}
+	f.focusField(0)
+
+	return f
+}
+
+func (f *form) focusField(index int) tea.Cmd {
+	f.fields[f.focus].input.Blur()
+	f.focus = (index + len(f.fields)) % len(f.fields)
+
+	return f.fields[f.focus].input.Focus()
+}
+
+func (f form) update(msg tea.Msg) (form, tea.Cmd) {
+	if key, ok := msg.(tea.KeyMsg); ok {
+		switch key.String() {
+		case "tab", "down":
+			return f, f.focusField(f.focus + 1)
+		case "shift+tab", "up":
+			return f, f.focusField(f.focus - 1)
+		}
+	}
+
+	var cmd tea.Cmd
+	focused := &f.fields[f.focus]
+	focused.input, cmd = focused.input.Update(msg)
+	focused.err = nil
+
+	return f, cmd
+}
+
+/* valid checks every field and focuses the first invalid one */
+func (f *form) valid() bool {
+	firstInvalid := -1
+
+	for i := range f.fields {
+		current := &f.fields[i]
+		current.err = nil
+		if current.validate != nil {
+			current.err = current.validate(strings.TrimSpace(current.input.Value()))
+		}
+		if current.err != nil && firstInvalid < 0 {
+			firstInvalid = i
+		}
+	}
+
+	if firstInvalid >= 0 {
+		f.focusField(firstInvalid)
+		return false
+	}
+
+	return true
+}
+
+func (f form) value(index int) string {
+	return strings.TrimSpace(f.fields[index].input.Value())
+}
+
+func (f *form) setValue(index int, value string) {
+	f.fields[index].input.SetValue(value)
+}
+
+func (f form) view() string {
+	labelWidth := 0
+	for _, current := range f.fields {
+		labelWidth = max(labelWidth, len(current.label))
+	}
+
+	var view strings.Builder
+	for i, current := range f.fields {
+		label := fmt.Sprintf("%-*s", labelWidth, current.label)
+		if i == f.focus {
+			view.WriteString(focusedStyle.Render("> " + label))
+		} else {
+			view.WriteString(labelStyle.Render("  " + label))
+		}
+		view.WriteString("  " + current.input.View())
+		if current.err != nil {
+			view.WriteString("  " + errorStyle.Render(current.err.Error()))
+		}
+		view.WriteString("\n")
+	}
+
+	return view.String()
+}
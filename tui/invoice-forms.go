@@ -0,0 +1,171 @@
+package Tui
+
+import (
+	"errors"
+	"fmt"
+	InvoiceManager "moneybringer/invoice-manager"
+	Invoice "moneybringer/invoice-manager/invoice"
+	InvoiceSpec "moneybringer/invoice-manager/spec"
+	TaxRate "moneybringer/invoice-manager/tax-rate"
+	Money "moneybringer/utils/money"
+	TimeUtils "moneybringer/utils/time"
+	"strconv"
+	"strings"
+)
+
+/* field indexes of the header form */
+const (
+	headerDateOfIssue = iota
+	headerServiceStartDate
+	headerServiceEndDate
+	headerPaymentDeadline
+	headerPlaceOfIssue
+	headerCurrency
+	headerNotes
+)
+
+/* field indexes of the position form */
+const (
+	positionProduct = iota
+	positionUnit
+	positionQuantity
+	positionNetPrice
+	positionTaxRate
+	positionExemptionBasis
+	positionClassification
+)
+
+var errRequired = errors.New("required")
+
+/* notesSeparator joins the notes in one input, the invoice prints them joined with ", " */
+const notesSeparator = "; "
+
+func newHeaderForm(spec InvoiceSpec.Spec) form {
+	return newForm(
+		newField("Date of issue", spec.DateOfIssue, validDate),
+		newField("Service start date", spec.ServiceStartDate, validDate),
+		newField("Service end date", spec.ServiceEndDate, validDate),
+		newField("Payment deadline", spec.PaymentDeadline, validDate),
+		newField("Place of issue", spec.PlaceOfIssue, nil),
+		newField("Currency", spec.Currency, validCurrency),
+		newField("Notes (separated by ;)", strings.Join(spec.Notes, notesSeparator), nil),
+	)
+}
+
+func (e *editor) applyHeader() {
+	e.spec.DateOfIssue = e.header.value(headerDateOfIssue)
+	e.spec.ServiceStartDate = e.header.value(headerServiceStartDate)
+	e.spec.ServiceEndDate = e.header.value(headerServiceEndDate)
+	e.spec.PaymentDeadline = e.header.value(headerPaymentDeadline)
+	e.spec.PlaceOfIssue = e.header.value(headerPlaceOfIssue)
+	e.spec.Currency, _ = InvoiceManager.ParseCurrency(e.header.value(headerCurrency))
+
+	e.spec.Notes = nil
+	for _, note := range strings.Split(e.header.value(headerNotes), ";") {
+		if strings.TrimSpace(note) != "" {
+			e.spec.Notes = append(e.spec.Notes, strings.TrimSpace(note))
+		}
+	}
+}
+
+func newPositionForm(position InvoiceSpec.PositionSpec) form {
+	quantity, netPrice, taxRate := "", "", ""
+	if position.Quantity != nil {
+		quantity = strconv.Itoa(*position.Quantity)
+	}
+	if position.NetPrice != nil {
+		netPrice = string(*position.NetPrice)
+	}
+	if position.TaxRate != nil {
+		taxRate = string(*position.TaxRate)
+	}
+
+	return newForm(
+		newField("Product or service", position.Product, required),
+		newField("Unit", position.Unit, nil),
+		newField("Quantity", quantity, validQuantity),
+		newField("Net price", netPrice, validNetPrice),
+		newField("Tax rate (23, 8, 5, 0, zw, np, oo)", taxRate, validTaxRate),
+		newField("Legal basis for zw/np", position.ExemptionBasis, nil),
+		newField("PKWiU classification", position.PolishClassificationOfGoodsAndServices, nil),
+	)
+}
+
+/* positionOf reads a position form that passed valid */
+func positionOf(f form) InvoiceSpec.PositionSpec {
+	quantity, _ := strconv.Atoi(f.value(positionQuantity))
+	netPrice, _ := Money.Parse(f.value(positionNetPrice), "")
+	taxRate, _ := TaxRate.Parse(f.value(positionTaxRate))
+
+	return InvoiceSpec.PositionSpec{
+		Product:                                f.value(positionProduct),
+		Unit:                                   f.value(positionUnit),
+		Quantity:                               &quantity,
+		NetPrice:                               InvoiceSpec.AmountOf(netPrice),
+		TaxRate:                                &taxRate,
+		ExemptionBasis:                         f.value(positionExemptionBasis),
+		PolishClassificationOfGoodsAndServices: f.value(positionClassification),
+	}
+}
+
+/* positionPreview recalculates the position while it is typed, as long as the numbers parse */
+func positionPreview(f form, currency string) string {
+	quantity, quantityErr := strconv.Atoi(f.value(positionQuantity))
+	netPrice, netPriceErr := Money.Parse(f.value(positionNetPrice), currency)
+	taxRate, taxRateErr := TaxRate.Parse(f.value(positionTaxRate))
+
+	if quantityErr != nil || netPriceErr != nil || taxRateErr != nil {
+		return labelStyle.Render("Net, VAT and gross appear once quantity, net price and tax rate are valid")
+	}
+
+	position := Invoice.NewInvoicePosition(0, "", "", "", quantity, netPrice, taxRate, currency)
+
+	return totalStyle.Render(fmt.Sprintf("Net %s · VAT %s %s · Gross %s %s", position.NetValue, taxRate.Label(), position.TaxAmount, position.GrossValue, currency))
+}
+
+func required(value string) error {
+	if value == "" {
+		return errRequired
+	}
+
+	return nil
+}
+
+func validDate(value string) error {
+	if _, err := TimeUtils.ParseDdMmYyyy(value); err != nil {
+		return errors.New("expected DD-MM-YYYY")
+	}
+
+	return nil
+}
+
+func validCurrency(value string) error {
+	_, err := InvoiceManager.ParseCurrency(value)
+	if err != nil {
+		return errors.New("expected a code such as PLN or EUR")
+	}
+
+	return nil
+}
+
+func validQuantity(value string) error {
+	if quantity, err := strconv.Atoi(value); err != nil || quantity <= 0 {
+		return errors.New("expected a whole number above 0")
+	}
+
+	return nil
+}
+
+func validNetPrice(value string) error {
+	if amount, err := Money.Parse(value, ""); err != nil || amount.Amount < 0 {
+		return errors.New("expected an amount such as 120.50")
+	}
+
+	return nil
+}
+
+func validTaxRate(value string) error {
+	_, err := TaxRate.Parse(value)
+
+	return err
+}
@@ -0,0 +1,88 @@
+package Tui
+
+import (
+	"fmt"
+	InvoiceManager "moneybringer/invoice-manager"
+	CompanyData "moneybringer/invoice-manager/company"
+	Invoice "moneybringer/invoice-manager/invoice"
+	InvoiceSpec "moneybringer/invoice-manager/spec"
+	"strings"
+)
+
+/* positionsTable shows the positions of the spec with the totals recalculated on every change */
+type positionsTable struct {
+	cursor int
+}
+
+const positionRowFormat = "%3s  %-30s %6s %-6s %10s %5s %12s %10s %12s"
+
+func (t positionsTable) view(spec InvoiceSpec.Spec, companyData CompanyData.Company) string {
+	var view strings.Builder
+
+	view.WriteString(positionsHeading())
+
+	if len(spec.Positions) == 0 {
+		view.WriteString(labelStyle.Render("  No positions yet, press a to add one") + "\n")
+	}
+
+	for i, positionSpec := range spec.Positions {
+		/* positions in the table passed the form check, their net price parses */
+		netPrice, _ := positionSpec.NetPrice.Money("")
+		position := Invoice.NewInvoicePosition(i+1, positionSpec.Product, "", positionSpec.Unit, *positionSpec.Quantity, netPrice, *positionSpec.TaxRate, spec.Currency)
+		row := positionRow(position)
+
+		if i == t.cursor {
+			view.WriteString(selectedStyle.Render(row) + "\n")
+		} else {
+			view.WriteString(row + "\n")
+		}
+	}
+
+	view.WriteString("\n" + summaryView(spec, companyData))
+
+	return view.String()
+}
+
+func positionsHeading() string {
+	return headingStyle.Render(fmt.Sprintf(positionRowFormat, "No", "Product or service", "Qty", "Unit", "Net price", "VAT", "Net value", "VAT", "Gross")) + "\n"
+}
+
+func positionRow(position Invoice.InvoicePosition) string {
+	return fmt.Sprintf(positionRowFormat, fmt.Sprint(position.ItemNo), truncate(position.ProductOrServiceName, 30), fmt.Sprint(position.Quantity), truncate(position.Unit, 6), position.NetPrice, position.TaxRate.Label(), position.NetValue, position.TaxAmount, position.GrossValue)
+}
+
+/* summaryView rounds VAT like the issued invoice, per rate or per line as vatRounding in company.json says */
+func summaryView(spec InvoiceSpec.Spec, companyData CompanyData.Company) string {
+	if len(spec.Positions) == 0 {
+		return ""
+	}
+
+	summary, err := InvoiceManager.SpecSummary(spec, companyData)
+	if err != nil {
+		return errorStyle.Render(err.Error()) + "\n"
+	}
+
+	return vatBreakdownView(summary)
+}
+
+func vatBreakdownView(summary InvoiceManager.InvoiceSummary) string {
+	var view strings.Builder
+
+	for _, rate := range summary.VatBreakdown {
+		view.WriteString(labelStyle.Render(fmt.Sprintf("%-5s net %12s   VAT %10s   gross %12s", rate.TaxRate.Label(), rate.NetValue, rate.TaxAmount, rate.GrossValue)) + "\n")
+	}
+
+	currency := summary.TotalGrossValue.Currency
+	view.WriteString(totalStyle.Render(fmt.Sprintf("Total net %s, VAT %s, gross %s %s", summary.TotalAmount, summary.TotalTaxAmount, summary.TotalGrossValue, currency)) + "\n")
+
+	return view.String()
+}
+
+func truncate(value string, width int) string {
+	runes := []rune(value)
+	if len(runes) <= width {
+		return value
+	}
+
+	return string(runes[:width-1]) + "…"
+}
@@ -0,0 +1,40 @@
+package Tui
+
+import (
+	"fmt"
+	InvoiceManager "moneybringer/invoice-manager"
+	"strings"
+)
+
+/* reviewView shows the invoice as it will be saved, like the show command prints stored invoices */
+func reviewView(invoice InvoiceManager.InvoiceCreatedData) string {
+	var view strings.Builder
+
+	buyer := invoice.InvoiceTo
+	buyerAddress := strings.TrimSpace(fmt.Sprintf("%s %s, %s %s", buyer.Address.StreetAddress, buyer.Address.Number, buyer.Address.ZipCode, buyer.Address.City))
+
+	for _, line := range [][2]string{
+		{"Invoice", invoice.InvoiceNo + labelStyle.Render(" (preview, assigned when saved)")},
+		{"Date of issue", fmt.Sprintf("%s, %s", invoice.DateOfIssue, invoice.PlaceOfIssue)},
+		{"Service period", fmt.Sprintf("%s - %s", invoice.ServiceStartDate, invoice.ServiceEndDate)},
+		{"Seller", fmt.Sprintf("%s (%s)", invoice.InvoiceFrom.FullName, invoice.InvoiceFrom.TaxNumber)},
+		{"Buyer", fmt.Sprintf("%s, %s", buyer.FullName, buyerAddress)},
+		{"Payment", fmt.Sprintf("%s, due %s", invoice.Payment.Method, invoice.Payment.Deadline)},
+		{"Bank account", invoice.IBAN},
+		{"Notes", invoice.Notes},
+	} {
+		if line[1] != "" {
+			view.WriteString(labelStyle.Render(fmt.Sprintf("%-15s ", line[0]+":")) + line[1] + "\n")
+		}
+	}
+
+	view.WriteString("\n" + positionsHeading())
+	for _, position := range invoice.InvoicePositions {
+		view.WriteString(positionRow(position) + "\n")
+	}
+
+	view.WriteString("\n" + vatBreakdownView(invoice.InvoiceSummary))
+	view.WriteString(labelStyle.Render("In words: "+invoice.InvoiceSummary.GrossInWords) + "\n")
+
+	return view.String()
+}
@@ -0,0 +1,12 @@
+package Tui
+
+import "github.com/charmbracelet/lipgloss"
+
+var titleStyle = lipgloss.NewStyle().Bold(true).Foreground(lipgloss.Color("230")).Background(lipgloss.Color("62")).Padding(0, 1)
+var headingStyle = lipgloss.NewStyle().Bold(true).Foreground(lipgloss.Color("62"))
+var labelStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("245"))
+var focusedStyle = lipgloss.NewStyle().Bold(true).Foreground(lipgloss.Color("212"))
+var selectedStyle = lipgloss.NewStyle().Bold(true).Foreground(lipgloss.Color("230")).Background(lipgloss.Color("238"))
+var totalStyle = lipgloss.NewStyle().Bold(true)
+var errorStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("203"))
+var helpStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("241"))
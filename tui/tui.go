@@ -0,0 +1,325 @@
+package Tui
+
+import (
+	"fmt"
+	InvoiceManager "moneybringer/invoice-manager"
+	CompanyData "moneybringer/invoice-manager/company"
+	CustomerData "moneybringer/invoice-manager/customer"
+	InvoiceSpec "moneybringer/invoice-manager/spec"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+/*
+Full-screen editor of a new invoice: customer picker, header form, positions table with
+live totals and a review screen. It only edits an InvoiceSpec, the caller creates, numbers
+and stores the invoice from it like from a spec given with --input.
+*/
+
+type screen int
+
+const (
+	screenCustomer screen = iota
+	screenHeader
+	screenPositions
+	screenPosition
+	screenReview
+)
+
+type editor struct {
+	companyData CompanyData.Company
+	spec        InvoiceSpec.Spec
+	screen      screen
+	picker      customerPicker
+	header      form
+	/* deadline offered for the date of issue, followed while the user does not change it */
+	defaultDeadline string
+	positions       positionsTable
+	position        form
+	/* index of the position in the position form, len(spec.Positions) for a new one */
+	editing int
+	preview InvoiceManager.InvoiceCreatedData
+	message string
+	saved   bool
+}
+
+/* EditInvoice runs the editor on the terminal and returns the spec, false when the user left without saving */
+func EditInvoice(companyData CompanyData.Company, customersData CustomerData.CustomersData, customerKey string) (InvoiceSpec.Spec, bool, error) {
+	if len(customersData.Customers) == 0 {
+		return InvoiceSpec.Spec{}, false, fmt.Errorf("%w: customers.json has no customers", CustomerData.ErrCustomerNotFound)
+	}
+
+	final, err := tea.NewProgram(newEditor(companyData, customersData, customerKey), tea.WithAltScreen()).Run()
+	if err != nil {
+		return InvoiceSpec.Spec{}, false, err
+	}
+
+	result := final.(editor)
+
+	return result.spec, result.saved, nil
+}
+
+/* newEditor starts with the customer picker, or with the header when customerKey names a customer */
+func newEditor(companyData CompanyData.Company, customersData CustomerData.CustomersData, customerKey string) editor {
+	spec := InvoiceManager.DefaultSpec(companyData, "")
+
+	e := editor{
+		companyData:     companyData,
+		spec:            spec,
+		picker:          newCustomerPicker(customersData.Customers, customerKey),
+		header:          newHeaderForm(spec),
+		defaultDeadline: spec.PaymentDeadline,
+	}
+
+	if _, exists := customersData.Customers[customerKey]; exists {
+		e.spec.Customer = customerKey
+		e.screen = screenHeader
+	}
+
+	return e
+}
+
+func (e editor) Init() tea.Cmd {
+	return nil
+}
+
+func (e editor) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	if key, ok := msg.(tea.KeyMsg); ok && key.String() == "ctrl+c" {
+		return e, tea.Quit
+	}
+
+	switch e.screen {
+	case screenCustomer:
+		return e.updateCustomer(msg)
+	case screenHeader:
+		return e.updateHeader(msg)
+	case screenPositions:
+		return e.updatePositions(msg)
+	case screenPosition:
+		return e.updatePosition(msg)
+	case screenReview:
+		return e.updateReview(msg)
+	}
+
+	return e, nil
+}
+
+func (e editor) updateCustomer(msg tea.Msg) (tea.Model, tea.Cmd) {
+	if key, ok := msg.(tea.KeyMsg); ok {
+		switch key.String() {
+		case "enter":
+			if e.picker.selected() == "" {
+				return e, nil
+			}
+			e.spec.Customer = e.picker.selected()
+			e.screen = screenHeader
+			return e, nil
+		case "esc":
+			if e.spec.Customer != "" {
+				e.screen = screenHeader
+			}
+			return e, nil
+		}
+	}
+
+	var cmd tea.Cmd
+	e.picker, cmd = e.picker.update(msg)
+
+	return e, cmd
+}
+
+func (e editor) updateHeader(msg tea.Msg) (tea.Model, tea.Cmd) {
+	if key, ok := msg.(tea.KeyMsg); ok {
+		switch key.String() {
+		case "enter":
+			if !e.header.valid() {
+				return e, nil
+			}
+			e.applyHeader()
+			e.screen = screenPositions
+			return e, nil
+		case "esc":
+			e.screen = screenCustomer
+			return e, nil
+		}
+	}
+
+	var cmd tea.Cmd
+	e.header, cmd = e.header.update(msg)
+	e.followDeadline()
+
+	return e, cmd
+}
+
+/* followDeadline moves the payment deadline with the date of issue until the user changes the deadline */
+func (e *editor) followDeadline() {
+	if e.header.value(headerPaymentDeadline) != e.defaultDeadline || validDate(e.header.value(headerDateOfIssue)) != nil {
+		return
+	}
+
+	e.defaultDeadline = InvoiceManager.DefaultPaymentDeadline(e.companyData, e.header.value(headerDateOfIssue))
+	e.header.setValue(headerPaymentDeadline, e.defaultDeadline)
+}
+
+func (e editor) updatePositions(msg tea.Msg) (tea.Model, tea.Cmd) {
+	key, ok := msg.(tea.KeyMsg)
+	if !ok {
+		return e, nil
+	}
+
+	e.message = ""
+	switch key.String() {
+	case "up", "k":
+		e.positions.cursor = max(e.positions.cursor-1, 0)
+	case "down", "j":
+		e.positions.cursor = min(e.positions.cursor+1, max(len(e.spec.Positions)-1, 0))
+	case "a":
+		return e.editPosition(len(e.spec.Positions), InvoiceManager.DefaultPositionSpec(e.companyData))
+	case "enter", "e":
+		if len(e.spec.Positions) > 0 {
+			return e.editPosition(e.positions.cursor, e.spec.Positions[e.positions.cursor])
+		}
+	case "d", "delete":
+		if len(e.spec.Positions) > 0 {
+			e.spec.Positions = append(e.spec.Positions[:e.positions.cursor], e.spec.Positions[e.positions.cursor+1:]...)
+			e.positions.cursor = min(e.positions.cursor, max(len(e.spec.Positions)-1, 0))
+		}
+	case "esc":
+		e.screen = screenHeader
+	case "r", "tab":
+		return e.review()
+	}
+
+	return e, nil
+}
+
+func (e editor) editPosition(index int, position InvoiceSpec.PositionSpec) (tea.Model, tea.Cmd) {
+	e.editing = index
+	e.position = newPositionForm(position)
+	e.screen = screenPosition
+
+	return e, nil
+}
+
+func (e editor) updatePosition(msg tea.Msg) (tea.Model, tea.Cmd) {
+	if key, ok := msg.(tea.KeyMsg); ok {
+		switch key.String() {
+		case "enter":
+			if !e.position.valid() {
+				return e, nil
+			}
+			position := positionOf(e.position)
+			if e.editing == len(e.spec.Positions) {
+				e.spec.Positions = append(e.spec.Positions, position)
+			} else {
+				e.spec.Positions[e.editing] = position
+			}
+			e.positions.cursor = e.editing
+			e.screen = screenPositions
+			return e, nil
+		case "esc":
+			e.screen = screenPositions
+			return e, nil
+		}
+	}
+
+	var cmd tea.Cmd
+	e.position, cmd = e.position.update(msg)
+
+	return e, cmd
+}
+
+/* review creates the invoice as it will be issued, the number is a preview until it is saved */
+func (e editor) review() (tea.Model, tea.Cmd) {
+	if problems := e.spec.Validate(); len(problems) > 0 {
+		e.message = strings.Join(problems, "; ")
+		return e, nil
+	}
+
+	preview, err := InvoiceManager.CreateInvoiceFromSpec(e.spec)
+	if err != nil {
+		e.message = err.Error()
+		return e, nil
+	}
+
+	e.preview = preview
+	e.screen = screenReview
+
+	return e, nil
+}
+
+func (e editor) updateReview(msg tea.Msg) (tea.Model, tea.Cmd) {
+	key, ok := msg.(tea.KeyMsg)
+	if !ok {
+		return e, nil
+	}
+
+	switch key.String() {
+	case "enter", "s":
+		e.saved = true
+		return e, tea.Quit
+	case "esc", "p":
+		e.screen = screenPositions
+	case "h":
+		e.screen = screenHeader
+	case "c":
+		e.screen = screenCustomer
+	}
+
+	return e, nil
+}
+
+func (e editor) View() string {
+	steps := []string{"Customer", "Header", "Positions", "Review"}
+	current := min(int(e.screen), int(screenPositions))
+	if e.screen == screenReview {
+		current = 3
+	}
+
+	var breadcrumb []string
+	for i, step := range steps {
+		if i == current {
+			breadcrumb = append(breadcrumb, focusedStyle.Render(step))
+		} else {
+			breadcrumb = append(breadcrumb, labelStyle.Render(step))
+		}
+	}
+
+	var view strings.Builder
+	view.WriteString(titleStyle.Render("moneybringer · new invoice") + "  " + strings.Join(breadcrumb, labelStyle.Render(" › ")) + "\n\n")
+
+	if e.spec.Customer != "" && e.screen != screenCustomer {
+		view.WriteString(labelStyle.Render("Customer: ") + e.spec.Customer + "\n\n")
+	}
+
+	switch e.screen {
+	case screenCustomer:
+		view.WriteString(e.picker.view())
+	case screenHeader:
+		view.WriteString(e.header.view())
+	case screenPositions:
+		view.WriteString(e.positions.view(e.spec, e.companyData))
+	case screenPosition:
+		view.WriteString(e.position.view())
+		view.WriteString("\n" + positionPreview(e.position, e.spec.Currency) + "\n")
+	case screenReview:
+		view.WriteString(reviewView(e.preview))
+	}
+
+	if e.message != "" {
+		view.WriteString("\n" + errorStyle.Render(e.message) + "\n")
+	}
+
+	view.WriteString("\n" + helpStyle.Render(help[e.screen]) + "\n")
+
+	return view.String()
+}
+
+var help = map[screen]string{
+	screenCustomer:  "↑/↓ choose · type to filter · enter select · ctrl+c quit without saving",
+	screenHeader:    "tab/↑/↓ move · enter positions · esc customer · ctrl+c quit without saving",
+	screenPositions: "↑/↓ choose · a add · enter/e edit · d delete · r review · esc header · ctrl+c quit without saving",
+	screenPosition:  "tab/↑/↓ move · enter keep the position · esc discard changes",
+	screenReview:    "enter/s save the invoice · p positions · h header · c customer · ctrl+c quit without saving",
+}
@@ -0,0 +1,200 @@
+package AmountInWords
+
+import (
+	"fmt"
+	Money "moneybringer/utils/money"
+	"strings"
+)
+
+/* forms holds singular, "few" (2-4) and "many" (5+) variants, English only uses the first two */
+type forms [3]string
+
+var polishOnes = []string{"", "jeden", "dwa", "trzy", "cztery", "pięć", "sześć", "siedem", "osiem", "dziewięć"}
+var polishTeens = []string{"dziesięć", "jedenaście", "dwanaście", "trzynaście", "czternaście", "piętnaście", "szesnaście", "siedemnaście", "osiemnaście", "dziewiętnaście"}
+var polishTens = []string{"", "", "dwadzieścia", "trzydzieści", "czterdzieści", "pięćdziesiąt", "sześćdziesiąt", "siedemdziesiąt", "osiemdziesiąt", "dziewięćdziesiąt"}
+var polishHundreds = []string{"", "sto", "dwieście", "trzysta", "czterysta", "pięćset", "sześćset", "siedemset", "osiemset", "dziewięćset"}
+var polishScales = []forms{
+	{"", "", ""},
+	{"tysiąc", "tysiące", "tysięcy"},
+	{"milion", "miliony", "milionów"},
+	{"miliard", "miliardy", "miliardów"},
+}
+var polishCurrencies = map[string]forms{
+	"PLN": {"złoty", "złote", "złotych"},
+	"EUR": {"euro", "euro", "euro"},
+	"USD": {"dolar", "dolary", "dolarów"},
+}
+
+var englishOnes = []string{"", "one", "two", "three", "four", "five", "six", "seven", "eight", "nine"}
+var englishTeens = []string{"ten", "eleven", "twelve", "thirteen", "fourteen", "fifteen", "sixteen", "seventeen", "eighteen", "nineteen"}
+var englishTens = []string{"", "", "twenty", "thirty", "forty", "fifty", "sixty", "seventy", "eighty", "ninety"}
+var englishScales = []string{"", "thousand", "million", "billion"}
+var englishCurrencies = map[string]forms{
+	"PLN": {"zloty", "zlotys"},
+	"EUR": {"euro", "euros"},
+	"USD": {"dollar", "dollars"},
+}
+
+const LANGUAGE_POLISH = "pl"
+const LANGUAGE_ENGLISH = "en"
+
+/*
+Spell writes the amount the way it goes on invoices, whole units in words and
+minor units as a fraction, e.g. "jeden tysiąc dwieście złotych 50/100".
+*/
+func Spell(amount Money.Money, language string) (string, error) {
+	value := amount.Amount
+	prefix := ""
+	if value < 0 {
+		value = -value
+		prefix = "minus "
+	}
+
+	whole := value / 100
+	fraction := value % 100
+
+	var words string
+	switch language {
+	case LANGUAGE_POLISH, "":
+		words = polishNumber(whole) + " " + polishCurrency(whole, amount.Currency)
+	case LANGUAGE_ENGLISH:
+		words = englishNumber(whole) + " " + englishCurrency(whole, amount.Currency)
+	default:
+		return "", fmt.Errorf("unsupported language %q for amount in words, use %s or %s", language, LANGUAGE_POLISH, LANGUAGE_ENGLISH)
+	}
+
+	return fmt.Sprintf("%s%s %02d/100", prefix, words, fraction), nil
+}
+
+func polishNumber(number int64) string {
+	if number == 0 {
+		return "zero"
+	}
+
+	var parts []string
+	for scale := len(polishScales) - 1; scale >= 0; scale-- {
+		group := number / pow1000(scale) % 1000
+		if group == 0 {
+			continue
+		}
+
+		parts = append(parts, polishGroup(group))
+		if scale > 0 {
+			parts = append(parts, polishForm(group, polishScales[scale]))
+		}
+	}
+
+	return strings.Join(parts, " ")
+}
+
+func polishGroup(group int64) string {
+	var parts []string
+
+	if hundreds := group / 100; hundreds > 0 {
+		parts = append(parts, polishHundreds[hundreds])
+	}
+
+	rest := group % 100
+	switch {
+	case rest >= 10 && rest < 20:
+		parts = append(parts, polishTeens[rest-10])
+	default:
+		if tens := rest / 10; tens > 0 {
+			parts = append(parts, polishTens[tens])
+		}
+		if ones := rest % 10; ones > 0 {
+			parts = append(parts, polishOnes[ones])
+		}
+	}
+
+	return strings.Join(parts, " ")
+}
+
+/* Polish nouns after numerals: 1 - singular, 2-4 (but not 12-14) - "few" form, the rest - "many" form */
+func polishForm(number int64, nounForms forms) string {
+	if number == 1 {
+		return nounForms[0]
+	}
+
+	lastDigit := number % 10
+	lastTwoDigits := number % 100
+	if lastDigit >= 2 && lastDigit <= 4 && (lastTwoDigits < 12 || lastTwoDigits > 14) {
+		return nounForms[1]
+	}
+
+	return nounForms[2]
+}
+
+func polishCurrency(number int64, currency string) string {
+	nounForms, exists := polishCurrencies[currency]
+	if !exists {
+		return currency
+	}
+
+	return polishForm(number, nounForms)
+}
+
+func englishNumber(number int64) string {
+	if number == 0 {
+		return "zero"
+	}
+
+	var parts []string
+	for scale := len(englishScales) - 1; scale >= 0; scale-- {
+		group := number / pow1000(scale) % 1000
+		if group == 0 {
+			continue
+		}
+
+		parts = append(parts, englishGroup(group))
+		if scale > 0 {
+			parts = append(parts, englishScales[scale])
+		}
+	}
+
+	return strings.Join(parts, " ")
+}
+
+func englishGroup(group int64) string {
+	var parts []string
+
+	if hundreds := group / 100; hundreds > 0 {
+		parts = append(parts, englishOnes[hundreds], "hundred")
+	}
+
+	rest := group % 100
+	switch {
+	case rest >= 10 && rest < 20:
+		parts = append(parts, englishTeens[rest-10])
+	case rest >= 20 && rest%10 > 0:
+		parts = append(parts, englishTens[rest/10]+"-"+englishOnes[rest%10])
+	case rest >= 20:
+		parts = append(parts, englishTens[rest/10])
+	case rest > 0:
+		parts = append(parts, englishOnes[rest])
+	}
+
+	return strings.Join(parts, " ")
+}
+
+func englishCurrency(number int64, currency string) string {
+	nounForms, exists := englishCurrencies[currency]
+	if !exists {
+		return currency
+	}
+
+	if number == 1 {
+		return nounForms[0]
+	}
+
+	return nounForms[1]
+}
+
+func pow1000(scale int) int64 {
+	result := int64(1)
+	for i := 0; i < scale; i++ {
+		result *= 1000
+	}
+
+	return result
+}
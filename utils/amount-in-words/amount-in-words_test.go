@@ -0,0 +1,97 @@
+package AmountInWords_test
+
+import (
+	AmountInWords "moneybringer/utils/amount-in-words"
+	Money "moneybringer/utils/money"
+	"testing"
+)
+
+func TestSpellPolish(t *testing.T) {
+	cases := []struct {
+		amount   int64
+		currency string
+		expected string
+	}{
+		/* 1, 2-4 and 5+ forms of the currency */
+		{100, "PLN", "jeden złoty 00/100"},
+		{200, "PLN", "dwa złote 00/100"},
+		{400, "PLN", "cztery złote 00/100"},
+		{500, "PLN", "pięć złotych 00/100"},
+		{0, "PLN", "zero złotych 00/100"},
+		{2100, "PLN", "dwadzieścia jeden złotych 00/100"},
+		{2200, "PLN", "dwadzieścia dwa złote 00/100"},
+		{10400, "PLN", "sto cztery złote 00/100"},
+		{200, "USD", "dwa dolary 00/100"},
+		{700, "USD", "siedem dolarów 00/100"},
+		{300, "EUR", "trzy euro 00/100"},
+		{300, "CHF", "trzy CHF 00/100"},
+		/* teens take the 5+ form, also when they end in 2-4 */
+		{1000, "PLN", "dziesięć złotych 00/100"},
+		{1100, "PLN", "jedenaście złotych 00/100"},
+		{1200, "PLN", "dwanaście złotych 00/100"},
+		{1400, "PLN", "czternaście złotych 00/100"},
+		{1900, "PLN", "dziewiętnaście złotych 00/100"},
+		{11300, "PLN", "sto trzynaście złotych 00/100"},
+		/* thousands and millions are declined by their own group */
+		{100000, "PLN", "jeden tysiąc złotych 00/100"},
+		{200000, "PLN", "dwa tysiące złotych 00/100"},
+		{500000, "PLN", "pięć tysięcy złotych 00/100"},
+		{1200000, "PLN", "dwanaście tysięcy złotych 00/100"},
+		{2200000, "PLN", "dwadzieścia dwa tysiące złotych 00/100"},
+		{123456700, "PLN", "jeden milion dwieście trzydzieści cztery tysiące pięćset sześćdziesiąt siedem złotych 00/100"},
+		{300000000, "PLN", "trzy miliony złotych 00/100"},
+		{1500000200, "PLN", "piętnaście milionów dwa złote 00/100"},
+		{100100000, "PLN", "jeden milion jeden tysiąc złotych 00/100"},
+		/* grosze are written as a fraction */
+		{123450, "PLN", "jeden tysiąc dwieście trzydzieści cztery złote 50/100"},
+		{105, "PLN", "jeden złoty 05/100"},
+		{99, "PLN", "zero złotych 99/100"},
+		/* corrections lowering the invoice value */
+		{-12345, "PLN", "minus sto dwadzieścia trzy złote 45/100"},
+		{-50, "PLN", "minus zero złotych 50/100"},
+		{-500000, "PLN", "minus pięć tysięcy złotych 00/100"},
+	}
+
+	for _, c := range cases {
+		words, err := AmountInWords.Spell(Money.New(c.amount, c.currency), AmountInWords.LANGUAGE_POLISH)
+		if err != nil {
+			t.Errorf("Spell(%d %s): %v", c.amount, c.currency, err)
+			continue
+		}
+		if words != c.expected {
+			t.Errorf("Spell(%d %s) = %q, want %q", c.amount, c.currency, words, c.expected)
+		}
+	}
+}
+
+func TestSpellDefaultsToPolish(t *testing.T) {
+	if words, err := AmountInWords.Spell(Money.New(200, "PLN"), ""); err != nil || words != "dwa złote 00/100" {
+		t.Errorf("Spell without a language = %q, %v, want Polish", words, err)
+	}
+}
+
+func TestSpellEnglish(t *testing.T) {
+	cases := []struct {
+		amount   int64
+		currency string
+		expected string
+	}{
+		{100, "EUR", "one euro 00/100"},
+		{1300, "USD", "thirteen dollars 00/100"},
+		{2100, "PLN", "twenty-one zlotys 00/100"},
+		{123450, "EUR", "one thousand two hundred thirty-four euros 50/100"},
+		{-200000000, "USD", "minus two million dollars 00/100"},
+	}
+
+	for _, c := range cases {
+		if words, err := AmountInWords.Spell(Money.New(c.amount, c.currency), AmountInWords.LANGUAGE_ENGLISH); err != nil || words != c.expected {
+			t.Errorf("Spell(%d %s) = %q, %v, want %q", c.amount, c.currency, words, err, c.expected)
+		}
+	}
+}
+
+func TestSpellRefusesUnknownLanguage(t *testing.T) {
+	if _, err := AmountInWords.Spell(Money.New(100, "PLN"), "de"); err == nil {
+		t.Error("Spell in de, want an error")
+	}
+}
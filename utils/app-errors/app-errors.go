@@ -0,0 +1,13 @@
+package AppErrors
+
+import (
+	"errors"
+)
+
+/*
+Error kinds shared by the packages, so the CLI or a service embedding them decides with
+errors.Is how to react. Package errors wrap them, e.g. an invalid seller profile is
+CompanyData.ErrInvalidCompany and ErrConfigInvalid at once.
+*/
+var ErrConfigInvalid = errors.New("invalid configuration")
+var ErrStorage = errors.New("storage error")
@@ -0,0 +1,128 @@
+package AppPaths
+
+import (
+	"os"
+	"path/filepath"
+)
+
+/*
+Config files (company.json, customers.json, ksef.json) and stored invoices are looked
+up in this order:
+
+ 1. --config-dir / --data-dir given on the command line
+ 2. $MONEYBRINGER_HOME/config and $MONEYBRINGER_HOME/invoices
+ 3. ./config and ./invoices when ./config exists, i.e. running from a checkout as before
+ 4. $XDG_CONFIG_HOME/moneybringer and $XDG_DATA_HOME/moneybringer/invoices
+    (~/.config/moneybringer and ~/.local/share/moneybringer/invoices by default)
+*/
+const HOME_ENV = "MONEYBRINGER_HOME"
+const APP_NAME = "moneybringer"
+
+const SOURCE_FLAG = "flag"
+const SOURCE_HOME_ENV = HOME_ENV
+const SOURCE_WORKING_DIR = "working directory"
+const SOURCE_XDG = "XDG base directory"
+
+const legacyConfigDir = "config"
+const legacyInvoicesDir = "invoices"
+
+var configDirOverride string
+var invoicesDirOverride string
+
+/* Location is a resolved directory and the rule that selected it */
+type Location struct {
+	Dir    string `json:"dir"`
+	Source string `json:"source"`
+}
+
+func SetConfigDir(dir string) {
+	configDirOverride = dir
+}
+
+func SetInvoicesDir(dir string) {
+	invoicesDirOverride = dir
+}
+
+func ConfigDir() string {
+	return ResolveConfigDir().Dir
+}
+
+func InvoicesDir() string {
+	return ResolveInvoicesDir().Dir
+}
+
+/* ConfigFile returns the path of a file in the config directory, e.g. ConfigFile("company.json") */
+func ConfigFile(name string) string {
+	return filepath.Join(ConfigDir(), name)
+}
+
+func ResolveConfigDir() Location {
+	switch {
+	case configDirOverride != "":
+		return Location{Dir: configDirOverride, Source: SOURCE_FLAG}
+	case os.Getenv(HOME_ENV) != "":
+		return Location{Dir: filepath.Join(os.Getenv(HOME_ENV), legacyConfigDir), Source: SOURCE_HOME_ENV}
+	case isLegacyLayout():
+		return Location{Dir: legacyConfigDir, Source: SOURCE_WORKING_DIR}
+	}
+
+	return Location{Dir: filepath.Join(xdgDir("XDG_CONFIG_HOME", ".config"), APP_NAME), Source: SOURCE_XDG}
+}
+
+func ResolveInvoicesDir() Location {
+	switch {
+	case invoicesDirOverride != "":
+		return Location{Dir: invoicesDirOverride, Source: SOURCE_FLAG}
+	case os.Getenv(HOME_ENV) != "":
+		return Location{Dir: filepath.Join(os.Getenv(HOME_ENV), legacyInvoicesDir), Source: SOURCE_HOME_ENV}
+	case isLegacyLayout():
+		return Location{Dir: legacyInvoicesDir, Source: SOURCE_WORKING_DIR}
+	}
+
+	return Location{Dir: filepath.Join(xdgDir("XDG_DATA_HOME", filepath.Join(".local", "share")), APP_NAME, legacyInvoicesDir), Source: SOURCE_XDG}
+}
+
+/*
+ResolveConfigPath resolves a path written in a config file, such as the KSeF public key
+or a logo. Absolute paths and paths existing relative to the working directory (as in
+configs written before the config directory was configurable) are kept. Other paths are
+looked up in the config directory and then in its parent, so "./config/key.pem" still
+works under $MONEYBRINGER_HOME.
+*/
+func ResolveConfigPath(path string) string {
+	if path == "" || filepath.IsAbs(path) || exists(path) {
+		return path
+	}
+
+	configDir := ConfigDir()
+	inParent := filepath.Join(filepath.Dir(configDir), path)
+	if !exists(filepath.Join(configDir, path)) && exists(inParent) {
+		return inParent
+	}
+
+	return filepath.Join(configDir, path)
+}
+
+func exists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+func isLegacyLayout() bool {
+	info, err := os.Stat(legacyConfigDir)
+	return err == nil && info.IsDir()
+}
+
+/* xdgDir follows the XDG base directory spec: the variable when set to an absolute path, otherwise ~/fallback */
+func xdgDir(variable string, fallback string) string {
+	if dir := os.Getenv(variable); filepath.IsAbs(dir) {
+		return dir
+	}
+
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return fallback
+	}
+
+	return filepath.Join(home, fallback)
+}
@@ -0,0 +1,192 @@
+package Money
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+/* Money keeps amounts as integer minor units (grosze, cents) to avoid float drift */
+type Money struct {
+	Amount   int64
+	Currency string
+}
+
+var ErrCurrencyMismatch = errors.New("amounts are in different currencies")
+
+type moneyJSON struct {
+	Amount   string
+	Currency string
+}
+
+func New(amount int64, currency string) Money {
+	return Money{Amount: amount, Currency: currency}
+}
+
+func Zero(currency string) Money {
+	return Money{Currency: currency}
+}
+
+/*
+FromFloat rounds half away from zero to full minor units. The shortest decimal form of the
+value is rounded, so 1.005 becomes 1.01 as written and not 1.00 as its binary approximation.
+*/
+func FromFloat(value float64, currency string) Money {
+	whole, fraction, _ := strings.Cut(strconv.FormatFloat(math.Abs(value), 'f', -1, 64), ".")
+	fraction += "000"
+
+	amount, _ := strconv.ParseInt(whole+fraction[:2], 10, 64)
+	if fraction[2] >= '5' {
+		amount++
+	}
+
+	if value < 0 {
+		amount = -amount
+	}
+
+	return Money{Amount: amount, Currency: currency}
+}
+
+/* Parse accepts "1234.5", "1234,50", "1 234,50" and a leading minus, nothing else */
+func Parse(value string, currency string) (Money, error) {
+	normalized := strings.ReplaceAll(strings.TrimSpace(value), " ", "")
+	normalized = strings.ReplaceAll(normalized, ",", ".")
+
+	negative := strings.HasPrefix(normalized, "-")
+	normalized = strings.TrimPrefix(normalized, "-")
+
+	whole, fraction, _ := strings.Cut(normalized, ".")
+	if !isDigits(whole) || len(fraction) > 2 || (fraction != "" && !isDigits(fraction)) {
+		return Money{}, fmt.Errorf("invalid amount %q", value)
+	}
+
+	fraction = (fraction + "00")[:2]
+	amount, err := strconv.ParseInt(whole+fraction, 10, 64)
+	if err != nil {
+		return Money{}, fmt.Errorf("invalid amount %q", value)
+	}
+
+	if negative {
+		amount = -amount
+	}
+
+	return Money{Amount: amount, Currency: currency}, nil
+}
+
+/* Add and Sub panic with ErrCurrencyMismatch on amounts in different currencies */
+func (m Money) Add(other Money) Money {
+	return Money{Amount: m.Amount + other.Amount, Currency: m.currencyWith(other)}
+}
+
+func (m Money) Sub(other Money) Money {
+	return Money{Amount: m.Amount - other.Amount, Currency: m.currencyWith(other)}
+}
+
+func (m Money) Neg() Money {
+	return Money{Amount: -m.Amount, Currency: m.Currency}
+}
+
+func (m Money) MulInt(multiplier int) Money {
+	return Money{Amount: m.Amount * int64(multiplier), Currency: m.Currency}
+}
+
+/* Percent returns rate% of the amount rounded half away from zero, as VAT rounding requires */
+func (m Money) Percent(rate int) Money {
+	return Money{Amount: divRound(m.Amount*int64(rate), 100), Currency: m.Currency}
+}
+
+/* MulRatio returns amount * numerator / denominator rounded half away from zero, e.g. a proportional share */
+func (m Money) MulRatio(numerator int64, denominator int64) Money {
+	return Money{Amount: divRound(m.Amount*numerator, denominator), Currency: m.Currency}
+}
+
+func (m Money) IsZero() bool {
+	return m.Amount == 0
+}
+
+/* String formats the amount with a dot and two decimals, without the currency */
+func (m Money) String() string {
+	sign := ""
+	amount := m.Amount
+	if amount < 0 {
+		sign = "-"
+		amount = -amount
+	}
+
+	return fmt.Sprintf("%s%d.%02d", sign, amount/100, amount%100)
+}
+
+func (m Money) Float64() float64 {
+	return float64(m.Amount) / 100
+}
+
+func (m Money) MarshalJSON() ([]byte, error) {
+	return json.Marshal(moneyJSON{Amount: m.String(), Currency: m.Currency})
+}
+
+/* UnmarshalJSON also reads plain numbers stored by older versions of raw invoices */
+func (m *Money) UnmarshalJSON(data []byte) error {
+	var legacy float64
+	if err := json.Unmarshal(data, &legacy); err == nil {
+		*m = FromFloat(legacy, "")
+		return nil
+	}
+
+	var raw moneyJSON
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+
+	parsed, err := Parse(raw.Amount, raw.Currency)
+	if err != nil {
+		return err
+	}
+
+	*m = parsed
+	return nil
+}
+
+/*
+currencyWith panics when the amounts are in different currencies, adding them is a bug of the
+caller. An amount without a currency (a zero value, legacy raw invoices) takes the other one.
+*/
+func (m Money) currencyWith(other Money) string {
+	if m.Currency == "" {
+		return other.Currency
+	}
+
+	if other.Currency != "" && other.Currency != m.Currency {
+		panic(fmt.Errorf("%w: %s and %s", ErrCurrencyMismatch, m.Currency, other.Currency))
+	}
+
+	return m.Currency
+}
+
+func isDigits(value string) bool {
+	if value == "" {
+		return false
+	}
+
+	for _, char := range value {
+		if char < '0' || char > '9' {
+			return false
+		}
+	}
+
+	return true
+}
+
+func divRound(value int64, divisor int64) int64 {
+	if divisor < 0 {
+		value, divisor = -value, -divisor
+	}
+
+	if value < 0 {
+		return -((-value + divisor/2) / divisor)
+	}
+
+	return (value + divisor/2) / divisor
+}
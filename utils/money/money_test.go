@@ -0,0 +1,197 @@
+package Money_test
+
+import (
+	"encoding/json"
+	"errors"
+	Money "moneybringer/utils/money"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	cases := []struct {
+		value    string
+		expected int64
+	}{
+		{"1234.5", 123450},
+		{"1234,50", 123450},
+		{"1 234,50", 123450},
+		{" 12 ", 1200},
+		{"7.", 700},
+		{"0.05", 5},
+		{"-12.34", -1234},
+		{"-0,5", -50},
+		{"0", 0},
+	}
+
+	for _, c := range cases {
+		parsed, err := Money.Parse(c.value, "PLN")
+		if err != nil {
+			t.Errorf("Parse(%q): %v", c.value, err)
+			continue
+		}
+		if parsed.Amount != c.expected || parsed.Currency != "PLN" {
+			t.Errorf("Parse(%q) = %d %s, want %d PLN", c.value, parsed.Amount, parsed.Currency, c.expected)
+		}
+	}
+}
+
+func TestParseRefusesInvalidAmounts(t *testing.T) {
+	for _, value := range []string{"", "-", "+5", "--5", "-+5", "5-", ".5", "1.234", "1.2.3", "1.-5", "12.3a", "abc", "1e3", "0x10", "1'234.00"} {
+		if parsed, err := Money.Parse(value, "PLN"); err == nil {
+			t.Errorf("Parse(%q) = %d, want an error", value, parsed.Amount)
+		}
+	}
+}
+
+func TestString(t *testing.T) {
+	for amount, expected := range map[int64]string{0: "0.00", 5: "0.05", -5: "-0.05", 123456: "1234.56", -123400: "-1234.00"} {
+		if got := Money.New(amount, "PLN").String(); got != expected {
+			t.Errorf("String of %d = %q, want %q", amount, got, expected)
+		}
+	}
+}
+
+func TestPercentRoundsHalfAwayFromZero(t *testing.T) {
+	cases := []struct {
+		amount   int64
+		rate     int
+		expected int64
+	}{
+		{1000, 23, 230},
+		{217, 23, 50}, // 49.91
+		{-217, 23, -50},
+		{150, 1, 2}, // 1.5
+		{-150, 1, -2},
+		{149, 1, 1},
+		{-149, 1, -1},
+		{50, 1, 1}, // 0.5
+		{-50, 1, -1},
+		{3, 8, 0}, // 0.24
+		{1000, 0, 0},
+	}
+
+	for _, c := range cases {
+		if got := Money.New(c.amount, "PLN").Percent(c.rate); got.Amount != c.expected {
+			t.Errorf("%d%% of %d = %d, want %d", c.rate, c.amount, got.Amount, c.expected)
+		}
+	}
+}
+
+func TestMulRatio(t *testing.T) {
+	cases := []struct {
+		amount      int64
+		numerator   int64
+		denominator int64
+		expected    int64
+	}{
+		{1000, 1, 3, 333},
+		{1000, 2, 3, 667},
+		{-1000, 2, 3, -667},
+		{5, 1, 2, 3},
+		{-5, 1, 2, -3},
+		{5, 1, -2, -3},
+		{-5, 1, -2, 3},
+		{123000, 40000, 123000, 40000},
+		{0, 7, 9, 0},
+	}
+
+	for _, c := range cases {
+		if got := Money.New(c.amount, "EUR").MulRatio(c.numerator, c.denominator); got.Amount != c.expected || got.Currency != "EUR" {
+			t.Errorf("%d * %d / %d = %d %s, want %d EUR", c.amount, c.numerator, c.denominator, got.Amount, got.Currency, c.expected)
+		}
+	}
+}
+
+func TestFromFloatRoundsTheDecimalValue(t *testing.T) {
+	for value, expected := range map[float64]int64{12: 1200, 1234.56: 123456, 1.005: 101, 2.675: 268, -1.005: -101, 0.015: 2, 0.1 + 0.2: 30, 0.0000001: 0, -12.5: -1250} {
+		if got := Money.FromFloat(value, "PLN"); got.Amount != expected {
+			t.Errorf("FromFloat(%v) = %d, want %d", value, got.Amount, expected)
+		}
+	}
+}
+
+func TestAddAndSub(t *testing.T) {
+	pln := Money.New(1050, "PLN")
+
+	cases := []struct {
+		name     string
+		got      Money.Money
+		expected Money.Money
+	}{
+		{"add", pln.Add(Money.New(-2000, "PLN")), Money.New(-950, "PLN")},
+		{"sub", pln.Sub(Money.New(50, "PLN")), Money.New(1000, "PLN")},
+		{"add to a zero value", Money.Money{}.Add(pln), pln},
+		{"add an amount without a currency", pln.Add(Money.New(1, "")), Money.New(1051, "PLN")},
+		{"sub from a zero value", Money.Zero("").Sub(pln), Money.New(-1050, "PLN")},
+		{"neg", pln.Neg(), Money.New(-1050, "PLN")},
+		{"mul", pln.MulInt(3), Money.New(3150, "PLN")},
+	}
+
+	for _, c := range cases {
+		if c.got != c.expected {
+			t.Errorf("%s = %+v, want %+v", c.name, c.got, c.expected)
+		}
+	}
+}
+
+func TestAddAndSubRefuseDifferentCurrencies(t *testing.T) {
+	operations := map[string]func(a Money.Money, b Money.Money) Money.Money{
+		"Add": Money.Money.Add,
+		"Sub": Money.Money.Sub,
+	}
+
+	for name, operation := range operations {
+		func() {
+			defer func() {
+				err, _ := recover().(error)
+				if !errors.Is(err, Money.ErrCurrencyMismatch) {
+					t.Errorf("%s of PLN and EUR panicked with %v, want ErrCurrencyMismatch", name, err)
+				}
+			}()
+
+			operation(Money.New(100, "PLN"), Money.New(100, "EUR"))
+		}()
+	}
+}
+
+func TestJSON(t *testing.T) {
+	jsonData, err := json.Marshal(Money.New(-1234, "EUR"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(jsonData) != `{"Amount":"-12.34","Currency":"EUR"}` {
+		t.Errorf("Marshal = %s", jsonData)
+	}
+
+	cases := []struct {
+		json     string
+		expected Money.Money
+	}{
+		{`{"Amount":"-12.34","Currency":"EUR"}`, Money.New(-1234, "EUR")},
+		{`{"Amount":"1 000,5","Currency":"PLN"}`, Money.New(100050, "PLN")},
+		/* plain numbers of raw invoices written before amounts kept their currency */
+		{`1234.56`, Money.New(123456, "")},
+		{`1.005`, Money.New(101, "")},
+		{`-12.5`, Money.New(-1250, "")},
+		{`0.30000000000000004`, Money.New(30, "")},
+		{`100`, Money.New(10000, "")},
+	}
+
+	for _, c := range cases {
+		var parsed Money.Money
+		if err := json.Unmarshal([]byte(c.json), &parsed); err != nil {
+			t.Errorf("Unmarshal(%s): %v", c.json, err)
+			continue
+		}
+		if parsed != c.expected {
+			t.Errorf("Unmarshal(%s) = %+v, want %+v", c.json, parsed, c.expected)
+		}
+	}
+
+	for _, invalid := range []string{`{"Amount":"+5","Currency":"PLN"}`, `{"Amount":"1.234","Currency":"PLN"}`, `"12.30"`, `true`} {
+		var parsed Money.Money
+		if err := json.Unmarshal([]byte(invalid), &parsed); err == nil {
+			t.Errorf("Unmarshal(%s) = %+v, want an error", invalid, parsed)
+		}
+	}
+}
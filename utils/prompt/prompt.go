@@ -0,0 +1,151 @@
+package Prompt
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+/*
+Prompter asks one question of an interactive flow and returns the answer without the
+trailing newline. An empty answer takes the default offered in the question.
+*/
+type Prompter interface {
+	Ask(question string) (string, error)
+}
+
+var ErrNoMoreAnswers = errors.New("no more answers")
+
+/* current answers every prompt of the interactive flows, SetPrompter replaces it */
+var current Prompter = NewTerminal(os.Stdin, os.Stdout)
+
+func SetPrompter(prompter Prompter) {
+	current = prompter
+}
+
+func Current() Prompter {
+	return current
+}
+
+/* String asks question and returns the trimmed answer, defaultValue when it is empty or input ended */
+func String(question string, defaultValue string) string {
+	answer, err := current.Ask(question)
+	answer = strings.TrimSpace(answer)
+
+	if err != nil || answer == "" {
+		return defaultValue
+	}
+
+	return answer
+}
+
+/* Int is String for whole numbers, answers that are not a number take the default */
+func Int(question string, defaultValue int) int {
+	number, err := strconv.Atoi(String(question, strconv.Itoa(defaultValue)))
+	if err != nil {
+		return defaultValue
+	}
+
+	return number
+}
+
+/* Confirm is true for y, yes, t and tak in any letter case, anything else is no */
+func Confirm(question string) bool {
+	switch strings.ToLower(String(question, "")) {
+	case "y", "yes", "t", "tak":
+		return true
+	}
+
+	return false
+}
+
+/* Terminal reads answers line by line through one buffered reader, so piped input is never lost between prompts */
+type Terminal struct {
+	reader *bufio.Reader
+	out    io.Writer
+}
+
+func NewTerminal(in io.Reader, out io.Writer) *Terminal {
+	return &Terminal{reader: bufio.NewReader(in), out: out}
+}
+
+func (t *Terminal) Ask(question string) (string, error) {
+	fmt.Fprint(t.out, question)
+
+	line, err := t.reader.ReadString('\n')
+	if err != nil && line == "" {
+		/* without a newline the prompt would run into the next output */
+		fmt.Fprintln(t.out)
+		return "", err
+	}
+
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+/* Scripted answers prompts in order from pre-recorded answers and echoes them after the question */
+type Scripted struct {
+	answers []string
+	out     io.Writer
+}
+
+func NewScripted(answers []string, out io.Writer) *Scripted {
+	return &Scripted{answers: answers, out: out}
+}
+
+/* LoadScript reads answers from a file, one per line, an empty line takes the default */
+func LoadScript(path string, out io.Writer) (*Scripted, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	answers := strings.Split(strings.TrimSuffix(strings.ReplaceAll(string(data), "\r\n", "\n"), "\n"), "\n")
+
+	return NewScripted(answers, out), nil
+}
+
+func (s *Scripted) Ask(question string) (string, error) {
+	fmt.Fprint(s.out, question)
+
+	if len(s.answers) == 0 {
+		fmt.Fprintln(s.out)
+		return "", fmt.Errorf("%w for %q", ErrNoMoreAnswers, question)
+	}
+
+	answer := s.answers[0]
+	s.answers = s.answers[1:]
+	fmt.Fprintln(s.out, answer)
+
+	return answer, nil
+}
+
+/* Remaining returns the answers not used yet */
+func (s *Scripted) Remaining() []string {
+	return s.answers
+}
+
+/*
+Fake answers by question: the longest key of Answers contained in the question wins,
+other questions get the default. Every question is recorded in Asked.
+*/
+type Fake struct {
+	Answers map[string]string
+	Asked   []string
+}
+
+func (f *Fake) Ask(question string) (string, error) {
+	f.Asked = append(f.Asked, question)
+
+	match := ""
+	for key := range f.Answers {
+		if strings.Contains(question, key) && len(key) > len(match) {
+			match = key
+		}
+	}
+
+	return f.Answers[match], nil
+}
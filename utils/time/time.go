@@ -1,7 +1,6 @@
 package TimeUtils
 
 import (
-	"fmt"
 	"time"
 )
 
@@ -35,12 +34,16 @@ func SetDayOfMonth(timeObj time.Time, dayNumber int) time.Time {
 	return updatedTime
 }
 
-func GetDataFromDdMmYyyyFormat(dateString string) (time.Time, bool) {
+func ParseDdMmYyyy(dateString string) (time.Time, error) {
 	layout := "02-01-2006"
 
-	date, err := time.Parse(layout, dateString)
+	return time.Parse(layout, dateString)
+}
+
+/* GetDataFromDdMmYyyyFormat falls back to the current time, false tells the caller dateString was not a date */
+func GetDataFromDdMmYyyyFormat(dateString string) (time.Time, bool) {
+	date, err := ParseDdMmYyyy(dateString)
 	if err != nil {
-		fmt.Println("Error parsing date:", err)
 		return time.Now(), false
 	}
 
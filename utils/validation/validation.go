@@ -0,0 +1,192 @@
+package Validation
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"regexp"
+	"strings"
+)
+
+/*
+Checks of identifiers printed on invoices: NIP and REGON checksums, IBAN mod-97, BIC
+format and the EU VAT number format of every member state. Every function accepts the
+identifier as typed by people, with spaces, dashes and dots.
+*/
+
+var ErrInvalid = errors.New("invalid identifier")
+
+var nipWeights = []int{6, 5, 7, 2, 3, 4, 5, 6, 7}
+var regon9Weights = []int{8, 9, 2, 3, 4, 5, 6, 7}
+var regon14Weights = []int{2, 4, 8, 5, 0, 9, 7, 3, 6, 1, 2, 4, 8}
+
+var digitsPattern = regexp.MustCompile(`^[0-9]+$`)
+var bicPattern = regexp.MustCompile(`^[A-Z]{4}[A-Z]{2}[A-Z0-9]{2}([A-Z0-9]{3})?$`)
+var ibanPattern = regexp.MustCompile(`^[A-Z]{2}[0-9]{2}[A-Z0-9]{11,30}$`)
+
+/* ibanLengths of EU and EEA countries and a few trading partners, other countries are only checked with mod-97 */
+var ibanLengths = map[string]int{
+	"AT": 20, "BE": 16, "BG": 22, "CH": 21, "CY": 28, "CZ": 24, "DE": 22, "DK": 18,
+	"EE": 20, "ES": 24, "FI": 18, "FR": 27, "GB": 22, "GR": 27, "HR": 21, "HU": 28,
+	"IE": 22, "IS": 26, "IT": 27, "LI": 21, "LT": 20, "LU": 20, "LV": 21, "MT": 31,
+	"NL": 18, "NO": 15, "PL": 28, "PT": 25, "RO": 24, "SE": 24, "SI": 19, "SK": 24,
+	"UA": 29,
+}
+
+/* vatEuPatterns are the VIES formats of the number after the prefix, Greece uses EL and Northern Ireland XI */
+var vatEuPatterns = map[string]*regexp.Regexp{
+	"AT": regexp.MustCompile(`^U[0-9]{8}$`),
+	"BE": regexp.MustCompile(`^[01][0-9]{9}$`),
+	"BG": regexp.MustCompile(`^[0-9]{9,10}$`),
+	"CY": regexp.MustCompile(`^[0-9]{8}[A-Z]$`),
+	"CZ": regexp.MustCompile(`^[0-9]{8,10}$`),
+	"DE": regexp.MustCompile(`^[0-9]{9}$`),
+	"DK": regexp.MustCompile(`^[0-9]{8}$`),
+	"EE": regexp.MustCompile(`^[0-9]{9}$`),
+	"EL": regexp.MustCompile(`^[0-9]{9}$`),
+	"ES": regexp.MustCompile(`^[0-9A-Z][0-9]{7}[0-9A-Z]$`),
+	"FI": regexp.MustCompile(`^[0-9]{8}$`),
+	"FR": regexp.MustCompile(`^[0-9A-Z]{2}[0-9]{9}$`),
+	"HR": regexp.MustCompile(`^[0-9]{11}$`),
+	"HU": regexp.MustCompile(`^[0-9]{8}$`),
+	"IE": regexp.MustCompile(`^([0-9]{7}[A-Z]{1,2}|[0-9][A-Z+*][0-9]{5}[A-Z])$`),
+	"IT": regexp.MustCompile(`^[0-9]{11}$`),
+	"LT": regexp.MustCompile(`^([0-9]{9}|[0-9]{12})$`),
+	"LU": regexp.MustCompile(`^[0-9]{8}$`),
+	"LV": regexp.MustCompile(`^[0-9]{11}$`),
+	"MT": regexp.MustCompile(`^[0-9]{8}$`),
+	"NL": regexp.MustCompile(`^[0-9]{9}B[0-9]{2}$`),
+	"PL": regexp.MustCompile(`^[0-9]{10}$`),
+	"PT": regexp.MustCompile(`^[0-9]{9}$`),
+	"RO": regexp.MustCompile(`^[0-9]{2,10}$`),
+	"SE": regexp.MustCompile(`^[0-9]{10}01$`),
+	"SI": regexp.MustCompile(`^[0-9]{8}$`),
+	"SK": regexp.MustCompile(`^[0-9]{10}$`),
+	"XI": regexp.MustCompile(`^([0-9]{9}|[0-9]{12}|GD[0-4][0-9]{2}|HA[5-9][0-9]{2})$`),
+}
+
+/* Normalize strips spaces, dashes and dots and upper-cases the identifier */
+func Normalize(identifier string) string {
+	return strings.ToUpper(strings.NewReplacer(" ", "", "-", "", ".", "").Replace(strings.TrimSpace(identifier)))
+}
+
+/* ValidateNIP checks the Polish tax number, a PL prefix is allowed */
+func ValidateNIP(nip string) error {
+	normalized := strings.TrimPrefix(Normalize(nip), "PL")
+
+	if len(normalized) != 10 || !digitsPattern.MatchString(normalized) {
+		return fmt.Errorf("%w: NIP %q must have 10 digits", ErrInvalid, nip)
+	}
+
+	if checksum(normalized, nipWeights)%11 != digit(normalized, 9) {
+		return fmt.Errorf("%w: NIP %q has a wrong check digit", ErrInvalid, nip)
+	}
+
+	return nil
+}
+
+/* ValidateREGON checks a 9 digit REGON or a 14 digit REGON of a local unit, whose first 9 digits are a REGON too */
+func ValidateREGON(regon string) error {
+	normalized := Normalize(regon)
+
+	if !digitsPattern.MatchString(normalized) || (len(normalized) != 9 && len(normalized) != 14) {
+		return fmt.Errorf("%w: REGON %q must have 9 or 14 digits", ErrInvalid, regon)
+	}
+
+	weights := regon9Weights
+	if len(normalized) == 14 {
+		if err := ValidateREGON(normalized[:9]); err != nil {
+			return fmt.Errorf("%w: REGON %q does not start with a valid 9 digit REGON", ErrInvalid, regon)
+		}
+		weights = regon14Weights
+	}
+
+	if checksum(normalized, weights)%11%10 != digit(normalized, len(normalized)-1) {
+		return fmt.Errorf("%w: REGON %q has a wrong check digit", ErrInvalid, regon)
+	}
+
+	return nil
+}
+
+/* ValidateIBAN checks the format, the length of known countries and the ISO 13616 mod-97 checksum */
+func ValidateIBAN(iban string) error {
+	normalized := Normalize(iban)
+
+	if !ibanPattern.MatchString(normalized) {
+		return fmt.Errorf("%w: IBAN %q must be a country code, 2 check digits and the account number", ErrInvalid, iban)
+	}
+
+	if length, known := ibanLengths[normalized[:2]]; known && len(normalized) != length {
+		return fmt.Errorf("%w: IBAN %q must have %d characters in %s, has %d", ErrInvalid, iban, length, normalized[:2], len(normalized))
+	}
+
+	/* country code and check digits go to the end, letters become 10..35 */
+	var numeric strings.Builder
+	for _, char := range normalized[4:] + normalized[:4] {
+		if char >= 'A' && char <= 'Z' {
+			numeric.WriteString(fmt.Sprint(char - 'A' + 10))
+		} else {
+			numeric.WriteRune(char)
+		}
+	}
+
+	value, _ := new(big.Int).SetString(numeric.String(), 10)
+	if new(big.Int).Mod(value, big.NewInt(97)).Int64() != 1 {
+		return fmt.Errorf("%w: IBAN %q has wrong check digits", ErrInvalid, iban)
+	}
+
+	return nil
+}
+
+/* ValidateBIC checks the SWIFT/BIC format: bank, country and location code with an optional branch */
+func ValidateBIC(bic string) error {
+	if !bicPattern.MatchString(Normalize(bic)) {
+		return fmt.Errorf("%w: BIC %q must have 8 or 11 characters, e.g. INGBPLPW", ErrInvalid, bic)
+	}
+
+	return nil
+}
+
+/* ValidateVatEuNumber checks the number against the format of the country in its prefix, Polish numbers also by the NIP checksum */
+func ValidateVatEuNumber(vatEuNumber string) error {
+	normalized := Normalize(vatEuNumber)
+
+	if len(normalized) < 3 {
+		return fmt.Errorf("%w: EU VAT number %q must start with the country prefix, e.g. DE", ErrInvalid, vatEuNumber)
+	}
+
+	prefix, number := normalized[:2], normalized[2:]
+	pattern, known := vatEuPatterns[prefix]
+	if !known {
+		return fmt.Errorf("%w: EU VAT number %q has prefix %s, which is not an EU member state (Greece uses EL)", ErrInvalid, vatEuNumber, prefix)
+	}
+
+	if !pattern.MatchString(number) {
+		return fmt.Errorf("%w: EU VAT number %q does not match the format used in %s", ErrInvalid, vatEuNumber, prefix)
+	}
+
+	if prefix == "PL" {
+		return ValidateNIP(number)
+	}
+
+	return nil
+}
+
+/* IsVatEuPrefix reports whether prefix starts EU VAT numbers, these are the country codes except EL for Greece and XI for Northern Ireland */
+func IsVatEuPrefix(prefix string) bool {
+	_, known := vatEuPatterns[prefix]
+	return known
+}
+
+func checksum(digits string, weights []int) int {
+	sum := 0
+	for i, weight := range weights {
+		sum += digit(digits, i) * weight
+	}
+
+	return sum
+}
+
+func digit(digits string, i int) int {
+	return int(digits[i] - '0')
+}
@@ -0,0 +1,118 @@
+package Validation_test
+
+import (
+	"errors"
+	Validation "moneybringer/utils/validation"
+	"testing"
+)
+
+type identifierCase struct {
+	identifier string
+	valid      bool
+}
+
+func check(t *testing.T, name string, validate func(string) error, cases []identifierCase) {
+	t.Helper()
+
+	for _, c := range cases {
+		err := validate(c.identifier)
+
+		if c.valid && err != nil {
+			t.Errorf("%s %q: %v", name, c.identifier, err)
+		}
+		if !c.valid && !errors.Is(err, Validation.ErrInvalid) {
+			t.Errorf("%s %q = %v, want ErrInvalid", name, c.identifier, err)
+		}
+	}
+}
+
+func TestValidateNIP(t *testing.T) {
+	check(t, "NIP", Validation.ValidateNIP, []identifierCase{
+		{"7781234563", true},
+		{"PL7781234563", true},
+		{"778-123-45-63", true},
+		{"PL 778 123 45 63", true},
+		{"2222222222", true},
+		{"7781234564", false}, // wrong check digit
+		{"1234567890", false}, // checksum 10 is never valid
+		{"778123456", false},
+		{"77812345630", false},
+		{"77812345AB", false},
+		{"DE7781234563", false},
+		{"", false},
+	})
+}
+
+func TestValidateREGON(t *testing.T) {
+	check(t, "REGON", Validation.ValidateREGON, []identifierCase{
+		{"123456785", true},
+		{"123-456-785", true},
+		{"12345678512347", true}, // local unit of 123456785
+		{"123456786", false},
+		{"12345678512348", false},
+		{"12345678612347", false}, // local unit of an invalid REGON
+		{"12345678", false},
+		{"1234567851234", false},
+		{"12345678A", false},
+	})
+}
+
+func TestValidateIBAN(t *testing.T) {
+	check(t, "IBAN", Validation.ValidateIBAN, []identifierCase{
+		{"PL61109010140000071219812874", true},
+		{"PL 61 1090 1014 0000 0712 1981 2874", true},
+		{"pl61109010140000071219812874", true},
+		{"PL 45 2222 2222 2222 2222 2222 2222", true},
+		{"DE89370400440532013000", true},
+		{"GB82WEST12345698765432", true},
+		{"PL61109010140000071219812875", false},        // wrong check digits
+		{"PL 22 2222 2222 2222 2222 2222 2222", false}, // wrong check digits
+		{"PL6110901014000007121981287", false},         // too short for PL
+		{"DE8937040044053201300", false},
+		{"61109010140000071219812874", false},
+		{"PL6110901014", false},
+		{"", false},
+	})
+}
+
+func TestValidateBIC(t *testing.T) {
+	check(t, "BIC", Validation.ValidateBIC, []identifierCase{
+		{"INGBPLPW", true},
+		{"ingbplpw", true},
+		{"DEUTDEFF500", true},
+		{"BREXPLPWXXX", true},
+		{"INGBPL", false},
+		{"INGBPLPWX", false},
+		{"1NGBPLPW", false},
+		{"INGB1LPW", false},
+	})
+}
+
+func TestValidateVatEuNumber(t *testing.T) {
+	check(t, "EU VAT number", Validation.ValidateVatEuNumber, []identifierCase{
+		{"DE123456789", true},
+		{"de 123 456 789", true},
+		{"ATU12345678", true},
+		{"NL123456789B01", true},
+		{"EL123456789", true},
+		{"FRXX123456789", true},
+		{"SE123456789001", true},
+		{"PL7781234563", true},
+		{"DE12345678", false},
+		{"ATU1234567", false},
+		{"NL123456789", false},
+		{"GR123456789", false}, // Greece uses EL
+		{"US123456789", false},
+		{"PL7781234564", false}, // NIP check digit
+		{"123456789", false},
+		{"D", false},
+	})
+}
+
+func TestIsVatEuPrefix(t *testing.T) {
+	for prefix, expected := range map[string]bool{"DE": true, "EL": true, "XI": true, "PL": true, "GR": false, "GB": false, "US": false} {
+		if Validation.IsVatEuPrefix(prefix) != expected {
+			t.Errorf("IsVatEuPrefix(%s) = %v, want %v", prefix, !expected, expected)
+		}
+	}
+}
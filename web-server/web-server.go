@@ -0,0 +1,423 @@
+package WebServer
+
+import (
+	"embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"mime"
+	ExchangeRate "moneybringer/exchange-rate"
+	InvoiceGenerator "moneybringer/invoice-generator"
+	InvoiceManager "moneybringer/invoice-manager"
+	CompanyData "moneybringer/invoice-manager/company"
+	CustomerData "moneybringer/invoice-manager/customer"
+	Invoice "moneybringer/invoice-manager/invoice"
+	InvoiceSpec "moneybringer/invoice-manager/spec"
+	InvoiceStore "moneybringer/invoice-store"
+	KsefExporter "moneybringer/ksef-exporter"
+	AppErrors "moneybringer/utils/app-errors"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+/*
+JSON HTTP API and a small HTML front-end over the invoice packages, for people who do
+not use the CLI. Invoices are created from the same spec as create --input, then stored,
+exported to FA(2) XML and rendered as by the CLI. Sending to KSeF stays in the CLI.
+
+	GET  /api/defaults                     spec with the defaults of the seller profile
+	GET  /api/customers                    customers from customers.json
+	POST /api/preview                      totals of a spec, nothing is stored
+	GET  /api/invoices?month=&customer=&profile=
+	POST /api/invoices[?proforma=true]     create and issue an invoice from a spec
+	GET  /api/invoices/<number>[?profile=] stored invoice
+	GET  /api/invoices/<number>.pdf        PDF rendered from the stored invoice
+	GET  /api/invoices/<number>.json       invoice JSON as a download
+
+Issued invoices cannot be deleted, so other web pages must not reach the API through the
+browser: requests naming another Host (DNS rebinding) or coming from another Origin are
+refused, and POST bodies must be sent as application/json, which a cross-origin form or
+simple request cannot do without a CORS preflight the server never answers.
+*/
+
+//go:embed static
+var static embed.FS
+
+/* MAX_SPEC_SIZE limits request bodies, a spec with hundreds of positions stays far below it */
+const MAX_SPEC_SIZE = 1 << 20
+
+type Server struct {
+	rates      ExchangeRate.Provider
+	repository InvoiceStore.InvoiceRepository
+	/* allowedHosts are the host:port values the server is reached by, lower case */
+	allowedHosts map[string]bool
+	/* issuing is serialized, so the numbers previewed and issued follow the order of requests */
+	issueMutex sync.Mutex
+}
+
+type invoiceListItem struct {
+	DocumentKind        string `json:"documentKind"`
+	Profile             string `json:"profile"`
+	InvoiceNo           string `json:"invoiceNo"`
+	DateOfIssue         string `json:"dateOfIssue"`
+	Customer            string `json:"customer"`
+	TotalGrossValue     string `json:"totalGrossValue"`
+	Currency            string `json:"currency"`
+	KsefReferenceNumber string `json:"ksefReferenceNumber,omitempty"`
+	Url                 string `json:"url"`
+	PdfUrl              string `json:"pdfUrl"`
+	JsonUrl             string `json:"jsonUrl"`
+}
+
+type customerListItem struct {
+	Key      string `json:"key"`
+	FullName string `json:"fullName"`
+	City     string `json:"city"`
+}
+
+type errorResponse struct {
+	Error    string   `json:"error"`
+	Problems []string `json:"problems,omitempty"`
+}
+
+/*
+New serves invoices of the profile selected with CompanyData.SelectProfile, rates convert
+foreign currency invoices. allowedHosts are the host:port values browsers use to reach the
+server, e.g. "localhost:8080", requests with any other Host or Origin are refused.
+*/
+func New(rates ExchangeRate.Provider, repository InvoiceStore.InvoiceRepository, allowedHosts []string) *Server {
+	server := &Server{rates: rates, repository: repository, allowedHosts: map[string]bool{}}
+	for _, host := range allowedHosts {
+		server.allowedHosts[strings.ToLower(host)] = true
+	}
+
+	return server
+}
+
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+
+	staticFiles, _ := fs.Sub(static, "static")
+	mux.Handle("GET /", http.FileServer(http.FS(staticFiles)))
+
+	mux.HandleFunc("GET /api/defaults", s.getDefaults)
+	mux.HandleFunc("GET /api/customers", s.listCustomers)
+	mux.HandleFunc("POST /api/preview", s.previewInvoice)
+	mux.HandleFunc("GET /api/invoices", s.listInvoices)
+	mux.HandleFunc("POST /api/invoices", s.createInvoice)
+	mux.HandleFunc("GET /api/invoices/{number...}", s.getInvoice)
+
+	return logRequests(s.guardRequests(mux))
+}
+
+/* guardRequests refuses requests a page of another site could make through the user's browser */
+func (s *Server) guardRequests(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !s.allowedHosts[strings.ToLower(r.Host)] {
+			writeJSON(w, http.StatusForbidden, errorResponse{Error: fmt.Sprintf("host %q is not served, open the address moneybringer serve printed", r.Host)})
+			return
+		}
+
+		if origin := r.Header.Get("Origin"); origin != "" {
+			originUrl, err := url.Parse(origin)
+			if err != nil || !s.allowedHosts[strings.ToLower(originUrl.Host)] {
+				writeJSON(w, http.StatusForbidden, errorResponse{Error: fmt.Sprintf("requests from origin %q are not allowed", origin)})
+				return
+			}
+		}
+
+		if r.Method == http.MethodPost {
+			mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+			if err != nil || mediaType != "application/json" {
+				writeJSON(w, http.StatusUnsupportedMediaType, errorResponse{Error: "request body must be sent as Content-Type: application/json"})
+				return
+			}
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (s *Server) getDefaults(w http.ResponseWriter, r *http.Request) {
+	companyData, err := CompanyData.GetCompanyData()
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, InvoiceManager.DefaultSpec(companyData, ""))
+}
+
+func (s *Server) listCustomers(w http.ResponseWriter, r *http.Request) {
+	customersData, err := CustomerData.LoadCustomers(CustomerData.CustomersJsonPath())
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	items := []customerListItem{}
+	for _, key := range sortedKeys(customersData.Customers) {
+		customer := customersData.Customers[key]
+		items = append(items, customerListItem{Key: key, FullName: customer.FullName, City: customer.Address.City})
+	}
+
+	writeJSON(w, http.StatusOK, items)
+}
+
+/* previewInvoice returns the totals and VAT breakdown the invoice will have, the front-end shows them while positions are edited */
+func (s *Server) previewInvoice(w http.ResponseWriter, r *http.Request) {
+	spec, ok := readSpec(w, r)
+	if !ok {
+		return
+	}
+
+	companyData, err := CompanyData.GetCompanyData()
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	summary, err := InvoiceManager.SpecSummary(spec, companyData)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, summary)
+}
+
+func (s *Server) listInvoices(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	filter := InvoiceStore.Filter{Profile: query.Get("profile"), Customer: query.Get("customer")}
+	if monthValue := query.Get("month"); monthValue != "" {
+		month, err := time.Parse("2006-01", monthValue)
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, errorResponse{Error: "month must be in YYYY-MM format"})
+			return
+		}
+		filter.Year, filter.Month = month.Year(), month.Month()
+	}
+
+	storedInvoices, err := s.repository.List(filter)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	items := []invoiceListItem{}
+	for _, stored := range storedInvoices {
+		items = append(items, listItem(stored.Invoice))
+	}
+
+	writeJSON(w, http.StatusOK, items)
+}
+
+/* createInvoice issues the invoice described by the spec in the body, ?proforma=true issues a proforma instead */
+func (s *Server) createInvoice(w http.ResponseWriter, r *http.Request) {
+	spec, ok := readSpec(w, r)
+	if !ok {
+		return
+	}
+
+	s.issueMutex.Lock()
+	defer s.issueMutex.Unlock()
+
+	invoice, err := InvoiceManager.CreateInvoiceFromSpec(spec)
+	if err == nil && r.URL.Query().Get("proforma") == "true" {
+		invoice, err = InvoiceManager.AsProforma(invoice)
+	}
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	stored, err := s.issue(invoice)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.Header().Set("Location", invoiceUrl(stored.Invoice, ""))
+	writeJSON(w, http.StatusCreated, stored.Invoice)
+}
+
+/* issue numbers, stores, exports and renders the invoice in the order of the create command */
+func (s *Server) issue(invoice InvoiceManager.InvoiceCreatedData) (InvoiceStore.StoredInvoice, error) {
+	if err := InvoiceManager.ApplyExchangeRate(&invoice, s.rates); err != nil {
+		return InvoiceStore.StoredInvoice{}, err
+	}
+
+	var stored InvoiceStore.StoredInvoice
+	err := InvoiceManager.IssueInvoice(&invoice, func(numbered InvoiceManager.InvoiceCreatedData) error {
+		var saveErr error
+		stored, saveErr = s.repository.Save(numbered)
+		return saveErr
+	})
+	if err != nil {
+		return stored, err
+	}
+
+	if stored.Invoice.Kind() != InvoiceManager.DOCUMENT_KIND_PROFORMA {
+		if err := KsefExporter.SaveFA2(stored.Invoice, stored.FilePath("xml")); err != nil {
+			return stored, fmt.Errorf("invoice %s is stored, exporting FA(2) XML failed: %w", stored.Invoice.InvoiceNo, err)
+		}
+	}
+
+	if err := InvoiceGenerator.GenerateInvoicePDF(stored.Invoice, stored.PdfPath()); err != nil {
+		return stored, fmt.Errorf("invoice %s is stored, generating PDF failed: %w", stored.Invoice.InvoiceNo, err)
+	}
+
+	return stored, nil
+}
+
+/* getInvoice serves /api/invoices/<number>, numbers contain slashes so the format is the extension */
+func (s *Server) getInvoice(w http.ResponseWriter, r *http.Request) {
+	number := r.PathValue("number")
+	extension := ""
+	for _, candidate := range []string{".pdf", ".json"} {
+		if strings.HasSuffix(number, candidate) {
+			number, extension = strings.TrimSuffix(number, candidate), candidate
+		}
+	}
+
+	stored, err := s.repository.Get(number, r.URL.Query().Get("profile"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	fileName := strings.TrimSuffix(filepath.Base(stored.PdfPath()), ".pdf")
+
+	switch extension {
+	case ".pdf":
+		servePdf(w, r, stored, fileName+".pdf")
+	case ".json":
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", fileName+".json"))
+		writeJSON(w, http.StatusOK, stored.Invoice)
+	default:
+		writeJSON(w, http.StatusOK, stored.Invoice)
+	}
+}
+
+/* servePdf renders the stored invoice again, like the render command, so the PDF follows the current logo and fonts */
+func servePdf(w http.ResponseWriter, r *http.Request, stored InvoiceStore.StoredInvoice, fileName string) {
+	pdfFile, err := os.CreateTemp("", "moneybringer-*.pdf")
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	pdfFile.Close()
+	defer os.Remove(pdfFile.Name())
+
+	if err := InvoiceGenerator.GenerateInvoicePDF(stored.Invoice, pdfFile.Name()); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/pdf")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("inline; filename=%q", fileName))
+	http.ServeFile(w, r, pdfFile.Name())
+}
+
+func readSpec(w http.ResponseWriter, r *http.Request) (InvoiceSpec.Spec, bool) {
+	var spec InvoiceSpec.Spec
+
+	decoder := json.NewDecoder(http.MaxBytesReader(w, r.Body, MAX_SPEC_SIZE))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&spec); err != nil {
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: fmt.Sprintf("cannot parse invoice spec: %v", err)})
+		return spec, false
+	}
+
+	if problems := spec.Validate(); len(problems) > 0 {
+		writeJSON(w, http.StatusUnprocessableEntity, errorResponse{Error: "invalid invoice spec", Problems: problems})
+		return spec, false
+	}
+
+	return spec, true
+}
+
+func listItem(invoice InvoiceManager.InvoiceCreatedData) invoiceListItem {
+	return invoiceListItem{
+		DocumentKind:        invoice.Kind(),
+		Profile:             invoice.SellerProfile(),
+		InvoiceNo:           invoice.InvoiceNo,
+		DateOfIssue:         invoice.DateOfIssue,
+		Customer:            invoice.InvoiceTo.FullName,
+		TotalGrossValue:     invoice.InvoiceSummary.TotalGrossValue.String(),
+		Currency:            InvoiceManager.CurrencyOf(invoice),
+		KsefReferenceNumber: invoice.KsefReferenceNumber,
+		Url:                 invoiceUrl(invoice, ""),
+		PdfUrl:              invoiceUrl(invoice, ".pdf"),
+		JsonUrl:             invoiceUrl(invoice, ".json"),
+	}
+}
+
+/* invoiceUrl keeps the slashes of the number as path separators, the profile tells apart equal numbers of several profiles */
+func invoiceUrl(invoice InvoiceManager.InvoiceCreatedData, extension string) string {
+	segments := strings.Split(invoice.InvoiceNo, "/")
+	for i, segment := range segments {
+		segments[i] = url.PathEscape(segment)
+	}
+
+	return fmt.Sprintf("/api/invoices/%s%s?profile=%s", strings.Join(segments, "/"), extension, url.QueryEscape(invoice.SellerProfile()))
+}
+
+/* statusOf maps the errors of the invoice packages to HTTP statuses, like the CLI maps them to exit codes */
+func statusOf(err error) int {
+	switch {
+	case errors.Is(err, InvoiceStore.ErrInvoiceNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, InvoiceStore.ErrAmbiguousInvoice), errors.Is(err, InvoiceStore.ErrInvoiceExists):
+		return http.StatusConflict
+	case errors.Is(err, CustomerData.ErrCustomerNotFound), errors.Is(err, AppErrors.ErrConfigInvalid), errors.Is(err, InvoiceManager.ErrMixedCurrency), errors.Is(err, Invoice.ErrMissingExemptionBasis):
+		return http.StatusUnprocessableEntity
+	}
+
+	return http.StatusInternalServerError
+}
+
+func writeError(w http.ResponseWriter, err error) {
+	status := statusOf(err)
+	if status == http.StatusInternalServerError {
+		log.Printf("error: %v", err)
+	}
+
+	writeJSON(w, status, errorResponse{Error: err.Error()})
+}
+
+func writeJSON(w http.ResponseWriter, status int, value interface{}) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	encoder.Encode(value)
+}
+
+func logRequests(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		started := time.Now()
+		next.ServeHTTP(w, r)
+		log.Printf("%s %s %s", r.Method, r.URL.RequestURI(), time.Since(started).Round(time.Millisecond))
+	})
+}
+
+func sortedKeys[V any](values map[string]V) []string {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	return keys
+}